@@ -1,7 +1,11 @@
 package main
 
-import "github.com/Zephyruston/Agent-Cat-Agent/internal/cli"
+import (
+	"os"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/cli"
+)
 
 func main() {
-	cli.Execute()
+	os.Exit(cli.Execute())
 }
@@ -2,73 +2,245 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
 	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
 )
 
+// Guard 在写入文件前检查即将写入的文件（绝对路径到内容），返回错误时不写入任何文件
+type Guard func(files map[string]string) error
+
 type Generator struct {
-	OpenAI *llm.OpenAIClient
-	Docker *container.DockerClient
+	LLM       llm.Provider
+	Runtime   container.Runtime
+	Languages *lang.Registry
+	Guard     Guard // 为 nil 时不检查
+	// Limits 写入模型生成文件的限制，零值时使用 workspace.DefaultLimits
+	Limits workspace.Limits
+	// Schema 非空时要求模型按 JSON Schema 输出
+	Schema *llm.Schema
+	// OnDelta 非空时以流式请求模型并回调增量文本
+	OnDelta func(delta string)
+	// Prompts GenerateCode 使用的提示词模板，为 nil 时使用内置模板
+	Prompts *prompt.Set
 }
 
-func NewGenerator(openai *llm.OpenAIClient, docker *container.DockerClient) *Generator {
-	return &Generator{OpenAI: openai, Docker: docker}
+func NewGenerator(provider llm.Provider, runtime container.Runtime) *Generator {
+	return &Generator{LLM: provider, Runtime: runtime, Languages: lang.Default()}
 }
 
 // GenerateCode 根据 prompt 生成代码（兼容单文件，返回主文件内容）
-func (g *Generator) GenerateCode(ctx context.Context, prompt, language, model string) (string, error) {
-	return g.OpenAI.RawChatCompletion(ctx, prompt, language, model)
+func (g *Generator) GenerateCode(ctx context.Context, text, language, model string) (string, error) {
+	messages, err := promptMessages(g.Prompts, prompt.ModeGen, prompt.NewData(g.Languages, language, text))
+	if err != nil {
+		return "", err
+	}
+	return g.Complete(ctx, model, messages)
+}
+
+// promptMessages 用 set（为 nil 时使用内置模板）渲染 mode 的系统消息与用户消息
+func promptMessages(set *prompt.Set, mode string, d prompt.Data) ([]llm.Message, error) {
+	if set == nil {
+		set = prompt.Default()
+	}
+	system, err := set.Render(mode, prompt.RoleSystem, d)
+	if err != nil {
+		return nil, err
+	}
+	user, err := set.Render(mode, prompt.RoleUser, d)
+	if err != nil {
+		return nil, err
+	}
+	return []llm.Message{{Role: llm.RoleSystem, Content: system}, {Role: llm.RoleUser, Content: user}}, nil
 }
 
-// RunCodeInDocker 支持传递所有 mainFiles 和 depFiles，go run 时全部传递
-func (g *Generator) RunCodeInDocker(ctx context.Context, language, workDir string, mainFiles, depFiles []string, targetDir string) (string, error) {
-	image := "golang:1.24.0"
-	if language != "go" {
-		// 保持原有逻辑
-		if language == "python" {
-			cmd := []string{"python", mainFiles[0]}
-			return g.Docker.RunContainerWithMount(ctx, image, cmd, workDir, targetDir)
+// MaxContinuations 回复因长度限制被截断时最多请求续写的次数
+const MaxContinuations = 3
+
+// Complete 发送完整对话历史，返回模型回复；回复被截断时用 continue 模板请求模型续写并拼接，
+// 续写次数用完仍被截断时返回 llm.Truncated 错误
+func (g *Generator) Complete(ctx context.Context, model string, messages []llm.Message) (string, error) {
+	req := llm.Request{Model: model, Messages: messages, Schema: g.Schema, OnDelta: g.OnDelta}
+	content := ""
+	for i := 0; ; i++ {
+		resp, err := g.LLM.Complete(ctx, req)
+		var incomplete *llm.CompletionError
+		if !errors.As(err, &incomplete) || incomplete.Condition != llm.Truncated || i >= MaxContinuations {
+			if err != nil {
+				return "", errs.E(errs.LLM, "generate code", err)
+			}
+			return stitch(content, resp.Content), nil
+		}
+		content = stitch(content, incomplete.Response.Content)
+		next, err := g.continuation(messages, content)
+		if err != nil {
+			return "", err
 		}
-		return "", fmt.Errorf("unsupported language: %s", language)
+		// 续写是任意片段，不再要求结构化输出
+		req.Messages, req.Schema = next, nil
 	}
+}
 
-	// Go 多 main 文件智能运行
-	var result strings.Builder
-	if len(mainFiles) == 1 {
-		// 单主程序，全部一起 run
-		cmd := []string{"go", "run"}
-		cmd = append(cmd, relPaths(mainFiles, workDir)...) // main
-		cmd = append(cmd, relPaths(depFiles, workDir)...)  // dep
-		out, err := g.Docker.RunContainerWithMount(ctx, image, cmd, workDir, targetDir)
+// continuation 返回请求模型从 partial 的截断处续写的对话
+func (g *Generator) continuation(messages []llm.Message, partial string) ([]llm.Message, error) {
+	set := g.Prompts
+	if set == nil {
+		set = prompt.Default()
+	}
+	text, err := set.Render(prompt.ModeContinue, prompt.RoleUser, prompt.Data{})
+	if err != nil {
+		return nil, err
+	}
+	return append(messages[:len(messages):len(messages)],
+		llm.Message{Role: llm.RoleAssistant, Content: partial},
+		llm.Message{Role: llm.RoleUser, Content: text}), nil
+}
+
+// 续写开头与已输出内容末尾的重叠至少包含 minOverlap 个非空白字符才视为重复输出，最多检查 maxOverlap 字节
+const (
+	minOverlap = 8
+	maxOverlap = 4096
+)
+
+// stitch 拼接被截断的回复 prev 与续写 next：截断在代码块内而续写重新打开代码块时去掉围栏行，
+// 并去掉续写开头重复的已输出内容（较长的重叠，或被截断的最后半行）
+func stitch(prev, next string) string {
+	if prev == "" {
+		return next
+	}
+	if strings.Count(prev, "```")%2 == 1 {
+		if trimmed := strings.TrimLeft(next, "\n"); strings.HasPrefix(trimmed, "```") {
+			if i := strings.IndexByte(trimmed, '\n'); i >= 0 {
+				next = trimmed[i+1:]
+			}
+		}
+	}
+	for n := min(len(prev), len(next), maxOverlap); n >= minOverlap; n-- {
+		if strings.HasSuffix(prev, next[:n]) && len(strings.Join(strings.Fields(next[:n]), "")) >= minOverlap {
+			return prev + next[n:]
+		}
+	}
+	if tail := prev[strings.LastIndexByte(prev, '\n')+1:]; tail != "" && strings.HasPrefix(next, tail) {
+		return prev + next[len(tail):]
+	}
+	return prev + next
+}
+
+// RunCode 在容器中运行 mainFiles，go run 时全部传递 mainFiles 和 depFiles
+func (g *Generator) RunCode(ctx context.Context, language, workDir string, mainFiles, depFiles []string, targetDir string) (string, error) {
+	l, err := lookupLanguage(g.Languages, language)
+	if err != nil {
+		return "", err
+	}
+	if len(mainFiles) == 0 {
+		return "", errs.Errorf(errs.Extract, "run code", "no main file to run")
+	}
+	if g.Runtime == nil {
+		return "", errs.Errorf(errs.Runtime, "run code", "container runtime not initialized")
+	}
+	out, err := g.runCode(ctx, l, workDir, mainFiles, depFiles, targetDir)
+	return out, classifyRunError(language, "run code", out, err)
+}
+
+// RunCommand 在语言容器中用 sh -c 执行 command，错误按编译/运行失败分类
+func (g *Generator) RunCommand(ctx context.Context, language, workDir, command, targetDir string) (string, error) {
+	l, err := lookupLanguage(g.Languages, language)
+	if err != nil {
+		return "", err
+	}
+	if g.Runtime == nil {
+		return "", errs.Errorf(errs.Runtime, "run command", "container runtime not initialized")
+	}
+	out, err := g.Runtime.Run(ctx, container.RunSpec{Image: l.Image, Cmd: []string{"sh", "-c", command}, HostDir: workDir, MountDir: targetDir})
+	return out, classifyRunError(language, "run command", out, err)
+}
+
+func (g *Generator) runCode(ctx context.Context, l *lang.Language, workDir string, mainFiles, depFiles []string, targetDir string) (string, error) {
+	run := func(mains []string) (string, error) {
+		return g.Runtime.Run(ctx, container.RunSpec{
+			Image:    l.Image,
+			Cmd:      l.RunCmd(relPaths(mains, workDir), relPaths(depFiles, workDir)),
+			HostDir:  workDir,
+			MountDir: targetDir,
+		})
+	}
+	// 单主程序或非 Go 语言，直接运行
+	out, err := run(mainFiles)
+	if l.Name != "go" || len(mainFiles) == 1 {
 		return out, err
 	}
-	// 多主程序，先尝试全部 run
-	cmdAll := []string{"go", "run"}
-	cmdAll = append(cmdAll, relPaths(mainFiles, workDir)...)
-	cmdAll = append(cmdAll, relPaths(depFiles, workDir)...)
-	out, err := g.Docker.RunContainerWithMount(ctx, image, cmdAll, workDir, targetDir)
+	// Go 多主程序，全部 run 成功或不是重复 main 错误，直接返回
 	if err == nil || !strings.Contains(out+err.Error(), "main redeclared") {
-		// 成功或不是重复 main 错误，直接返回
 		return out, err
 	}
 	// 否则分别运行每个 mainFile
+	var result strings.Builder
+	var firstErr error
 	for _, mf := range mainFiles {
-		cmd := []string{"go", "run", relPath(mf, workDir)}
-		cmd = append(cmd, relPaths(depFiles, workDir)...)
-		out, err := g.Docker.RunContainerWithMount(ctx, image, cmd, workDir, targetDir)
+		out, err := run([]string{mf})
 		result.WriteString("==== Output for " + filepath.Base(mf) + " ====" + "\n")
 		if err != nil {
 			result.WriteString("[ERROR] " + err.Error() + "\n")
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 		result.WriteString(out + "\n")
 	}
-	return result.String(), nil
+	return result.String(), firstErr
+}
+
+// lookupLanguage 从注册表中查找语言，未注册时返回参数错误
+func lookupLanguage(r *lang.Registry, language string) (*lang.Language, error) {
+	if r == nil {
+		r = lang.Default()
+	}
+	l, ok := r.Get(language)
+	if !ok {
+		return nil, errs.Errorf(errs.Usage, "lookup language", "unsupported language: %s", language)
+	}
+	return l, nil
+}
+
+// goBuildErrRe 匹配 go 编译器输出的包头或 file:line:col 形式的错误
+var goBuildErrRe = regexp.MustCompile(`(?m)^# \S+|\.go:\d+:\d+: `)
+
+// classifyRunError 根据容器输出区分编译错误与运行错误
+func classifyRunError(language, op, output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errs.KindOf(err) != errs.Other {
+		return err
+	}
+	var exitErr *container.ExitError
+	if !errors.As(err, &exitErr) {
+		// 容器本身启动/等待失败
+		return errs.E(errs.Runtime, op, err)
+	}
+	switch language {
+	case "go":
+		if !strings.Contains(output, "panic:") && goBuildErrRe.MatchString(output) {
+			return errs.E(errs.Build, op, err)
+		}
+	case "python":
+		if strings.Contains(output, "SyntaxError") || strings.Contains(output, "IndentationError") {
+			return errs.E(errs.Build, op, err)
+		}
+	}
+	return errs.E(errs.Runtime, op, err)
 }
 
 // relPaths 批量转相对路径
@@ -89,18 +261,20 @@ func relPath(path, workDir string) string {
 	return path
 }
 
-// WriteFilesToDirAndCollectMain 写入多文件并返回所有 main 包和依赖 go 文件路径
-func WriteFilesToDirAndCollectMain(files map[string]string, workDir, language string) ([]string, []string, error) {
-	var mainFiles []string
-	var depFiles []string
+// goIdentRe 合法的 Go 包名
+var goIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PlanFiles 计算多文件相对 workDir 的写入路径：Go 非 main 包放入以包名命名的子目录。
+// 返回相对路径到内容的映射、其中属于 main 包的路径，以及包名不合法而被拒绝的文件
+func PlanFiles(files map[string]string, language string) (map[string]string, map[string]bool, []workspace.Rejected) {
+	planned := make(map[string]string, len(files))
+	mains := make(map[string]bool)
+	var rejected []workspace.Rejected
 	for name, code := range files {
 		if language != "go" {
 			// 其他语言暂时全部写入 workDir
-			path := filepath.Join(workDir, name)
-			if err := os.WriteFile(path, []byte(code), 0644); err != nil {
-				return nil, nil, err
-			}
-			mainFiles = append(mainFiles, path)
+			planned[name] = code
+			mains[name] = true
 			continue
 		}
 		// Go: 检查 package
@@ -111,54 +285,171 @@ func WriteFilesToDirAndCollectMain(files map[string]string, workDir, language st
 				break
 			}
 		}
-		if pkg == "main" {
-			path := filepath.Join(workDir, name)
-			if err := os.WriteFile(path, []byte(code), 0644); err != nil {
-				return nil, nil, err
-			}
+		switch {
+		case pkg == "main":
+			planned[name] = code
+			mains[name] = true
+		case !goIdentRe.MatchString(pkg):
+			rejected = append(rejected, workspace.Rejected{Path: name, Reason: fmt.Sprintf("invalid package name %q", pkg)})
+		case filepath.Base(filepath.Dir(name)) == pkg:
+			// 结构化输出中已带包目录
+			planned[name] = code
+		default:
+			planned[filepath.Join(pkg, name)] = code
+		}
+	}
+	return planned, mains, rejected
+}
+
+// WriteFilesToDirAndCollectMain 校验并写入多文件，返回所有 main 包和依赖 go 文件路径（已排序）；
+// 任一文件未通过校验时不写入任何文件并返回 *workspace.RejectedError
+func WriteFilesToDirAndCollectMain(files map[string]string, workDir, language string) ([]string, []string, error) {
+	return writeFiles(files, workDir, language, workspace.DefaultLimits, nil)
+}
+
+func writeFiles(files map[string]string, workDir, language string, limits workspace.Limits, guard Guard) ([]string, []string, error) {
+	planned, mains, rejected := PlanFiles(files, language)
+	validated, err := workspace.Validate(workDir, planned, limits)
+	var rej *workspace.RejectedError
+	if errors.As(err, &rej) {
+		rejected = append(rejected, rej.Files...)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if len(rejected) > 0 {
+		return nil, nil, &workspace.RejectedError{Files: rejected}
+	}
+	if guard != nil {
+		if err := guard(validated); err != nil {
+			return nil, nil, err
+		}
+	}
+	root, err := filepath.Abs(workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var mainFiles []string
+	var depFiles []string
+	for path, code := range validated {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, nil, err
+		}
+		if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+			return nil, nil, err
+		}
+		if rel, _ := filepath.Rel(root, path); mains[rel] || mains[filepath.ToSlash(rel)] {
 			mainFiles = append(mainFiles, path)
 		} else {
-			dir := filepath.Join(workDir, pkg)
-			os.MkdirAll(dir, 0755)
-			path := filepath.Join(dir, name)
-			if err := os.WriteFile(path, []byte(code), 0644); err != nil {
-				return nil, nil, err
-			}
 			depFiles = append(depFiles, path)
 		}
 	}
+	sort.Strings(mainFiles)
+	sort.Strings(depFiles)
 	return mainFiles, depFiles, nil
 }
 
-func (g *Generator) GenerateCodeAndWriteFiles(ctx context.Context, prompt, language, model, workDir string, extractFiles func(string, string) map[string]string) (string, string, error) {
-	content, err := g.OpenAI.RawChatCompletion(ctx, prompt, language, model)
+// GenerateCodeAndWriteFiles 生成代码并写入 workDir，返回 LLM 原始响应及 main/依赖文件路径
+func (g *Generator) GenerateCodeAndWriteFiles(ctx context.Context, prompt, language, model, workDir string, extractFiles func(string, string) map[string]string) (string, []string, []string, error) {
+	if _, err := lookupLanguage(g.Languages, language); err != nil {
+		return "", nil, nil, err
+	}
+	content, err := g.GenerateCode(ctx, prompt, language, model)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	mainFiles, depFiles, err := g.WriteCodeFiles(content, language, workDir, extractFiles)
+	return content, mainFiles, depFiles, err
+}
+
+// WriteCodeFiles 从 LLM 响应中提取代码写入 workDir，返回 main/依赖文件路径
+func (g *Generator) WriteCodeFiles(content, language, workDir string, extractFiles func(string, string) map[string]string) ([]string, []string, error) {
+	l, err := lookupLanguage(g.Languages, language)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, entry, err := extractCodeFiles(content, l.MainFile, extractFiles)
 	if err != nil {
-		return "", "", err
+		return nil, nil, err
+	}
+	if len(files) == 0 || strings.TrimSpace(content) == "" {
+		return nil, nil, errs.Errorf(errs.Extract, "extract code", "no code found in llm response")
+	}
+	limits := g.Limits
+	if limits == (workspace.Limits{}) {
+		limits = workspace.DefaultLimits
+	}
+	mainFiles, depFiles, err := writeFiles(files, workDir, language, limits, g.Guard)
+	var rejected *workspace.RejectedError
+	if errors.As(err, &rejected) {
+		return nil, nil, errs.E(errs.Extract, "write files", err)
 	}
-	files := extractFiles(content, MainFileName(language))
-	mainFiles, _, err := WriteFilesToDirAndCollectMain(files, workDir, language)
 	if err != nil {
-		return "", "", err
+		if errs.KindOf(err) != errs.Other {
+			return nil, nil, err
+		}
+		return nil, nil, errs.E(errs.Other, "write files", err)
+	}
+	if len(mainFiles) == 0 {
+		return nil, nil, errs.Errorf(errs.Extract, "extract code", "no main package in llm response")
 	}
+	mainFiles = entrypointFirst(mainFiles, workDir, entry)
 	if language == "go" {
 		if err := PostProcessGoFiles(workDir); err != nil {
-			return "", "", err
+			return nil, nil, errs.E(errs.Build, "goimports", err)
 		}
 	}
-	return content, mainFiles[0], nil
+	return mainFiles, depFiles, nil
 }
 
-// PostProcessGoFiles 自动调用 goimports 修复 import，仅处理 workDir 下所有 go 文件
+// extractCodeFiles 回复为 JSON 时按结构化输出解析并校验，返回文件与模型指定的主文件，
+// 否则使用 extractFiles 从 markdown 中提取
+func extractCodeFiles(content, defaultFile string, extractFiles func(string, string) map[string]string) (map[string]string, string, error) {
+	if !llm.IsStructured(content) {
+		return extractFiles(content, defaultFile), "", nil
+	}
+	out, err := llm.ParseCodeOutput(content)
+	if err != nil {
+		return nil, "", errs.E(errs.Extract, "parse structured output", err)
+	}
+	return out.FileMap(), out.Entrypoint, nil
+}
+
+// entrypointFirst 将 entry（相对 workDir）移到 mainFiles 最前，语言的 RunCmd 只运行第一个主文件时运行的就是它；
+// entry 为空或不是主文件时保持原顺序
+func entrypointFirst(mainFiles []string, workDir, entry string) []string {
+	if entry == "" {
+		return mainFiles
+	}
+	root, err := filepath.Abs(workDir)
+	if err != nil {
+		return mainFiles
+	}
+	want := filepath.Join(root, filepath.FromSlash(entry))
+	for i, f := range mainFiles {
+		if f == want {
+			out := append([]string{f}, mainFiles[:i]...)
+			return append(out, mainFiles[i+1:]...)
+		}
+	}
+	return mainFiles
+}
+
+// PostProcessGoFiles 自动调用宿主机上的 goimports 修复 import，仅处理 workDir 下所有 go 文件；
+// 未安装 goimports 时跳过，由容器中的质量门禁修复。输出都写到标准错误，标准输出留给 --events、MCP stdio 等机器可读的输出
 func PostProcessGoFiles(workDir string) error {
+	if _, err := exec.LookPath("goimports"); err != nil {
+		return nil
+	}
 	cmd := exec.Command("goimports", "-w", workDir)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = os.Stderr
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// MainFileName 返回内置语言的默认主文件名
 func MainFileName(language string) string {
-	if language == "python" {
-		return "main.py"
+	if l, ok := lang.Default().Get(language); ok {
+		return l.MainFile
 	}
 	return "main.go"
 }
@@ -1,10 +1,17 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
 )
 
 func TestWriteFilesToDirAndCollectMain(t *testing.T) {
@@ -38,3 +45,176 @@ func TestWriteFilesToDirAndCollectMain(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteCodeFilesRunsEntrypointFirst(t *testing.T) {
+	dir := t.TempDir()
+	g := &Generator{}
+	content := `{"files": [{"path": "app.py", "language": "python", "content": "x = 1\n"}, {"path": "main.py", "language": "python", "content": "import app\n"}], "entrypoint": "main.py", "run_command": ""}`
+	mainFiles, _, err := g.WriteCodeFiles(content, "python", dir, llm.ExtractCodeFilesFromLLMResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mainFiles) != 2 || filepath.Base(mainFiles[0]) != "main.py" {
+		t.Errorf("expect main.py to run first, got %v", mainFiles)
+	}
+}
+
+func TestClassifyRunError(t *testing.T) {
+	exit := &container.ExitError{Code: 1}
+	cases := []struct {
+		name     string
+		language string
+		output   string
+		err      error
+		expect   errs.Kind
+	}{
+		{"go compile error", "go", "# command-line-arguments\n./main.go:3:2: undefined: foo", exit, errs.Build},
+		{"go panic", "go", "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x1d", exit, errs.Runtime},
+		{"python syntax", "python", "SyntaxError: invalid syntax", exit, errs.Build},
+		{"docker failure", "go", "", os.ErrNotExist, errs.Runtime},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := errs.KindOf(classifyRunError(c.language, "run code", c.output, c.err))
+			if got != c.expect {
+				t.Errorf("expect %v, got %v", c.expect, got)
+			}
+		})
+	}
+	if classifyRunError("go", "run code", "", nil) != nil {
+		t.Errorf("nil error should stay nil")
+	}
+}
+
+func TestClassifyCheckError(t *testing.T) {
+	exit := &container.ExitError{Code: 1}
+	cases := []struct {
+		name     string
+		language string
+		output   string
+		expect   errs.Kind
+	}{
+		{"go build", "go", "# example.com/pkg\npkg/a.go:3:2: undefined: foo", errs.Build},
+		{"go test build", "go", "FAIL\texample.com/pkg [build failed]", errs.Build},
+		{"go test failure", "go", "--- FAIL: TestAdd (0.00s)\n    a_test.go:8: got 1\nFAIL", errs.TestFailed},
+		{"python syntax", "python", "***   File \"calc.py\", line 2\nSyntaxError: invalid syntax", errs.Build},
+		{"python test failure", "python", "1 failed in 0.01s", errs.TestFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := errs.KindOf(classifyCheckError(c.language, c.output, exit)); got != c.expect {
+				t.Errorf("expect %v, got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestWriteFilesRejectsUnsafePaths(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go": "package main\nfunc main(){}",
+		"util.go": "package ../../evil\nfunc Foo(){}",
+	}
+	_, _, err := WriteFilesToDirAndCollectMain(files, dir, "go")
+	var rejected *workspace.RejectedError
+	if !errors.As(err, &rejected) || len(rejected.Files) != 1 || rejected.Files[0].Path != "util.go" {
+		t.Fatalf("expect util.go to be rejected, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "main.go")); !os.IsNotExist(err) {
+		t.Errorf("no file should be written when any file is rejected")
+	}
+
+	_, _, err = WriteFilesToDirAndCollectMain(map[string]string{"../../.bashrc": "rm -rf /"}, dir, "python")
+	if !errors.As(err, &rejected) {
+		t.Fatalf("expect traversal to be rejected, got %v", err)
+	}
+	v := NewWatcher().Judge(context.Background(), "", errs.E(errs.Extract, "write files", err))
+	if !v.Retry || len(v.Rejected) != 1 || !strings.Contains(NewWatcher().Feedback(v, ""), "../../.bashrc") {
+		t.Errorf("watcher should report rejected files, got %+v", v)
+	}
+}
+
+func TestParseReview(t *testing.T) {
+	r, err := ParseReview("审查结果:\n```json\n{\"decision\": \"request_changes\", \"summary\": \"越界\", \"findings\": [{\"severity\": \"high\", \"file\": \"main.go\", \"line\": 3, \"message\": \"index out of range\", \"suggestion\": \"check len\"}]}\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Approved() || len(r.Findings) != 1 || r.Findings[0].Line != 3 {
+		t.Errorf("unexpected review %+v", r)
+	}
+	if r, err := ParseReview(`{"findings": [{"severity": "HIGH", "file": "a.py", "message": "x"}]}`); err != nil || r.Approved() {
+		t.Errorf("high finding without decision should request changes: %+v %v", r, err)
+	}
+	if _, err := ParseReview("looks good"); err == nil {
+		t.Error("expect error without json")
+	}
+	if _, err := ParseReview(`{"decision": "maybe"}`); err == nil {
+		t.Error("expect error for unknown decision")
+	}
+}
+
+func TestStitch(t *testing.T) {
+	for _, tc := range []struct{ prev, next, want string }{
+		{"", "abc", "abc"},
+		// 正确地从截断处续写
+		{"```go\nfunc main() {\n\tfmt.Pri", "ntln(1)\n}\n```", "```go\nfunc main() {\n\tfmt.Println(1)\n}\n```"},
+		// 重新打开代码块并重复了最后半行
+		{"```go\nfunc main() {\n\tfmt.Pri", "```go\n\tfmt.Println(1)\n}\n```", "```go\nfunc main() {\n\tfmt.Println(1)\n}\n```"},
+		// 重复了已输出的最后几行
+		{"```python\ndef add(a, b):\n    return a + b\n", "def add(a, b):\n    return a + b\n\nprint(add(1, 2))\n```", "```python\ndef add(a, b):\n    return a + b\n\nprint(add(1, 2))\n```"},
+		// 较短的空白与括号重叠不视为重复
+		{"```go\n\t}\n", "\t}\n}\n```", "```go\n\t}\n\t}\n}\n```"},
+	} {
+		if got := stitch(tc.prev, tc.next); got != tc.want {
+			t.Errorf("stitch(%q, %q) = %q, want %q", tc.prev, tc.next, got, tc.want)
+		}
+	}
+}
+
+// truncatingProvider 依次返回 parts，除最后一段外都以截断结束
+type truncatingProvider struct {
+	parts    []string
+	requests []llm.Request
+}
+
+func (p *truncatingProvider) Complete(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	p.requests = append(p.requests, req)
+	resp := &llm.Response{Content: p.parts[0], FinishReason: llm.FinishLength}
+	if p.parts = p.parts[1:]; len(p.parts) == 0 {
+		resp.FinishReason = llm.FinishStop
+		return resp, nil
+	}
+	return nil, &llm.CompletionError{Condition: llm.Truncated, Response: resp}
+}
+
+func TestCompleteContinuesTruncated(t *testing.T) {
+	provider := &truncatingProvider{parts: []string{"```python\nprint(", "```python\nprint(1)\n", "print(2)\n```"}}
+	g := &Generator{LLM: provider, Schema: llm.CodeSchema}
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: "system"}, {Role: llm.RoleUser, Content: "print"}}
+	content, err := g.Complete(context.Background(), "m", messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "```python\nprint(1)\nprint(2)\n```" {
+		t.Errorf("unexpected stitched content %q", content)
+	}
+	last := provider.requests[2]
+	if len(provider.requests) != 3 || len(last.Messages) != 4 || last.Schema != nil {
+		t.Fatalf("unexpected continuation request %+v", last)
+	}
+	if last.Messages[2].Content != "```python\nprint(1)\n" || !strings.Contains(last.Messages[3].Content, "继续") {
+		t.Errorf("continuation should carry the partial reply, got %+v", last.Messages[2:])
+	}
+	if len(messages) != 2 {
+		t.Errorf("caller messages modified: %+v", messages)
+	}
+
+	// 续写次数用完仍被截断时返回截断错误
+	provider = &truncatingProvider{parts: []string{"a", "b", "c", "d", "e", "f"}}
+	g.LLM = provider
+	_, err = g.Complete(context.Background(), "m", messages)
+	var incomplete *llm.CompletionError
+	if !errors.As(err, &incomplete) || incomplete.Condition != llm.Truncated || errs.KindOf(err) != errs.LLM || len(provider.requests) != MaxContinuations+1 {
+		t.Errorf("expect truncated llm error after %d requests, got %v after %d", MaxContinuations+1, err, len(provider.requests))
+	}
+}
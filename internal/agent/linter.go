@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lint"
+)
+
+// Linter 质量门禁：在语言容器中自动修复格式并运行 go vet 等静态检查
+type Linter struct {
+	Runtime   container.Runtime
+	Languages *lang.Registry
+	// Linters 额外运行的检查工具，如 staticcheck、golangci-lint
+	Linters []string
+}
+
+func NewLinter(runtime container.Runtime) *Linter {
+	return &Linter{Runtime: runtime, Languages: lang.Default()}
+}
+
+// Lint 格式化 files（相对 workDir 的路径）并检查 workDir 中的代码，只报告 files 中的问题；
+// 语言不支持质量门禁时返回空报告
+func (l *Linter) Lint(ctx context.Context, language, workDir string, files []string, targetDir string) (*lint.Report, error) {
+	lg, err := lookupLanguage(l.Languages, language)
+	if err != nil {
+		return nil, err
+	}
+	if lg.LintCmd == nil || len(files) == 0 {
+		return &lint.Report{}, nil
+	}
+	if l.Runtime == nil {
+		return nil, errs.Errorf(errs.Runtime, "lint", "container runtime not initialized")
+	}
+	out, err := l.Runtime.Run(ctx, container.RunSpec{
+		Image:    lg.Image,
+		Cmd:      lg.LintCmd(files, l.Linters),
+		HostDir:  workDir,
+		MountDir: targetDir,
+	})
+	if err != nil {
+		// 检查命令总是以 0 退出，失败说明容器本身出错
+		return nil, errs.E(errs.Runtime, "lint", err)
+	}
+	report := lint.Parse(out)
+	report.Issues = lint.Filter(report.Issues, files)
+	return report, nil
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/mcp"
+)
+
+// MCPTools 把多个外部 MCP 服务的工具合并提供给模型，工具名为 <服务名>__<工具名>
+type MCPTools struct {
+	clients []*mcp.Client
+	tools   []llm.Tool
+	routes  map[string]mcpRoute
+	status  []mcp.ServerStatus
+}
+
+type mcpRoute struct {
+	client *mcp.Client
+	tool   string
+}
+
+// ConnectMCP 连接 servers 中未禁用的服务并按 Tools 过滤工具；单个服务失败只记录在 Status 中
+func ConnectMCP(ctx context.Context, servers map[string]mcp.ServerConfig) *MCPTools {
+	ts := &MCPTools{routes: make(map[string]mcpRoute)}
+	for _, name := range mcp.ServerNames(servers) {
+		cfg := servers[name]
+		if cfg.Disabled {
+			continue
+		}
+		status := mcp.ServerStatus{Name: name}
+		client, err := mcp.Connect(ctx, name, cfg)
+		if err != nil {
+			status.Error = err.Error()
+			ts.status = append(ts.status, status)
+			continue
+		}
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			client.Close()
+			status.Error = "list tools: " + err.Error()
+			ts.status = append(ts.status, status)
+			continue
+		}
+		ts.clients = append(ts.clients, client)
+		for _, tool := range tools {
+			if len(cfg.Tools) > 0 && !slices.Contains(cfg.Tools, tool.Name) {
+				continue
+			}
+			exposed := mcp.ToolName(name, tool.Name)
+			if _, dup := ts.routes[exposed]; dup {
+				continue
+			}
+			ts.routes[exposed] = mcpRoute{client: client, tool: tool.Name}
+			ts.tools = append(ts.tools, llm.Tool{Name: exposed, Description: tool.Description, Parameters: tool.InputSchema})
+			status.Tools = append(status.Tools, exposed)
+		}
+		ts.status = append(ts.status, status)
+	}
+	return ts
+}
+
+// Status 返回每个服务的连接结果
+func (ts *MCPTools) Status() []mcp.ServerStatus {
+	return ts.status
+}
+
+// Tools 返回提供给模型的工具
+func (ts *MCPTools) Tools() []llm.Tool {
+	return ts.tools
+}
+
+// Call 调用工具，服务返回的错误与 isError 结果都以文本返回给模型，只有 ctx 取消时返回错误
+func (ts *MCPTools) Call(ctx context.Context, call llm.ToolCall) (string, error) {
+	r, ok := ts.routes[call.Name]
+	if !ok {
+		return "error: unknown tool " + call.Name, nil
+	}
+	res, err := r.client.CallTool(ctx, r.tool, json.RawMessage(call.Arguments))
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return "error: " + err.Error(), nil
+	}
+	text := res.Text()
+	if res.IsError {
+		text = strings.TrimSpace("error: " + text)
+	}
+	return text, nil
+}
+
+// Close 断开全部服务
+func (ts *MCPTools) Close() error {
+	for _, c := range ts.clients {
+		c.Close()
+	}
+	return nil
+}
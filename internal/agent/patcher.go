@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/patch"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// MaxPatchFiles 未指定文件时自动收集的最大文件数
+const MaxPatchFiles = 50
+
+// Patcher 修改已有代码：把文件当前内容交给模型，应用模型返回的补丁并在容器中校验
+type Patcher struct {
+	LLM       llm.Provider
+	Runtime   container.Runtime
+	Languages *lang.Registry
+	Options   patch.Options
+}
+
+func NewPatcher(provider llm.Provider, runtime container.Runtime) *Patcher {
+	return &Patcher{LLM: provider, Runtime: runtime, Languages: lang.Default(), Options: patch.DefaultOptions}
+}
+
+// LoadTargets 读取 workDir 中要交给模型的文件；paths 为空时收集该语言的全部源文件（跳过隐藏目录与 vendor）
+func (p *Patcher) LoadTargets(language, workDir string, paths []string) ([]workspace.File, error) {
+	l, err := lookupLanguage(p.Languages, language)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		ext := filepath.Ext(l.MainFile)
+		err := filepath.WalkDir(workDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if path != workDir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(name) == ext {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errs.E(errs.Usage, "load files", err)
+		}
+		if len(paths) > MaxPatchFiles {
+			return nil, errs.Errorf(errs.Usage, "load files", "found %d %s files in %s, please list the files to modify", len(paths), ext, workDir)
+		}
+	} else {
+		abs := make([]string, 0, len(paths))
+		for _, name := range paths {
+			path := name
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(workDir, path)
+			}
+			if rel, err := filepath.Rel(workDir, path); err != nil || !filepath.IsLocal(rel) {
+				return nil, errs.Errorf(errs.Usage, "load files", "%s is outside %s", name, workDir)
+			}
+			if _, err := os.Stat(path); err != nil {
+				return nil, errs.E(errs.Usage, "load files", err)
+			}
+			abs = append(abs, path)
+		}
+		paths = abs
+	}
+	if len(paths) == 0 {
+		return nil, errs.Errorf(errs.Usage, "load files", "no %s files found in %s", language, workDir)
+	}
+	return workspace.ReadFiles(workDir, paths, false), nil
+}
+
+// ApplyPatch 解析模型回复中的补丁并应用到 workDir，任一修改冲突时不改动任何文件
+func (p *Patcher) ApplyPatch(content, workDir string) (*patch.Result, error) {
+	pt, err := patch.Parse(content)
+	if err != nil {
+		return nil, errs.E(errs.Extract, "parse patch", err)
+	}
+	res, err := patch.Apply(workDir, pt, p.Options)
+	var conflict *patch.ConflictError
+	if errors.As(err, &conflict) {
+		return nil, errs.E(errs.Patch, "apply patch", err)
+	}
+	if err != nil {
+		return nil, errs.E(errs.Other, "apply patch", err)
+	}
+	if len(res.Changes) == 0 {
+		return nil, errs.Errorf(errs.Patch, "apply patch", "patch does not change any file")
+	}
+	return res, nil
+}
+
+// Check 在容器中编译 workDir 中的项目并运行全部测试
+func (p *Patcher) Check(ctx context.Context, language, workDir, targetDir string) (string, error) {
+	l, err := lookupLanguage(p.Languages, language)
+	if err != nil {
+		return "", err
+	}
+	if len(l.CheckCmd) == 0 {
+		return "", errs.Errorf(errs.Usage, "check patch", "language %s has no check command", language)
+	}
+	if p.Runtime == nil {
+		return "", errs.Errorf(errs.Runtime, "check patch", "container runtime not initialized")
+	}
+	out, err := p.Runtime.Run(ctx, container.RunSpec{
+		Image:    l.Image,
+		Cmd:      l.CheckCmd,
+		HostDir:  workDir,
+		MountDir: targetDir,
+	})
+	return out, classifyCheckError(language, out, err)
+}
+
+// classifyCheckError 区分编译失败与测试未通过
+func classifyCheckError(language, output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *container.ExitError
+	if !errors.As(err, &exitErr) {
+		return errs.E(errs.Runtime, "check patch", err)
+	}
+	if e := classifyTestError(language, output, err); errs.KindOf(e) == errs.Build {
+		return errs.E(errs.Build, "check patch", err)
+	}
+	if e := classifyRunError(language, "check patch", output, err); errs.KindOf(e) == errs.Build && !strings.Contains(output, "--- FAIL") {
+		return e
+	}
+	return errs.E(errs.TestFailed, "check patch", err)
+}
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+)
+
+// 审查结论
+const (
+	DecisionApprove        = "approve"
+	DecisionRequestChanges = "request_changes"
+)
+
+// ReviewFinding 审查发现的一个问题
+type ReviewFinding struct {
+	Severity   string `json:"severity"`
+	File       string `json:"file"`
+	Line       int    `json:"line,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (f ReviewFinding) String() string {
+	s := fmt.Sprintf("%s: [%s] %s", f.File, f.Severity, f.Message)
+	if f.Line > 0 {
+		s = fmt.Sprintf("%s:%d: [%s] %s", f.File, f.Line, f.Severity, f.Message)
+	}
+	if f.Suggestion != "" {
+		s += "；建议: " + f.Suggestion
+	}
+	return s
+}
+
+// Review Reviewer 对一次尝试的审查结论
+type Review struct {
+	Decision string          `json:"decision"`
+	Summary  string          `json:"summary,omitempty"`
+	Findings []ReviewFinding `json:"findings,omitempty"`
+	Model    string          `json:"model,omitempty"`
+}
+
+// Approved 审查是否通过
+func (r *Review) Approved() bool {
+	return r.Decision != DecisionRequestChanges
+}
+
+// ReviewError 审查要求修改代码
+type ReviewError struct {
+	Review *Review
+}
+
+func (e *ReviewError) Error() string {
+	parts := make([]string, len(e.Review.Findings))
+	for i, f := range e.Review.Findings {
+		parts[i] = f.String()
+	}
+	msg := "review requested changes"
+	if e.Review.Summary != "" {
+		msg += ": " + e.Review.Summary
+	}
+	if len(parts) > 0 {
+		msg += " (" + strings.Join(parts, "; ") + ")"
+	}
+	return msg
+}
+
+// Reviewer 审查型 Agent：在运行前根据原始需求审查 Coder 生成的代码
+type Reviewer struct {
+	LLM llm.Provider
+	// Prompts 审查使用的提示词模板，为 nil 时使用内置模板
+	Prompts *prompt.Set
+}
+
+func NewReviewer(provider llm.Provider) *Reviewer {
+	return &Reviewer{LLM: provider}
+}
+
+// Review 请求模型按 review 模板审查 d.Files 是否满足 d.Prompt，返回结构化的审查结论
+func (r *Reviewer) Review(ctx context.Context, model string, d prompt.Data) (*Review, error) {
+	messages, err := promptMessages(r.Prompts, prompt.ModeReview, d)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.LLM.Complete(ctx, llm.Request{Model: model, Messages: messages})
+	if err != nil {
+		return nil, errs.E(errs.LLM, "review code", err)
+	}
+	review, err := ParseReview(resp.Content)
+	if err != nil {
+		return nil, errs.E(errs.Extract, "review code", err)
+	}
+	review.Model = model
+	return review, nil
+}
+
+// ParseReview 从模型回复中解析审查结论，允许 JSON 被 markdown 代码块或其他文字包裹
+func ParseReview(content string) (*Review, error) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no json object in review response")
+	}
+	var review Review
+	if err := json.Unmarshal([]byte(content[start:end+1]), &review); err != nil {
+		return nil, fmt.Errorf("invalid review json: %w", err)
+	}
+	switch review.Decision = strings.ToLower(strings.TrimSpace(review.Decision)); review.Decision {
+	case DecisionApprove, DecisionRequestChanges:
+	case "":
+		// 没有给出结论时按是否存在高危问题判断
+		review.Decision = DecisionApprove
+		for _, f := range review.Findings {
+			if strings.EqualFold(f.Severity, "high") {
+				review.Decision = DecisionRequestChanges
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown review decision %q", review.Decision)
+	}
+	return &review, nil
+}
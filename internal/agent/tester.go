@@ -2,67 +2,130 @@ package agent
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
 	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
 )
 
 type Tester struct {
-	OpenAI *llm.OpenAIClient
-	Docker *container.DockerClient
+	LLM       llm.Provider
+	Runtime   container.Runtime
+	Languages *lang.Registry
+	Guard     Guard // 为 nil 时不检查
+	// Prompts GenerateTest 使用的提示词模板，为 nil 时使用内置模板
+	Prompts *prompt.Set
 }
 
-func NewTester(openai *llm.OpenAIClient, docker *container.DockerClient) *Tester {
-	return &Tester{OpenAI: openai, Docker: docker}
+func NewTester(provider llm.Provider, runtime container.Runtime) *Tester {
+	return &Tester{LLM: provider, Runtime: runtime, Languages: lang.Default()}
 }
 
-// GenerateTest 根据 prompt 生成单元测试代码（兼容单文件，返回主文件内容）
-func (t *Tester) GenerateTest(ctx context.Context, prompt, language, model string) (string, error) {
-	return t.OpenAI.RawChatCompletion(ctx, prompt, language, model)
+// GenerateTest 根据 prompt 生成单元测试代码，返回 LLM 原始响应
+func (t *Tester) GenerateTest(ctx context.Context, text, language, model string) (string, error) {
+	messages, err := promptMessages(t.Prompts, prompt.ModeTest, prompt.NewData(t.Languages, language, text))
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.LLM.Complete(ctx, llm.Request{Model: model, Messages: messages})
+	if err != nil {
+		return "", errs.E(errs.LLM, "generate test", err)
+	}
+	return resp.Content, nil
 }
 
-// GenerateTestAndRun 生成测试代码、写入文件并用 Docker 执行
-func (t *Tester) GenerateTestAndRun(ctx context.Context, prompt, language, model, workDir string) (string, error) {
-	testCode, err := t.OpenAI.RawChatCompletion(ctx, prompt, language, model)
+// GenerateTestAndWrite 生成测试代码并写入 workDir，返回 LLM 原始响应与测试文件路径
+func (t *Tester) GenerateTestAndWrite(ctx context.Context, prompt, language, model, workDir string) (string, string, error) {
+	if _, err := lookupLanguage(t.Languages, language); err != nil {
+		return "", "", err
+	}
+	content, err := t.GenerateTest(ctx, prompt, language, model)
+	if err != nil {
+		return "", "", err
+	}
+	filePath, err := t.WriteTestFile(content, language, workDir)
+	return content, filePath, err
+}
+
+// WriteTestFile 从 LLM 响应中提取测试代码写入 workDir，返回测试文件路径
+func (t *Tester) WriteTestFile(content, language, workDir string) (string, error) {
+	l, err := lookupLanguage(t.Languages, language)
 	if err != nil {
 		return "", err
 	}
-	fileName := "main_test.go"
-	if language == "python" {
-		fileName = "test_main.py"
+	var testCode string
+	if llm.IsStructured(content) {
+		out, err := llm.ParseCodeOutput(content)
+		if err != nil {
+			return "", errs.E(errs.Extract, "parse structured output", err)
+		}
+		// 结构化输出只取第一个文件，统一写入默认测试文件
+		testCode = out.Files[0].Content
+	} else {
+		testCode = llm.ExtractCodeFilesFromLLMResponse(content, l.TestFile)[l.TestFile]
+	}
+	if strings.TrimSpace(testCode) == "" {
+		return "", errs.Errorf(errs.Extract, "extract test", "no test code found in llm response")
+	}
+	filePath := filepath.Join(workDir, l.TestFile)
+	if t.Guard != nil {
+		if err := t.Guard(map[string]string{filePath: testCode}); err != nil {
+			return "", err
+		}
 	}
-	filePath := filepath.Join(workDir, fileName)
 	if err := os.WriteFile(filePath, []byte(testCode), 0644); err != nil {
+		return "", errs.E(errs.Other, "write test file", err)
+	}
+	return filePath, nil
+}
+
+// RunTest 在容器中执行测试（假定测试代码已写入 workDir/fileName）
+func (t *Tester) RunTest(ctx context.Context, language, workDir, fileName, targetDir string) (string, error) {
+	l, err := lookupLanguage(t.Languages, language)
+	if err != nil {
 		return "", err
 	}
-	image := "golang:1.24.0"
-	cmd := []string{"go", "test", "."}
-	if language == "python" {
-		image = "python:3.11"
-		cmd = []string{"pytest", fileName}
+	if t.Runtime == nil {
+		return "", errs.Errorf(errs.Runtime, "run test", "container runtime not initialized")
 	}
-	if t.Docker == nil {
-		return testCode, fmt.Errorf("docker client not initialized")
+	out, err := t.Runtime.Run(ctx, container.RunSpec{
+		Image:    l.Image,
+		Cmd:      l.TestCmd(fileName),
+		HostDir:  workDir,
+		MountDir: targetDir,
+	})
+	return out, classifyTestError(language, out, err)
+}
+
+// classifyTestError 区分测试编译失败与测试未通过
+func classifyTestError(language, output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *container.ExitError
+	if !errors.As(err, &exitErr) {
+		return errs.E(errs.Runtime, "run test", err)
+	}
+	if language == "go" && (strings.Contains(output, "[build failed]") || strings.Contains(output, "[setup failed]")) {
+		return errs.E(errs.Build, "run test", err)
 	}
-	// 这里应有挂载 workDir 到容器的逻辑，略作伪实现
-	_, err = t.Docker.RunContainer(ctx, image, cmd)
-	return testCode, err
+	if language == "python" && strings.Contains(output, "during collection") {
+		// pytest 收集阶段出错（语法/导入错误）
+		return errs.E(errs.Build, "run test", err)
+	}
+	return errs.E(errs.TestFailed, "run test", err)
 }
 
-// RunTestInDocker 仅执行 Docker 运行（假定测试代码已写入 workDir/fileName）
-func (t *Tester) RunTestInDocker(ctx context.Context, language, workDir, fileName string) (string, error) {
-	image := "golang:1.24.0"
-	cmd := []string{"go", "test", "."}
-	if language == "python" {
-		image = "python:3.11"
-		cmd = []string{"pytest", fileName}
-	}
-	if t.Docker == nil {
-		return "", fmt.Errorf("docker client not initialized")
-	}
-	// 这里应有挂载 workDir 到容器的逻辑，略作伪实现
-	return t.Docker.RunContainer(ctx, image, cmd)
+// TestFileName 返回内置语言的默认测试文件名
+func TestFileName(language string) string {
+	if l, ok := lang.Default().Get(language); ok {
+		return l.TestFile
+	}
+	return "main_test.go"
 }
@@ -0,0 +1,417 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// 工具名
+const (
+	ToolWriteFile  = "write_file"
+	ToolReadFile   = "read_file"
+	ToolListFiles  = "list_files"
+	ToolRunCommand = "run_command"
+	ToolRunTests   = "run_tests"
+)
+
+// ToolSet 一组可供模型调用的工具；Call 只在 ctx 取消时返回错误，工具本身的失败作为结果返回给模型
+type ToolSet interface {
+	Tools() []llm.Tool
+	Call(ctx context.Context, call llm.ToolCall) (string, error)
+}
+
+// ToolCallRecord 一次工具调用的记录，参数与输出超过 maxRecord 字节时截断
+type ToolCallRecord struct {
+	Name      string        `json:"name"`
+	Arguments string        `json:"arguments,omitempty"`
+	Output    string        `json:"output,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// maxRecord 工具调用记录中参数与输出保留的最大字节数
+const maxRecord = 2000
+
+func truncateRecord(s string) string {
+	if len(s) > maxRecord {
+		return s[:maxRecord] + "..."
+	}
+	return s
+}
+
+// Toolbox Coder 在工具调用模式下可用的沙箱工具：文件操作限制在 WorkDir 内，命令在语言容器中执行
+type Toolbox struct {
+	Runtime   container.Runtime
+	Languages *lang.Registry
+	Language  string
+	WorkDir   string
+	MountDir  string
+	Guard     Guard // 为 nil 时不检查
+	// Limits 写入文件的限制，零值时使用 workspace.DefaultLimits
+	Limits workspace.Limits
+	// Check 在执行命令前检查已写入的文件，返回错误时不执行并把错误告诉模型
+	Check func(files []workspace.File) error
+	// OnCall 每次工具调用完成后回调
+	OnCall func(call llm.ToolCall, output string)
+	// MaxOutput 返回给模型的最大输出字节数，0 表示 8000
+	MaxOutput int
+	// Extra 额外提供给模型的工具（如 MCP 服务的工具），与沙箱工具同名时被忽略
+	Extra ToolSet
+	// OnDelta 非空时以流式请求模型并回调增量文本
+	OnDelta func(delta string)
+
+	written map[string]string // 相对路径到内容
+}
+
+// LoopLimits 工具调用循环的限制，字段为 0 表示不限制
+type LoopLimits struct {
+	MaxSteps  int // 模型请求次数
+	MaxTokens int // 累计消耗的 token 数
+}
+
+// LoopResult 工具调用循环的结果
+type LoopResult struct {
+	Content string // 模型不再调用工具时的最终回复
+	Steps   int
+	Tokens  int
+	Calls   []ToolCallRecord
+}
+
+func stringParam(desc string) map[string]any {
+	return map[string]any{"type": "string", "description": desc}
+}
+
+func objectParams(props map[string]any, required ...string) map[string]any {
+	req := make([]any, len(required))
+	for i, r := range required {
+		req[i] = r
+	}
+	return map[string]any{"type": "object", "properties": props, "required": req, "additionalProperties": false}
+}
+
+// Tools 返回提供给模型的工具定义，包括 Extra 中的工具
+func (t *Toolbox) Tools() []llm.Tool {
+	tools := t.sandboxTools()
+	if t.Extra != nil {
+		for _, tool := range t.Extra.Tools() {
+			if !hasTool(tools, tool.Name) {
+				tools = append(tools, tool)
+			}
+		}
+	}
+	return tools
+}
+
+func hasTool(tools []llm.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Toolbox) sandboxTools() []llm.Tool {
+	return []llm.Tool{
+		{Name: ToolWriteFile, Description: "Create or overwrite a file in the working directory with the full content.",
+			Parameters: objectParams(map[string]any{"path": stringParam("path relative to the working directory"), "content": stringParam("full file content")}, "path", "content")},
+		{Name: ToolReadFile, Description: "Read a file from the working directory.",
+			Parameters: objectParams(map[string]any{"path": stringParam("path relative to the working directory")}, "path")},
+		{Name: ToolListFiles, Description: "List files in the working directory.",
+			Parameters: objectParams(map[string]any{})},
+		{Name: ToolRunCommand, Description: "Run a shell command in the language container with the working directory mounted; returns combined output and exit code.",
+			Parameters: objectParams(map[string]any{"command": stringParam("shell command, e.g. go run .")}, "command")},
+		{Name: ToolRunTests, Description: "Build the project and run all tests in the language container.",
+			Parameters: objectParams(map[string]any{})},
+	}
+}
+
+// Track 将已存在的文件记为本次写入（恢复会话时使用）
+func (t *Toolbox) Track(files []workspace.File) {
+	if t.written == nil {
+		t.written = make(map[string]string)
+	}
+	for _, f := range files {
+		t.written[filepath.ToSlash(filepath.Clean(f.Path))] = f.Content
+	}
+}
+
+// Files 返回模型写入的文件（按路径排序）；Go 根目录下的 main 包文件、Python 的默认主文件（不存在时为第一个 .py 文件）标记为主文件
+func (t *Toolbox) Files() []workspace.File {
+	paths := make([]string, 0, len(t.written))
+	for p := range t.written {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	l, _ := lookupLanguage(t.Languages, t.Language)
+	files := make([]workspace.File, len(paths))
+	mainIdx := -1
+	for i, p := range paths {
+		files[i] = workspace.File{Path: p, Content: t.written[p]}
+		if t.Language == "go" {
+			files[i].Main = !strings.Contains(p, "/") && strings.HasSuffix(p, ".go") && !strings.HasSuffix(p, "_test.go") &&
+				goPackage(files[i].Content) == "main"
+			continue
+		}
+		if l == nil || filepath.Ext(p) != filepath.Ext(l.MainFile) || strings.HasPrefix(filepath.Base(p), "test_") {
+			continue
+		}
+		// 默认主文件优先
+		if p == l.MainFile || mainIdx < 0 {
+			mainIdx = i
+		}
+	}
+	if mainIdx >= 0 {
+		files[mainIdx].Main = true
+	}
+	return files
+}
+
+// goPackage 返回 Go 源码的包名
+func goPackage(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		if strings.HasPrefix(line, "package ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "package "))
+		}
+	}
+	return ""
+}
+
+// Call 执行一次工具调用，返回交给模型的结果；工具本身的失败也作为结果返回，只有 ctx 取消时返回错误
+func (t *Toolbox) Call(ctx context.Context, call llm.ToolCall) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+		Command string `json:"command"`
+	}
+	if call.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return "error: invalid arguments: " + err.Error(), nil
+		}
+	}
+	var out string
+	var err error
+	switch call.Name {
+	case ToolWriteFile:
+		out, err = t.WriteFile(args.Path, args.Content)
+	case ToolReadFile:
+		out, err = t.readFile(args.Path)
+	case ToolListFiles:
+		out, err = t.listFiles()
+	case ToolRunCommand:
+		if strings.TrimSpace(args.Command) == "" {
+			err = fmt.Errorf("command is required")
+		} else {
+			out, err = t.run(ctx, []string{"sh", "-c", args.Command})
+		}
+	case ToolRunTests:
+		out, err = t.runTests(ctx)
+	default:
+		if t.Extra == nil || !hasTool(t.Extra.Tools(), call.Name) {
+			err = fmt.Errorf("unknown tool %q", call.Name)
+			break
+		}
+		out, err = t.Extra.Call(ctx, call)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		out = strings.TrimSpace(out + "\nerror: " + err.Error())
+	}
+	if max := t.maxOutput(); len(out) > max {
+		out = "...\n" + out[len(out)-max:]
+	}
+	if t.OnCall != nil {
+		t.OnCall(call, out)
+	}
+	return out, nil
+}
+
+func (t *Toolbox) maxOutput() int {
+	if t.MaxOutput > 0 {
+		return t.MaxOutput
+	}
+	return 8000
+}
+
+func (t *Toolbox) limits() workspace.Limits {
+	if t.Limits == (workspace.Limits{}) {
+		return workspace.DefaultLimits
+	}
+	return t.Limits
+}
+
+// WriteFile 将文件写入 WorkDir 并记录，路径、数量与大小按 Limits 校验
+func (t *Toolbox) WriteFile(name, content string) (string, error) {
+	path, err := workspace.SafePath(t.WorkDir, name)
+	if err != nil {
+		return "", err
+	}
+	rel := filepath.ToSlash(filepath.Clean(name))
+	limits := t.limits()
+	if limits.MaxFileSize > 0 && len(content) > limits.MaxFileSize {
+		return "", fmt.Errorf("%d bytes exceeds the %d byte limit", len(content), limits.MaxFileSize)
+	}
+	_, exists := t.written[rel]
+	if limits.MaxFiles > 0 && !exists && len(t.written) >= limits.MaxFiles {
+		return "", fmt.Errorf("more than %d files", limits.MaxFiles)
+	}
+	total := len(content)
+	for p, c := range t.written {
+		if p != rel {
+			total += len(c)
+		}
+	}
+	if limits.MaxTotalSize > 0 && total > limits.MaxTotalSize {
+		return "", fmt.Errorf("total size exceeds the %d byte limit", limits.MaxTotalSize)
+	}
+	if t.Guard != nil && !exists {
+		if err := t.Guard(map[string]string{path: content}); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	t.Track([]workspace.File{{Path: rel, Content: content}})
+	return fmt.Sprintf("wrote %s (%d bytes)", rel, len(content)), nil
+}
+
+func (t *Toolbox) readFile(name string) (string, error) {
+	path, err := workspace.SafePath(t.WorkDir, name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (t *Toolbox) listFiles() (string, error) {
+	var paths []string
+	err := filepath.WalkDir(t.WorkDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != t.WorkDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(t.WorkDir, path)
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if len(paths) == 0 && err == nil {
+		return "(no files)", nil
+	}
+	return strings.Join(paths, "\n"), err
+}
+
+// run 在语言容器中执行命令，执行前用 Check 检查已写入的文件
+func (t *Toolbox) run(ctx context.Context, cmd []string) (string, error) {
+	l, err := lookupLanguage(t.Languages, t.Language)
+	if err != nil {
+		return "", err
+	}
+	if t.Runtime == nil {
+		return "", fmt.Errorf("container runtime not initialized")
+	}
+	if t.Check != nil {
+		if err := t.Check(t.Files()); err != nil {
+			return "", fmt.Errorf("refused to run: %w", err)
+		}
+	}
+	out, err := t.Runtime.Run(ctx, container.RunSpec{Image: l.Image, Cmd: cmd, HostDir: t.WorkDir, MountDir: t.MountDir})
+	var exitErr *container.ExitError
+	if errors.As(err, &exitErr) {
+		return out + fmt.Sprintf("\n(exit code %d)", exitErr.Code), nil
+	}
+	if err == nil {
+		out += "\n(exit code 0)"
+	}
+	return out, err
+}
+
+func (t *Toolbox) runTests(ctx context.Context) (string, error) {
+	l, err := lookupLanguage(t.Languages, t.Language)
+	if err != nil {
+		return "", err
+	}
+	if len(l.CheckCmd) == 0 {
+		return "", fmt.Errorf("language %s has no test command", t.Language)
+	}
+	return t.run(ctx, l.CheckCmd)
+}
+
+// Loop 让模型反复调用 Toolbox 中的工具，见 RunTools
+func (t *Toolbox) Loop(ctx context.Context, provider llm.Provider, model string, conv *llm.Conversation, limits LoopLimits) (*LoopResult, error) {
+	return RunTools(ctx, provider, llm.Request{Model: model, OnDelta: t.OnDelta}, conv, t, limits)
+}
+
+// RunTools 以 req 的模型与输出格式请求模型并执行其调用的工具，直到模型给出不含工具调用的最终回复；
+// 超出步数或 token 限制时返回 LLM 错误。每一步的模型回复与工具结果都会追加到 conv
+func RunTools(ctx context.Context, provider llm.Provider, req llm.Request, conv *llm.Conversation, tools ToolSet, limits LoopLimits) (*LoopResult, error) {
+	res := &LoopResult{}
+	req.Tools = tools.Tools()
+	for {
+		if limits.MaxSteps > 0 && res.Steps >= limits.MaxSteps {
+			return res, errs.Errorf(errs.LLM, "tool loop", "step limit %d reached", limits.MaxSteps)
+		}
+		if limits.MaxTokens > 0 && res.Tokens >= limits.MaxTokens {
+			return res, errs.Errorf(errs.LLM, "tool loop", "token limit %d reached (used %d)", limits.MaxTokens, res.Tokens)
+		}
+		req.Messages = conv.Messages
+		resp, err := provider.Complete(ctx, req)
+		if err != nil {
+			return res, errs.E(errs.LLM, "tool loop", err)
+		}
+		res.Steps++
+		if tokens := resp.Usage.TotalTokens; tokens > 0 {
+			res.Tokens += tokens
+		} else {
+			// 服务未返回用量时估算
+			res.Tokens += conv.Tokens() + llm.EstimateTokens(resp.Content)
+		}
+		if len(resp.ToolCalls) == 0 {
+			conv.AddAssistant(resp.Content)
+			res.Content = resp.Content
+			return res, nil
+		}
+		conv.AddToolCalls(resp.Content, resp.ToolCalls)
+		for _, call := range resp.ToolCalls {
+			start := time.Now()
+			out, err := tools.Call(ctx, call)
+			if err != nil {
+				return res, err
+			}
+			res.Calls = append(res.Calls, ToolCallRecord{
+				Name:      call.Name,
+				Arguments: truncateRecord(call.Arguments),
+				Output:    truncateRecord(out),
+				Duration:  time.Since(start),
+			})
+			conv.AddTool(call.ID, out)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lint"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/security"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// Verdict Watcher 对一次尝试的判定
+type Verdict struct {
+	Passed bool   `json:"passed"`
+	Retry  bool   `json:"retry,omitempty"` // 未通过时是否值得让 Coder 重试
+	Kind   string `json:"kind,omitempty"`  // 失败阶段，对应 errs.Kind
+	Reason string `json:"reason,omitempty"`
+	// Rejected 因路径不安全或超出限制而被拒绝写入的文件
+	Rejected []workspace.Rejected `json:"rejected,omitempty"`
+	// Findings 未通过的安全检查结果
+	Findings []security.Finding `json:"findings,omitempty"`
+	// Issues 质量门禁发现的问题
+	Issues []lint.Issue `json:"issues,omitempty"`
+	// Review 审查要求修改的问题
+	Review []ReviewFinding `json:"review,omitempty"`
+}
+
+// Watcher 监督型 Agent：根据运行结果判定尝试是否成功，并为 Coder 生成修复反馈
+type Watcher struct {
+	// RetryTestFailures 为 true 时测试未通过也会重试（测试模式下测试失败可能是被测代码的问题）
+	RetryTestFailures bool
+	// MaxOutput 反馈中保留的最大输出字节数
+	MaxOutput int
+}
+
+func NewWatcher() *Watcher {
+	return &Watcher{RetryTestFailures: true, MaxOutput: 4000}
+}
+
+// Judge 判定一次尝试
+func (w *Watcher) Judge(ctx context.Context, output string, err error) Verdict {
+	if err == nil {
+		return Verdict{Passed: true}
+	}
+	kind := errs.KindOf(err)
+	v := Verdict{Kind: kind.String(), Reason: err.Error()}
+	var rejected *workspace.RejectedError
+	if errors.As(err, &rejected) {
+		v.Rejected = rejected.Files
+	}
+	var insecure *security.Error
+	if errors.As(err, &insecure) {
+		v.Findings = insecure.Findings
+	}
+	var issues *lint.Error
+	if errors.As(err, &issues) {
+		v.Issues = issues.Issues
+	}
+	var review *ReviewError
+	if errors.As(err, &review) {
+		v.Review = review.Review.Findings
+	}
+	switch {
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		// 用户中断，不重试
+	case kind == errs.Extract || kind == errs.Build || kind == errs.Runtime || kind == errs.Patch || kind == errs.Lint || kind == errs.Review:
+		v.Retry = true
+	case kind == errs.TestFailed:
+		v.Retry = w.RetryTestFailures
+	case kind == errs.Security:
+		// block 策略不重试，rewrite 策略让 Coder 重写
+		v.Retry = insecure != nil && insecure.Rewrite
+	}
+	return v
+}
+
+// Feedback 用内置的 fix 模板为失败的尝试生成发给 Coder 的修复提示
+func (w *Watcher) Feedback(v Verdict, output string) string {
+	text, _ := prompt.Default().Render(prompt.ModeFix, prompt.RoleUser, w.FixData(prompt.Data{}, v, output))
+	return text
+}
+
+// FixData 将判定与截断后的输出填入 fix 模板的变量
+func (w *Watcher) FixData(d prompt.Data, v Verdict, output string) prompt.Data {
+	if len(output) > w.MaxOutput && w.MaxOutput > 0 {
+		output = "...\n" + output[len(output)-w.MaxOutput:]
+	}
+	d.Kind, d.Reason, d.Output = v.Kind, v.Reason, strings.TrimSpace(output)
+	d.Details, d.Rejected = nil, nil
+	for _, r := range v.Rejected {
+		d.Rejected = append(d.Rejected, r.String())
+	}
+	switch v.Kind {
+	case errs.Security.String():
+		for _, f := range v.Findings {
+			d.Details = append(d.Details, f.String())
+		}
+	case errs.Lint.String():
+		for _, issue := range v.Issues {
+			d.Details = append(d.Details, issue.String())
+		}
+	case errs.Review.String():
+		for _, f := range v.Review {
+			d.Details = append(d.Details, f.String())
+		}
+	}
+	return d
+}
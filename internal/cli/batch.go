@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newBatchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "batch <tasks.jsonl>",
+		Short: "run gen/test/agent tasks from a JSONL file concurrently, writing one result per line",
+		Long: "Each line of the task file is a JSON object with mode, prompt, language, model and options\n" +
+			"(max_attempts, review, lint, structured), the same as POST /jobs of aca serve.\n" +
+			"Every task runs in its own directory under --workdir.",
+		Args: cobra.ExactArgs(1),
+		RunE: runBatch,
+	}
+	cmd.Flags().IntP("parallel", "j", 2, "number of tasks run concurrently")
+	cmd.Flags().StringP("output", "o", "-", "result JSONL file, - for stdout (logs then go to stderr)")
+	return cmd
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errs.E(errs.Usage, "read batch", err)
+	}
+	tasks, err := aca.ReadBatch(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	output, _ := cmd.Flags().GetString("output")
+	out := cmd.OutOrStdout()
+	var logs io.Writer
+	if output == "-" {
+		// 结果独占标准输出，日志与容器输出写到标准错误
+		logs = cmd.ErrOrStderr()
+	} else {
+		file, err := os.Create(output)
+		if err != nil {
+			return errs.E(errs.Usage, "create output", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	pipeline, cleanup, err := newPipeline(cmd, logs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log := logOutput(logs)
+	log.Info("批量运行", len(tasks), "个任务，并发", parallel)
+	enc := json.NewEncoder(out)
+	sum := pipeline.RunBatch(ctx, taskFromFlags(cmd), tasks, parallel, func(res aca.BatchResult) {
+		enc.Encode(res)
+		log.Info(fmt.Sprintf("第 %d 行: %s，%d 次尝试，%.1fs，%d tokens", res.Line, res.Status, res.Attempts, res.Duration.Seconds(), res.Tokens))
+	})
+	log.Info(fmt.Sprintf("共 %d 个任务: 通过 %d，失败 %d，取消 %d；耗时 %.1fs（任务累计 %.1fs），约 %d tokens，费用 $%.4f",
+		sum.Total, sum.Passed, sum.Failed, sum.Canceled, sum.Duration.Seconds(), sum.TaskTime.Seconds(), sum.Tokens, sum.Cost))
+	switch {
+	case ctx.Err() != nil:
+		return errs.E(errs.Other, "batch", ctx.Err())
+	case sum.Failed > 0:
+		return errs.Errorf(errs.Other, "batch", "%d of %d tasks failed", sum.Failed, sum.Total)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+const chatHelp = `可用命令:
+  /run          重新运行当前代码
+  /diff         查看最近一轮的代码变更
+  /files        列出当前生成的文件
+  /undo         撤销最近一轮
+  /save <dir>   将当前文件保存到 dir
+  /usage        查看会话的 token 用量与费用
+  /help         显示帮助
+  /exit         退出会话
+其他输入将作为新一轮需求发送给模型`
+
+func newChatCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "chat",
+		Short: "interactive session that keeps conversation history and re-runs code each turn",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pipeline, cleanup, err := newPipeline(cmd, nil)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			chat, err := pipeline.NewChat(taskFromFlags(cmd))
+			if err != nil {
+				return err
+			}
+			defer chat.Close()
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			err = runChat(ctx, chat, cmd.InOrStdin(), cmd.OutOrStdout())
+			if u := chat.Usage(); u.Calls > 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "会话用量:", u)
+			}
+			return err
+		},
+	}
+}
+
+// runChat 运行交互式会话，直到输入结束、/exit 或 ctx 取消
+func runChat(ctx context.Context, chat *aca.Chat, in io.Reader, out io.Writer) error {
+	fmt.Fprintln(out, "进入会话模式，工作目录:", chat.Task().WorkDir, "，输入 /help 查看命令")
+	if id := chat.SessionID(); id != "" {
+		fmt.Fprintln(out, "会话 ID:", id, "，可用 aca sessions resume", id, "继续")
+	}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(out, "aca> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return errs.E(errs.Other, "read input", scanner.Err())
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			res, err := chat.Send(ctx, line)
+			printChatFiles(out, res.Files)
+			if err != nil {
+				fmt.Fprintln(out, "[ERROR]", err)
+			}
+			continue
+		}
+		name, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch name {
+		case "/exit", "/quit":
+			return nil
+		case "/help":
+			fmt.Fprintln(out, chatHelp)
+		case "/usage":
+			fmt.Fprintln(out, chat.Usage())
+		case "/run":
+			if _, err := chat.Run(ctx); err != nil {
+				fmt.Fprintln(out, "[ERROR]", err)
+			}
+		case "/diff":
+			if d := chat.Diff(); d != "" {
+				fmt.Fprint(out, d)
+			} else {
+				fmt.Fprintln(out, "没有变更")
+			}
+		case "/files":
+			if files := chat.Files(); len(files) > 0 {
+				printChatFiles(out, files)
+			} else {
+				fmt.Fprintln(out, "尚未生成文件")
+			}
+		case "/undo":
+			if err := chat.Undo(); err != nil {
+				fmt.Fprintln(out, "[ERROR]", err)
+				continue
+			}
+			fmt.Fprintln(out, "已撤销最近一轮，剩余", len(chat.Turns()), "轮")
+		case "/save":
+			if arg == "" {
+				fmt.Fprintln(out, "[ERROR]", errs.Errorf(errs.Usage, "save chat", "用法: /save <dir>"))
+				continue
+			}
+			if err := chat.Save(arg); err != nil {
+				fmt.Fprintln(out, "[ERROR]", err)
+				continue
+			}
+			fmt.Fprintln(out, "已保存到", arg)
+		default:
+			fmt.Fprintln(out, "未知命令:", name, "，输入 /help 查看命令")
+		}
+	}
+}
+
+func printChatFiles(out io.Writer, files []aca.File) {
+	for _, f := range files {
+		mark := " "
+		if f.Main {
+			mark = "*"
+		}
+		fmt.Fprintf(out, "%s %s (%d bytes)\n", mark, f.Path, len(f.Content))
+	}
+}
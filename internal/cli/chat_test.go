@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+)
+
+type echoProvider struct{}
+
+func (echoProvider) Complete(ctx context.Context, req aca.CompletionRequest) (*aca.Completion, error) {
+	last := req.Messages[len(req.Messages)-1].Content
+	return &aca.Completion{Content: "```python\nprint(" + "'" + last + "'" + ")\n```"}, nil
+}
+
+type nopRuntime struct{ runs int }
+
+func (r *nopRuntime) Run(ctx context.Context, spec aca.RunSpec) (string, error) {
+	r.runs++
+	return "", nil
+}
+
+func TestRunChat(t *testing.T) {
+	rt := &nopRuntime{}
+	p, err := aca.New(aca.WithProvider(echoProvider{}), aca.WithRuntime(rt), aca.WithLogger(aca.DiscardLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat, err := p.NewChat(aca.Task{Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := strings.NewReader("hello\n/files\nagain\n/diff\n/run\n/undo\n/bogus\n/exit\n")
+	var out bytes.Buffer
+	if err := runChat(context.Background(), chat, in, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"* main.py", "-print('hello')", "+print('again')", "已撤销最近一轮，剩余 1 轮", "未知命令: /bogus"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if rt.runs != 3 {
+		t.Errorf("expect 3 runs, got %d", rt.runs)
+	}
+}
@@ -2,118 +2,333 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strings"
+	"time"
 
-	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
-	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
-	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
-	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
 	"github.com/Zephyruston/Agent-Cat-Agent/internal/logger"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
 	"github.com/spf13/cobra"
 )
 
-func Execute() {
+// 进程退出码，按错误类别区分
+const (
+	ExitOK         = 0
+	ExitFailure    = 1
+	ExitUsage      = 2
+	ExitConfig     = 3
+	ExitLLM        = 4
+	ExitExtract    = 5
+	ExitBuild      = 6
+	ExitRuntime    = 7
+	ExitTestFailed = 8
+	ExitPatch      = 9
+	ExitSecurity   = 10
+	ExitLint       = 11
+	ExitReview     = 12
+	ExitBudget     = 13
+	// ExitCanceled 被 Ctrl-C 中断，与 shell 中 SIGINT 的惯例一致
+	ExitCanceled = 130
+)
+
+// Execute 解析命令行并执行，返回进程退出码
+func Execute() int {
 	rootCmd := &cobra.Command{
-		Use:   "aca",
-		Short: "Agent-Cat-Agent CLI",
-		Run:   runRoot,
+		Use:           "aca",
+		Short:         "Agent-Cat-Agent CLI",
+		RunE:          runRoot,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		// 必填参数的检查在 cobra 中不经过 FlagErrorFunc，提前检查以标记为 Usage 错误
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return errs.E(errs.Usage, "parse flags", cmd.ValidateRequiredFlags())
+		},
 	}
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return errs.E(errs.Usage, "parse flags", err)
+	})
 
-	rootCmd.Flags().StringP("mode", "m", "gen", "gen/test")
+	rootCmd.Flags().StringP("mode", "m", "gen", "gen/test/patch/agent")
 	rootCmd.Flags().StringP("prompt", "p", "", "prompt for code or test generation")
-	rootCmd.Flags().StringP("language", "l", "go", "programming language (default: go)")
-	rootCmd.Flags().StringP("config", "c", "etc/config.yaml", "config file path")
-	rootCmd.Flags().String("workdir", "./tmp", "working directory")
-	rootCmd.Flags().String("mount", "/app", "container mount dir")
+	rootCmd.Flags().StringSlice("files", nil, "files to modify in patch mode, relative to --workdir (default: all source files)")
+	rootCmd.Flags().String("events", "", "jsonl: stream run events (tokens, files, container output, verdicts) to stdout as JSON lines; logs go to stderr")
+	rootCmd.PersistentFlags().StringP("language", "l", "go", "programming language (default: go)")
+	rootCmd.PersistentFlags().StringP("config", "c", "etc/config.yaml", "config file path")
+	rootCmd.PersistentFlags().String("workdir", "./tmp", "working directory")
+	rootCmd.PersistentFlags().String("mount", "/app", "container mount dir")
+	rootCmd.PersistentFlags().String("state-dir", aca.DefaultSessionDir(), "session state directory")
+	rootCmd.PersistentFlags().Bool("force", false, "allow overwriting existing files in the workdir")
+	rootCmd.PersistentFlags().Bool("in-place", false, "write into --workdir directly instead of a fresh per-run subdirectory")
+	rootCmd.PersistentFlags().Bool("git", false, "commit every attempt to an aca/<session> branch in the workdir's git repository")
+	rootCmd.PersistentFlags().Bool("lint", false, "format and vet generated code in the container before running it, feeding remaining issues back to the model")
+	rootCmd.PersistentFlags().StringSlice("linters", nil, "extra linters for --lint: staticcheck, golangci-lint")
+	rootCmd.PersistentFlags().Bool("structured", false, "ask the model for JSON-schema output instead of markdown code blocks")
+	rootCmd.PersistentFlags().Bool("review", false, "have a reviewer model critique generated code before running it")
+	rootCmd.PersistentFlags().String("review-model", "", "model used by --review (default: the generator model)")
+	rootCmd.PersistentFlags().String("mcp-config", "", "MCP servers (.cursor/mcp.json format) whose tools the model may call")
+	rootCmd.PersistentFlags().String("prompts", "", "directory of prompt templates (<mode>[.<language>].<role>.tmpl) overriding the built-in ones and prompts.dir in the config")
+	rootCmd.PersistentFlags().Int("budget-tokens", 0, "stop retrying once a session has used this many tokens (overrides budget.tokens in the config)")
+	rootCmd.PersistentFlags().Float64("budget-cost", 0, "stop retrying once a session's estimated cost in USD reaches this (overrides budget.cost in the config)")
+	rootCmd.PersistentFlags().String("review-mode", "", "gate: send requested changes back to the model; annotate: only record the review")
 	rootCmd.MarkFlagRequired("prompt")
+	rootCmd.AddCommand(newChatCmd(), newSessionsCmd(), newUndoCmd(), newGitCmd(), newRunsCmd(), newMCPCmd(), newServeCmd(), newBatchCmd(), newEvalCmd(), newPromptsCmd())
+	usageArgs(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err)
+		return exitCode(err)
+	}
+	return ExitOK
+}
+
+// usageArgs 将 cmd 及其子命令的位置参数校验错误标记为 Usage 类别
+func usageArgs(cmd *cobra.Command) {
+	validate := cmd.Args
+	if validate == nil {
+		validate = legacyArgs
+	}
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		return errs.E(errs.Usage, "parse args", validate(cmd, args))
+	}
+	for _, sub := range cmd.Commands() {
+		usageArgs(sub)
 	}
 }
 
-func runRoot(cmd *cobra.Command, args []string) {
+// legacyArgs 与 cobra 对未设置 Args 的命令的校验相同：有子命令的根命令不接受未知的子命令
+func legacyArgs(cmd *cobra.Command, args []string) error {
+	if cmd.HasSubCommands() && !cmd.HasParent() && len(args) > 0 {
+		return fmt.Errorf("unknown command %q for %q", args[0], cmd.CommandPath())
+	}
+	return nil
+}
+
+// exitCode 将错误类别映射为退出码，未分类的错误为 ExitFailure
+func exitCode(err error) int {
+	if errors.Is(err, context.Canceled) {
+		return ExitCanceled
+	}
+	switch errs.KindOf(err) {
+	case errs.Usage:
+		return ExitUsage
+	case errs.Config:
+		return ExitConfig
+	case errs.LLM:
+		return ExitLLM
+	case errs.Extract:
+		return ExitExtract
+	case errs.Build:
+		return ExitBuild
+	case errs.Runtime:
+		return ExitRuntime
+	case errs.TestFailed:
+		return ExitTestFailed
+	case errs.Patch:
+		return ExitPatch
+	case errs.Security:
+		return ExitSecurity
+	case errs.Lint:
+		return ExitLint
+	case errs.Review:
+		return ExitReview
+	case errs.Budget:
+		return ExitBudget
+	}
+	return ExitFailure
+}
+
+func runRoot(cmd *cobra.Command, args []string) error {
 	mode, _ := cmd.Flags().GetString("mode")
 	prompt, _ := cmd.Flags().GetString("prompt")
-	language, _ := cmd.Flags().GetString("language")
-	configPath, _ := cmd.Flags().GetString("config")
-	workDir, _ := cmd.Flags().GetString("workdir")
-	mountDir, _ := cmd.Flags().GetString("mount")
 
-	absWorkDir, err := filepath.Abs(workDir)
-	if err != nil {
-		fmt.Println("[ERROR] 解析工作目录绝对路径失败:", err)
-		os.Exit(1)
+	if mode != "gen" && mode != "test" && mode != "patch" && mode != "agent" {
+		return errs.Errorf(errs.Usage, "parse flags", "--mode 仅支持 gen/test/patch/agent")
 	}
-	os.MkdirAll(absWorkDir, 0755)
-	logger.Info("创建/检查工作目录:", absWorkDir)
-	cfg, err := config.LoadConfig(configPath)
+	if strings.TrimSpace(prompt) == "" {
+		return errs.Errorf(errs.Usage, "parse flags", "--prompt 不能为空")
+	}
+	events, flush, err := eventsOption(cmd)
 	if err != nil {
-		logger.Error("配置文件加载失败:", err)
-		os.Exit(1)
+		return err
+	}
+	defer flush()
+	var logs io.Writer
+	if len(events) > 0 {
+		// 事件独占标准输出
+		logs = cmd.ErrOrStderr()
 	}
-	ctx := context.Background()
-	openaiClient := llm.NewOpenAIClient(*cfg)
-	dockerClient, err := container.NewDockerClient()
+	pipeline, cleanup, err := newPipeline(cmd, logs, events...)
 	if err != nil {
-		fmt.Println("[ERROR] Docker 初始化失败:", err)
-		os.Exit(1)
+		return err
 	}
-	defer dockerClient.Close()
+	defer cleanup()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	task := taskFromFlags(cmd)
+	task.Prompt = prompt
 	switch mode {
-	case "gen":
-		runGen(ctx, openaiClient, dockerClient, prompt, language, cfg.Model, absWorkDir, mountDir)
 	case "test":
-		runTest(ctx, openaiClient, dockerClient, prompt, language, cfg.Model, absWorkDir, mountDir)
-	default:
-		fmt.Println("[ERROR] --mode 仅支持 gen/test")
-		os.Exit(1)
+		_, err = pipeline.Test(ctx, task)
+		return err
+	case "patch":
+		task.Files, _ = cmd.Flags().GetStringSlice("files")
+		_, err = pipeline.Patch(ctx, task)
+		return err
+	case "agent":
+		_, err = pipeline.Agent(ctx, task)
+		return err
 	}
+	_, err = pipeline.Generate(ctx, task)
+	return err
 }
 
-func runGen(ctx context.Context, openaiClient *llm.OpenAIClient, dockerClient *container.DockerClient, prompt, language, model, absWorkDir, mountDir string) {
-	generator := agent.NewGenerator(openaiClient, dockerClient)
-	logger.Info("请求 LLM 生成代码...")
-	content, _, err := generator.GenerateCodeAndWriteFiles(ctx, prompt, language, model, absWorkDir, llm.ExtractCodeFilesFromLLMResponse)
-	mainFiles, depFiles, _ := agent.WriteFilesToDirAndCollectMain(llm.ExtractCodeFilesFromLLMResponse(content, agent.MainFileName(language)), absWorkDir, language)
+// newPipeline 根据 --config 创建使用 Docker 的流水线，cleanup 用于释放 Docker 客户端；
+// logs 非空时日志、MCP 连接信息与容器输出都写到 logs，否则写到标准输出/标准错误
+func newPipeline(cmd *cobra.Command, logs io.Writer, opts ...aca.Option) (*aca.Pipeline, func(), error) {
+	configPath, _ := cmd.Flags().GetString("config")
+	cfg, err := aca.LoadConfig(configPath)
 	if err != nil {
-		fmt.Println("[ERROR] 代码生成或写入失败:", err)
-		os.Exit(1)
+		return nil, nil, err
 	}
-	logger.Info("LLM 响应内容如下:\n====================\n", content, "\n====================")
-	logger.Info("用 Docker 执行代码...")
-	_, err = generator.RunCodeInDocker(ctx, language, absWorkDir, mainFiles, depFiles, mountDir)
+	if cmd.Flags().Changed("structured") {
+		cfg.StructuredOutput, _ = cmd.Flags().GetBool("structured")
+	}
+	if cmd.Flags().Changed("budget-tokens") {
+		cfg.Budget.Tokens, _ = cmd.Flags().GetInt("budget-tokens")
+	}
+	if cmd.Flags().Changed("budget-cost") {
+		cfg.Budget.Cost, _ = cmd.Flags().GetFloat64("budget-cost")
+	}
+	if err := cfg.Budget.Validate(); err != nil {
+		return nil, nil, errs.E(errs.Usage, "parse flags", err)
+	}
+	review, err := reviewOptions(cmd, cfg.Review)
 	if err != nil {
-		fmt.Println("[ERROR] Docker 执行失败:", err)
-		os.Exit(1)
+		return nil, nil, err
 	}
-	logger.Info("Docker 执行完成，详见上方输出")
+	servers := cfg.MCPServers
+	if path, _ := cmd.Flags().GetString("mcp-config"); path != "" {
+		// --mcp-config 中的服务与配置文件同名时优先
+		extra, err := aca.LoadMCPServers(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		servers = make(map[string]aca.MCPServerConfig, len(cfg.MCPServers)+len(extra))
+		for name, s := range cfg.MCPServers {
+			servers[name] = s
+		}
+		for name, s := range extra {
+			servers[name] = s
+		}
+	}
+	if dir, _ := cmd.Flags().GetString("prompts"); dir != "" {
+		cfg.Prompts.Dir = dir
+	}
+	prompts, err := aca.LoadPrompts(cfg.Prompts)
+	if err != nil {
+		return nil, nil, err
+	}
+	docker, err := aca.NewDockerRuntime()
+	if err != nil {
+		return nil, nil, err
+	}
+	docker.Output = logs
+	log := logOutput(logs)
+	tools := connectMCP(servers, log)
+	closeAll := func() {
+		docker.Close()
+		if tools != nil {
+			tools.Close()
+		}
+	}
+	if tools != nil {
+		opts = append([]aca.Option{aca.WithMCPTools(tools)}, opts...)
+	}
+	git, _ := cmd.Flags().GetBool("git")
+	inPlace, _ := cmd.Flags().GetBool("in-place")
+	opts = append([]aca.Option{aca.WithConfig(cfg), aca.WithLogger(log), aca.WithRuntime(docker), aca.WithSessionStore(sessionStore(cmd)),
+		aca.WithGit(git), aca.WithRunDirs(!inPlace), aca.WithQualityGate(qualityGate(cmd, cfg.Quality)),
+		aca.WithReview(review), aca.WithPrompts(prompts)}, opts...)
+	pipeline, err := aca.New(opts...)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	return pipeline, closeAll, nil
 }
 
-func runTest(ctx context.Context, openaiClient *llm.OpenAIClient, dockerClient *container.DockerClient, prompt, language, model, absWorkDir, mountDir string) {
-	tester := agent.NewTester(openaiClient, dockerClient)
-	logger.Info("请求 LLM 生成单元测试代码...")
-	testCode, err := tester.GenerateTest(ctx, prompt, language, model)
-	if err != nil || testCode == "" {
-		fmt.Println("[ERROR] 测试代码生成失败:", err)
-		os.Exit(1)
-	}
-	logger.Info("LLM 响应的测试代码如下:\n====================\n", testCode, "\n====================")
-	fileName := agent.MainFileName(language)
-	filePath := filepath.Join(absWorkDir, fileName)
-	if err := os.WriteFile(filePath, []byte(testCode), 0644); err != nil {
-		fmt.Println("[ERROR] 写入文件失败:", err)
-		os.Exit(1)
-	}
-	logger.Info("用 Docker 执行测试代码...")
-	_, err = tester.RunTestInDocker(ctx, language, absWorkDir, fileName)
-	if err != nil {
-		fmt.Println("[ERROR] Docker 执行失败:", err)
-		os.Exit(1)
+// logOutput 返回写到 w 的 Logger，w 为 nil 时返回输出到标准输出/标准错误的默认 Logger
+func logOutput(w io.Writer) aca.Logger {
+	if w == nil {
+		return logger.Std
+	}
+	return aca.NewLogger(w)
+}
+
+// connectMCP 连接外部 MCP 服务并用 log 输出各服务的可用工具，没有配置服务时返回 nil
+func connectMCP(servers map[string]aca.MCPServerConfig, log aca.Logger) *aca.MCPTools {
+	if len(servers) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tools := aca.ConnectMCP(ctx, servers)
+	for _, s := range tools.Status() {
+		if s.Error != "" {
+			log.Warning("MCP 服务", s.Name, "不可用:", s.Error)
+			continue
+		}
+		log.Info("MCP 服务", s.Name, "提供工具:", strings.Join(s.Tools, ", "))
+	}
+	return tools
+}
+
+// qualityGate 用 --lint/--linters 覆盖配置文件中的质量门禁
+func qualityGate(cmd *cobra.Command, gate aca.QualityGate) aca.QualityGate {
+	if cmd.Flags().Changed("lint") {
+		gate.Enabled, _ = cmd.Flags().GetBool("lint")
+		gate.Feedback = gate.Feedback || gate.Enabled
+	}
+	if cmd.Flags().Changed("linters") {
+		gate.Linters, _ = cmd.Flags().GetStringSlice("linters")
+	}
+	return gate
+}
+
+// reviewOptions 用 --review/--review-model/--review-mode 覆盖配置文件中的代码审查设置
+func reviewOptions(cmd *cobra.Command, opts aca.ReviewOptions) (aca.ReviewOptions, error) {
+	if cmd.Flags().Changed("review") {
+		opts.Enabled, _ = cmd.Flags().GetBool("review")
+	}
+	if model, _ := cmd.Flags().GetString("review-model"); model != "" {
+		opts.Model = model
+	}
+	if mode, _ := cmd.Flags().GetString("review-mode"); mode != "" {
+		if mode != aca.ReviewGate && mode != aca.ReviewAnnotate {
+			return opts, errs.Errorf(errs.Usage, "parse flags", "unknown review mode %q, want gate or annotate", mode)
+		}
+		opts.Mode = mode
 	}
-	logger.Info("Docker 执行完成，详见上方输出")
+	return opts, nil
+}
+
+// sessionStore 返回 --state-dir 指向的会话存储
+func sessionStore(cmd *cobra.Command) *aca.SessionStore {
+	dir, _ := cmd.Flags().GetString("state-dir")
+	return aca.NewSessionStore(dir)
+}
+
+// taskFromFlags 读取通用参数构造任务
+func taskFromFlags(cmd *cobra.Command) aca.Task {
+	language, _ := cmd.Flags().GetString("language")
+	workDir, _ := cmd.Flags().GetString("workdir")
+	mountDir, _ := cmd.Flags().GetString("mount")
+	force, _ := cmd.Flags().GetBool("force")
+	return aca.Task{Language: language, WorkDir: workDir, MountDir: mountDir, Force: force}
 }
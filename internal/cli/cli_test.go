@@ -1,48 +1,94 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"os"
 	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
 )
 
 func TestCliHelp(t *testing.T) {
 	os.Args = []string{"aca", "--help"}
-	defer func() { recover() }() // 捕获 os.Exit
-	Execute()
+	if code := Execute(); code != ExitOK {
+		t.Errorf("expect exit %d, got %d", ExitOK, code)
+	}
 }
 
 func TestCliGenArgs(t *testing.T) {
 	os.Args = []string{"aca", "--mode=gen", "--prompt=hello", "--language=go", "--config=internal/config/config.yaml", "--workdir=./tmp", "--mount=/app"}
-	defer func() { recover() }() // 捕获 os.Exit
-	Execute()
+	if code := Execute(); code != ExitConfig {
+		t.Errorf("expect exit %d, got %d", ExitConfig, code)
+	}
 }
 
 func TestCliTestArgs(t *testing.T) {
 	os.Args = []string{"aca", "--mode=test", "--prompt=hello", "--language=go", "--config=internal/config/config.yaml", "--workdir=./tmp", "--mount=/app"}
-	defer func() { recover() }() // 捕获 os.Exit
-	Execute()
+	if code := Execute(); code != ExitConfig {
+		t.Errorf("expect exit %d, got %d", ExitConfig, code)
+	}
 }
 
 func TestCliMissingPrompt(t *testing.T) {
 	os.Args = []string{"aca", "--mode=gen", "--language=go", "--config=etc/config.yaml"}
-	defer func() { recover() }()
-	Execute()
+	if code := Execute(); code != ExitUsage {
+		t.Errorf("expect exit %d, got %d", ExitUsage, code)
+	}
 }
 
 func TestCliInvalidMode(t *testing.T) {
 	os.Args = []string{"aca", "--mode=foo", "--prompt=hello", "--language=go", "--config=etc/config.yaml"}
-	defer func() { recover() }()
-	Execute()
+	if code := Execute(); code != ExitUsage {
+		t.Errorf("expect exit %d, got %d", ExitUsage, code)
+	}
 }
 
 func TestCliUnknownFlag(t *testing.T) {
 	os.Args = []string{"aca", "--unknown", "--prompt=hello", "--mode=gen", "--language=go", "--config=etc/config.yaml"}
-	defer func() { recover() }()
-	Execute()
+	if code := Execute(); code != ExitUsage {
+		t.Errorf("expect exit %d, got %d", ExitUsage, code)
+	}
+}
+
+func TestCliBadArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"aca", "nope"},
+		{"aca", "sessions", "show"},
+		{"aca", "sessions", "list", "--nope"},
+	} {
+		os.Args = args
+		if code := Execute(); code != ExitUsage {
+			t.Errorf("%v: expect exit %d, got %d", args[1:], ExitUsage, code)
+		}
+	}
 }
 
 func TestCliEmptyPrompt(t *testing.T) {
 	os.Args = []string{"aca", "--mode=gen", "--prompt=", "--language=go", "--config=etc/config.yaml"}
-	defer func() { recover() }()
-	Execute()
+	if code := Execute(); code != ExitUsage {
+		t.Errorf("expect exit %d, got %d", ExitUsage, code)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := map[error]int{
+		errors.New("boom"):                             ExitFailure,
+		errs.E(errs.Usage, "x", errors.New("bad")):     ExitUsage,
+		errs.E(errs.LLM, "x", context.Canceled):        ExitCanceled,
+		errs.E(errs.Other, "x", errors.New("boom")):    ExitFailure,
+		errs.E(errs.LLM, "x", errors.New("boom")):      ExitLLM,
+		errs.E(errs.Build, "x", errors.New("boom")):    ExitBuild,
+		errs.E(errs.TestFailed, "x", errors.New("no")): ExitTestFailed,
+		errs.E(errs.Patch, "x", errors.New("no")):      ExitPatch,
+		errs.E(errs.Security, "x", errors.New("no")):   ExitSecurity,
+		errs.E(errs.Lint, "x", errors.New("no")):       ExitLint,
+		errs.E(errs.Review, "x", errors.New("no")):     ExitReview,
+		errs.E(errs.Budget, "x", errors.New("no")):     ExitBudget,
+	}
+	for err, want := range cases {
+		if got := exitCode(err); got != want {
+			t.Errorf("exitCode(%v) = %d, want %d", err, got, want)
+		}
+	}
 }
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newEvalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "eval <suite.yaml>",
+		Short: "benchmark models and prompts on a suite of problems with hidden reference tests",
+		Long: "Every variant (model + system prompt) solves each problem --samples times in its own directory\n" +
+			"under --workdir, then the problem's hidden tests run in the sandbox. The report compares\n" +
+			"pass@k, attempts to success, tokens and wall time per variant.",
+		Args: cobra.ExactArgs(1),
+		RunE: runEval,
+	}
+	cmd.Flags().IntP("parallel", "j", 2, "number of samples run concurrently")
+	cmd.Flags().StringSlice("models", nil, "compare these models, overriding the models of the suite's variants")
+	cmd.Flags().Int("samples", 0, "samples per problem and variant, overriding the suite")
+	cmd.Flags().String("json", "", "write the JSON report to this file")
+	cmd.Flags().StringP("output", "o", "-", "Markdown report file, - for stdout (logs then go to stderr)")
+	return cmd
+}
+
+func runEval(cmd *cobra.Command, args []string) error {
+	suite, err := aca.LoadEvalSuite(args[0])
+	if err != nil {
+		return err
+	}
+	if models, _ := cmd.Flags().GetStringSlice("models"); len(models) > 0 {
+		suite.Variants = crossModels(suite.Variants, models)
+	}
+	if n, _ := cmd.Flags().GetInt("samples"); n > 0 {
+		suite.Samples = n
+	}
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	output, _ := cmd.Flags().GetString("output")
+	jsonPath, _ := cmd.Flags().GetString("json")
+	var logs io.Writer
+	if output == "-" {
+		// 报告独占标准输出，日志与容器输出写到标准错误
+		logs = cmd.ErrOrStderr()
+	}
+	pipeline, cleanup, err := newPipeline(cmd, logs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log := logOutput(logs)
+	log.Info(fmt.Sprintf("评测套件 %s: %d 个题目，并发 %d", suite.Name, len(suite.Problems), parallel))
+	report, err := pipeline.RunEval(ctx, taskFromFlags(cmd), suite, parallel, func(variant, problem string, s aca.EvalSample) {
+		status := "通过"
+		if !s.Passed {
+			status = "失败"
+		}
+		log.Info(fmt.Sprintf("%s / %s: %s，%d 次尝试，%.1fs，%d tokens", variant, problem, status, s.Attempts, s.Duration.Seconds(), s.Tokens))
+	})
+	if report == nil {
+		return err
+	}
+	if jsonPath != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if werr := os.WriteFile(jsonPath, append(data, '\n'), 0644); werr != nil {
+			return errs.E(errs.Usage, "write report", werr)
+		}
+	}
+	if output == "-" {
+		fmt.Fprint(cmd.OutOrStdout(), report.Markdown())
+	} else if werr := os.WriteFile(output, []byte(report.Markdown()), 0644); werr != nil {
+		return errs.E(errs.Usage, "write report", werr)
+	}
+	return errs.E(errs.Other, "eval", err)
+}
+
+// crossModels 将每个变体与每个模型组合；套件没有变体时每个模型一个变体
+func crossModels(variants []aca.EvalVariant, models []string) []aca.EvalVariant {
+	if len(variants) == 0 {
+		variants = []aca.EvalVariant{{}}
+	}
+	var out []aca.EvalVariant
+	for _, v := range variants {
+		for _, m := range models {
+			name := m
+			if v.Name != "" {
+				name = v.Name + "/" + m
+			}
+			out = append(out, aca.EvalVariant{Name: name, Model: m, System: v.System})
+		}
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"encoding/json"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+// eventsOption 按 --events 返回发布事件的 Pipeline 选项与结束时调用的 flush；
+// jsonl 时事件逐行以 JSON 写到命令的标准输出，调用方应将日志与容器输出写到标准错误
+func eventsOption(cmd *cobra.Command) ([]aca.Option, func(), error) {
+	format, _ := cmd.Flags().GetString("events")
+	switch format {
+	case "":
+		return nil, func() {}, nil
+	case "jsonl":
+	default:
+		return nil, nil, errs.Errorf(errs.Usage, "parse flags", "unknown --events format %q, want jsonl", format)
+	}
+	out := cmd.OutOrStdout()
+	bus := aca.NewEventBus(0)
+	ch, _ := bus.Subscribe(0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		enc := json.NewEncoder(out)
+		for e := range ch {
+			enc.Encode(e)
+		}
+	}()
+	return []aca.Option{aca.WithEvents(bus)}, func() {
+		bus.Close()
+		<-done
+	}, nil
+}
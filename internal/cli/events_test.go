@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+type goProvider struct{}
+
+func (goProvider) Complete(ctx context.Context, req aca.CompletionRequest) (*aca.Completion, error) {
+	return &aca.Completion{Content: "```go\npackage main\n\nfunc main() { fmt.Println(1) }\n```"}, nil
+}
+
+// --events=jsonl 时标准输出只包含事件，宿主机 goimports 的输出不能混入
+func TestEventsStdoutOnlyJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake goimports is a shell script")
+	}
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "goimports"), []byte("#!/bin/sh\necho goimports noise\n"), 0755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("events", "jsonl", "")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	events, flush, err := eventsOption(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := aca.New(append(events, aca.WithProvider(goProvider{}), aca.WithRuntime(&nopRuntime{}), aca.WithLogger(aca.NewLogger(io.Discard)))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Generate(context.Background(), aca.Task{Prompt: "print 1", Language: "go", WorkDir: t.TempDir()})
+	flush()
+	w.Close()
+	leaked, _ := io.ReadAll(r)
+	if len(leaked) > 0 {
+		t.Errorf("process stdout should stay empty, got %q", leaked)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatal("expect events on the command output")
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("non-JSON line in event stream: %q", line)
+		}
+	}
+}
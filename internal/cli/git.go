@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newUndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo <id>",
+		Short: "restore the workdir of a git-enabled session to a previous attempt",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store := sessionStore(cmd)
+			to, _ := cmd.Flags().GetInt("to")
+			if to < 0 {
+				sess, err := store.Load(args[0])
+				if err != nil {
+					return errs.E(errs.Usage, "undo session", err)
+				}
+				to = max(len(sess.Attempts)-1, 0)
+			}
+			commit, err := aca.UndoSession(store, args[0], to)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "已恢复到第 %d 次尝试 (%s)\n", to, shortHash(commit))
+			return nil
+		},
+	}
+	cmd.Flags().Int("to", -1, "attempt number to restore, 0 for the state before the session (default: previous attempt)")
+	return cmd
+}
+
+func newGitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git",
+		Short: "squash or export the branch of a git-enabled session",
+	}
+	squashCmd := &cobra.Command{
+		Use:   "squash <id>",
+		Short: "squash all commits on the session branch into one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			message, _ := cmd.Flags().GetString("message")
+			commit, err := aca.SquashSession(sessionStore(cmd), args[0], message)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "已合并为提交", shortHash(commit))
+			return nil
+		},
+	}
+	squashCmd.Flags().StringP("message", "m", "", "commit message (default: the session prompt)")
+	formatCmd := &cobra.Command{
+		Use:   "format-patch <id>",
+		Short: "export the commits on the session branch with git format-patch",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, _ := cmd.Flags().GetString("output")
+			files, err := aca.FormatSessionPatch(sessionStore(cmd), args[0], dir)
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				fmt.Fprintln(cmd.OutOrStdout(), f)
+			}
+			return nil
+		},
+	}
+	formatCmd.Flags().StringP("output", "o", ".", "output directory for patch files")
+	cmd.AddCommand(squashCmd, formatCmd)
+	return cmd
+}
+
+func shortHash(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
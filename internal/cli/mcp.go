@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+func newMCPCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Model Context Protocol integration",
+	}
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve generate_code, generate_tests and run_in_sandbox as an MCP server",
+		Args:  cobra.NoArgs,
+		RunE:  runMCPServe,
+	}
+	serveCmd.Flags().String("transport", "stdio", "stdio or http (Streamable HTTP at /mcp, SSE at /sse)")
+	serveCmd.Flags().String("addr", "127.0.0.1:8765", "listen address for --transport http")
+	serveCmd.Flags().String("token", "", "bearer token required by --transport http, mandatory on non-loopback addresses (defaults to $ACA_MCP_TOKEN)")
+	serveCmd.Flags().StringSlice("allow-origin", nil, "additional browser origins allowed by --transport http, e.g. https://example.com")
+	serveCmd.Flags().StringSlice("allow-host", nil, "additional Host names allowed by --transport http besides loopback addresses")
+	cmd.AddCommand(serveCmd)
+	return cmd
+}
+
+func runMCPServe(cmd *cobra.Command, args []string) error {
+	transport, _ := cmd.Flags().GetString("transport")
+	if transport != "stdio" && transport != "http" {
+		return errs.Errorf(errs.Usage, "parse flags", "unknown transport %q, want stdio or http", transport)
+	}
+	var logs io.Writer
+	if transport == "stdio" {
+		// stdio 传输独占标准输出，日志与容器输出写到标准错误
+		logs = cmd.ErrOrStderr()
+	}
+	pipeline, cleanup, err := newPipeline(cmd, logs)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server := pipeline.MCPServer(taskFromFlags(cmd))
+	if transport == "stdio" {
+		if err := server.ServeStdio(ctx, cmd.InOrStdin(), cmd.OutOrStdout()); err != nil && !errors.Is(err, context.Canceled) {
+			return errs.E(errs.Other, "serve mcp", err)
+		}
+		return nil
+	}
+	addr, _ := cmd.Flags().GetString("addr")
+	guard := httpguard.Options{}
+	guard.Token, _ = cmd.Flags().GetString("token")
+	if guard.Token == "" {
+		guard.Token = os.Getenv("ACA_MCP_TOKEN")
+	}
+	guard.Origins, _ = cmd.Flags().GetStringSlice("allow-origin")
+	guard.Hosts, _ = cmd.Flags().GetStringSlice("allow-host")
+	if err := guard.CheckListen(addr); err != nil {
+		return errs.E(errs.Usage, "serve mcp", err)
+	}
+	srv := &http.Server{Addr: addr, Handler: server.Handler(guard)}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	logger.Info("MCP 服务监听于 http://" + addr + "/mcp（SSE: /sse）")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errs.E(errs.Other, "serve mcp", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newPromptsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompts",
+		Short: "inspect the prompt templates, overridable with prompts in the config or --prompts",
+	}
+	showCmd := &cobra.Command{
+		Use:   "show [mode]...",
+		Short: "render the system and user prompts of modes (gen, test, fix, review, patch, agent, continue) for --language",
+		RunE:  runPromptsShow,
+	}
+	showCmd.Flags().String("prompt", "生成一个矩阵乘法", "requirement used to render the templates")
+	showCmd.Flags().StringSlice("files", nil, "files given to review/patch templates as file context")
+	showCmd.Flags().Bool("raw", false, "print the template sources instead of rendering them")
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list the templates and where each comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set, err := loadPrompts(cmd)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSOURCE")
+			for _, t := range set.Templates() {
+				fmt.Fprintf(w, "%s\t%s\n", t.Name, t.Source)
+			}
+			return errs.E(errs.Other, "list prompts", w.Flush())
+		},
+	}, showCmd)
+	return cmd
+}
+
+func runPromptsShow(cmd *cobra.Command, args []string) error {
+	set, err := loadPrompts(cmd)
+	if err != nil {
+		return err
+	}
+	modes := args
+	if len(modes) == 0 {
+		modes = prompt.Modes()
+	}
+	language, _ := cmd.Flags().GetString("language")
+	text, _ := cmd.Flags().GetString("prompt")
+	raw, _ := cmd.Flags().GetBool("raw")
+	d := prompt.NewData(aca.DefaultLanguages(), language, text)
+	d.Structured, _ = cmd.Flags().GetBool("structured")
+	if d.Files, err = promptFiles(cmd, d.MainFile); err != nil {
+		return err
+	}
+	// fix 模板使用一次示例失败
+	d.Attempt, d.Kind, d.Reason, d.Output = 1, errs.Build.String(), "build: container exited with status 1", "./main.go:3:1: syntax error"
+
+	out := cmd.OutOrStdout()
+	for _, mode := range modes {
+		if !slices.Contains(prompt.Modes(), mode) {
+			return errs.Errorf(errs.Usage, "show prompts", "unknown mode %q, want one of %s", mode, strings.Join(prompt.Modes(), ", "))
+		}
+		for _, role := range prompt.Roles(mode) {
+			t, _ := set.Resolve(mode, language, role)
+			fmt.Fprintf(out, "==== %s (%s) ====\n", t.Name, t.Source)
+			if raw {
+				fmt.Fprintln(out, strings.TrimRight(t.Text, "\n"))
+				continue
+			}
+			rendered, err := set.Render(mode, role, d)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, rendered)
+		}
+	}
+	return nil
+}
+
+// loadPrompts 加载配置文件与 --prompts 中的模板；未指定 --config 且默认配置文件不存在时只使用内置模板
+func loadPrompts(cmd *cobra.Command) (*aca.PromptSet, error) {
+	var cfg aca.PromptConfig
+	configPath, _ := cmd.Flags().GetString("config")
+	if _, err := os.Stat(configPath); err == nil || cmd.Flags().Changed("config") {
+		c, err := aca.LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg = c.Prompts
+	}
+	if dir, _ := cmd.Flags().GetString("prompts"); dir != "" {
+		cfg.Dir = dir
+	}
+	return aca.LoadPrompts(cfg)
+}
+
+// promptFiles 读取 --files 作为文件上下文，未指定时使用一个示例主文件
+func promptFiles(cmd *cobra.Command, mainFile string) ([]aca.File, error) {
+	paths, _ := cmd.Flags().GetStringSlice("files")
+	if len(paths) == 0 {
+		return []aca.File{{Path: mainFile, Content: "...\n"}}, nil
+	}
+	files := make([]aca.File, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errs.E(errs.Usage, "show prompts", err)
+		}
+		files = append(files, aca.File{Path: filepath.ToSlash(path), Content: string(data)})
+	}
+	return files, nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newRunsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runs",
+		Short: "manage per-run directories under --workdir",
+	}
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "delete (or archive) old run directories",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, _ := cmd.Flags().GetString("workdir")
+			olderThan, _ := cmd.Flags().GetDuration("older-than")
+			archive, _ := cmd.Flags().GetString("archive")
+			cleaned, err := aca.CleanRuns(root, olderThan, archive)
+			for _, name := range cleaned {
+				if archive != "" {
+					fmt.Fprintln(cmd.OutOrStdout(), "已归档", name)
+				} else {
+					fmt.Fprintln(cmd.OutOrStdout(), "已删除", name)
+				}
+			}
+			return err
+		},
+	}
+	cleanCmd.Flags().Duration("older-than", 7*24*time.Hour, "only clean runs last modified before this duration")
+	cleanCmd.Flags().String("archive", "", "archive each run as <dir>/<id>.tar.gz before deleting it")
+	cmd.AddCommand(cleanCmd)
+	return cmd
+}
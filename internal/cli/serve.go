@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/logger"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/server"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve a REST API and web dashboard that run gen/test jobs from an in-process queue",
+		Args:  cobra.NoArgs,
+		RunE:  runServe,
+	}
+	cmd.Flags().String("addr", "127.0.0.1:8080", "listen address")
+	cmd.Flags().Int("workers", 2, "number of jobs run concurrently")
+	cmd.Flags().Int("queue-size", 100, "maximum number of queued jobs")
+	cmd.Flags().String("token", "", "bearer token required by the API, mandatory on non-loopback addresses (defaults to server.token)")
+	return cmd
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	configPath, _ := cmd.Flags().GetString("config")
+	cfg, err := aca.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	// 命令行参数优先，未指定时使用配置文件中的值
+	addr, _ := cmd.Flags().GetString("addr")
+	workers, _ := cmd.Flags().GetInt("workers")
+	size, _ := cmd.Flags().GetInt("queue-size")
+	if !cmd.Flags().Changed("addr") && cfg.Server.Addr != "" {
+		addr = cfg.Server.Addr
+	}
+	if !cmd.Flags().Changed("workers") && cfg.Server.Workers > 0 {
+		workers = cfg.Server.Workers
+	}
+	if !cmd.Flags().Changed("queue-size") && cfg.Server.QueueSize > 0 {
+		size = cfg.Server.QueueSize
+	}
+	guard := httpguard.Options{Token: cfg.Server.Token, Origins: cfg.Server.AllowOrigins, Hosts: cfg.Server.AllowHosts}
+	if cmd.Flags().Changed("token") {
+		guard.Token, _ = cmd.Flags().GetString("token")
+	}
+	if err := guard.CheckListen(addr); err != nil {
+		return errs.E(errs.Usage, "serve", err)
+	}
+	pipeline, cleanup, err := newPipeline(cmd, nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	git, _ := cmd.Flags().GetBool("git")
+	inPlace, _ := cmd.Flags().GetBool("in-place")
+	if (git || inPlace) && workers > 1 {
+		logger.Warning("--git 或 --in-place 时任务共用同一个工作目录，将逐个执行")
+	}
+	queue := aca.NewJobQueue(pipeline, taskFromFlags(cmd), workers, size)
+	defer queue.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{Addr: addr, Handler: server.New(queue, sessionStore(cmd), guard)}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	logger.Info("HTTP API 与监控面板监听于 http://"+addr, "，", workers, "个 worker")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errs.E(errs.Other, "serve", err)
+	}
+	return nil
+}
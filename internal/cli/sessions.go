@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+	"github.com/spf13/cobra"
+)
+
+func newSessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "list, inspect, resume and delete saved sessions",
+	}
+	showCmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "show a session with its attempts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sess, err := sessionStore(cmd).Load(args[0])
+			if err != nil {
+				return errs.E(errs.Usage, "show session", err)
+			}
+			verbose, _ := cmd.Flags().GetBool("verbose")
+			printSession(cmd.OutOrStdout(), sess, verbose)
+			return nil
+		},
+	}
+	showCmd.Flags().BoolP("verbose", "v", false, "print responses, files and outputs of every attempt")
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list saved sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessions, err := sessionStore(cmd).List()
+			if err != nil {
+				return errs.E(errs.Other, "list sessions", err)
+			}
+			printSessions(cmd.OutOrStdout(), sessions)
+			return nil
+		},
+	}, showCmd, &cobra.Command{
+		Use:   "resume <id>",
+		Short: "continue an interrupted or failed session",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runResume,
+	}, &cobra.Command{
+		Use:   "delete <id>...",
+		Short: "delete sessions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store := sessionStore(cmd)
+			for _, id := range args {
+				if err := store.Delete(id); err != nil {
+					return errs.E(errs.Usage, "delete session", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), "已删除会话", id)
+			}
+			return nil
+		},
+	})
+	return cmd
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	sess, err := sessionStore(cmd).Load(args[0])
+	if err != nil {
+		return errs.E(errs.Usage, "resume session", err)
+	}
+	pipeline, cleanup, err := newPipeline(cmd, nil)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if sess.Mode == aca.ModeChat {
+		chat, err := pipeline.ResumeChat(sess.ID)
+		if err != nil {
+			return err
+		}
+		defer chat.Close()
+		return runChat(ctx, chat, cmd.InOrStdin(), cmd.OutOrStdout())
+	}
+	_, err = pipeline.Resume(ctx, sess.ID)
+	return err
+}
+
+func printSessions(out io.Writer, sessions []*aca.Session) {
+	if len(sessions) == 0 {
+		fmt.Fprintln(out, "没有保存的会话")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tMODE\tSTATUS\tATTEMPTS\tCREATED\tPROMPT")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", s.ID, s.Mode, s.Status, len(s.Attempts), s.CreatedAt.Format(time.DateTime), truncate(s.Prompt, 40))
+	}
+	w.Flush()
+}
+
+func printSession(out io.Writer, s *aca.Session, verbose bool) {
+	fmt.Fprintf(out, "ID:       %s\nMode:     %s\nStatus:   %s\nLanguage: %s\nModel:    %s\nWorkDir:  %s\nCreated:  %s\n",
+		s.ID, s.Mode, s.Status, s.Language, s.Model, s.WorkDir, s.CreatedAt.Format(time.DateTime))
+	if s.Prompt != "" {
+		fmt.Fprintf(out, "Prompt:   %s\n", s.Prompt)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(out, "Error:    %s\n", s.Error)
+	}
+	if s.Git != nil {
+		fmt.Fprintf(out, "Branch:   %s (from %s)\n", s.Git.Branch, shortHash(s.Git.Base))
+	}
+	if u := s.Usage; u.Calls > 0 {
+		fmt.Fprintf(out, "Usage:    %s\n", formatUsage(u))
+	}
+	for _, m := range s.MCP {
+		if m.Error != "" {
+			fmt.Fprintf(out, "MCP:      %s unavailable: %s\n", m.Name, m.Error)
+		} else {
+			fmt.Fprintf(out, "MCP:      %s [%s]\n", m.Name, strings.Join(m.Tools, ", "))
+		}
+	}
+	for _, a := range s.Attempts {
+		verdict := "passed"
+		if !a.Verdict.Passed {
+			verdict = "failed (" + a.Verdict.Kind + "): " + a.Verdict.Reason
+		}
+		fmt.Fprintf(out, "\n#%d %s  %s\n", a.Number, a.Duration.Round(time.Millisecond), verdict)
+		if a.Commit != "" {
+			fmt.Fprintf(out, "  commit %s\n", shortHash(a.Commit))
+		}
+		if s.Mode == aca.ModeChat {
+			fmt.Fprintf(out, "  > %s\n", truncate(a.Prompt, 80))
+		}
+		for _, f := range a.Files {
+			fmt.Fprintf(out, "  %s (%d bytes)\n", f.Path, len(f.Content))
+		}
+		if a.Usage.Calls > 0 {
+			fmt.Fprintf(out, "  usage %s\n", formatUsage(a.Usage))
+		}
+		for _, c := range a.ToolCalls {
+			fmt.Fprintf(out, "  tool %s %s (%s)\n", c.Name, truncate(c.Arguments, 60), c.Duration.Round(time.Millisecond))
+		}
+		if a.Review != nil {
+			fmt.Fprintf(out, "  review %s: %s\n", a.Review.Decision, a.Review.Summary)
+			for _, f := range a.Review.Findings {
+				fmt.Fprintf(out, "    %s\n", f)
+			}
+		}
+		if !verbose {
+			continue
+		}
+		fmt.Fprintf(out, "---- response ----\n%s\n", a.Response)
+		for _, f := range a.Files {
+			fmt.Fprintf(out, "---- %s ----\n%s\n", f.Path, f.Content)
+		}
+		if a.Output != "" {
+			fmt.Fprintf(out, "---- output ----\n%s\n", a.Output)
+		}
+	}
+}
+
+// formatUsage 输出调用次数、各类 token 数与费用，估算的用量以 ~ 标记
+func formatUsage(u aca.Usage) string {
+	s := fmt.Sprintf("%d calls, %d tokens (prompt %d", u.Calls, u.Total, u.Prompt)
+	if u.Cached > 0 {
+		s += fmt.Sprintf(", cached %d", u.Cached)
+	}
+	s += fmt.Sprintf(", completion %d)", u.Completion)
+	if u.Estimated {
+		s = "~" + s
+	}
+	if u.Cost > 0 {
+		s += fmt.Sprintf(", $%.4f", u.Cost)
+	}
+	return s
+}
+
+// truncate 将文本压成单行并截断到 n 个字符
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
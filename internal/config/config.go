@@ -1,9 +1,17 @@
 package config
 
 import (
-	"log"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lint"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/mcp"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/security"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/usage"
 	"gopkg.in/yaml.v3"
 )
 
@@ -11,18 +19,166 @@ type Config struct {
 	ApiKey  string `yaml:"api_key"`
 	BaseUrl string `yaml:"base_url"`
 	Model   string `yaml:"model"`
+	// ContextLimit 多轮会话的上下文 token 上限，超出时摘要较早的对话，0 表示不限制
+	ContextLimit int `yaml:"context_limit"`
+	// LLM 模型调用的超时、重试、限流与备用模型
+	LLM LLM `yaml:"llm"`
+	// MaxAttempts 每个任务的最大尝试次数，失败时将错误反馈给模型重试
+	MaxAttempts int `yaml:"max_attempts"`
+	// Security 运行前静态安全检查的策略
+	Security security.Policy `yaml:"security"`
+	// Quality 运行前在容器中格式化与静态检查代码的质量门禁
+	Quality lint.Gate `yaml:"quality"`
+	// Review 运行前由 Reviewer 审查代码
+	Review Review `yaml:"review"`
+	// StructuredOutput 要求模型按 JSON Schema 输出代码，代替从 markdown 代码块中提取
+	StructuredOutput bool `yaml:"structured_output"`
+	// Tools agent 模式下工具调用循环的限制
+	Tools Tools `yaml:"tools"`
+	// MCPConfig .cursor/mcp.json 格式的外部 MCP 服务配置文件，相对路径相对于本配置文件
+	MCPConfig string `yaml:"mcp_config"`
+	// MCPServers 生成代码时可供模型调用的外部 MCP 服务，与 MCPConfig 中的同名服务冲突时优先
+	MCPServers map[string]mcp.ServerConfig `yaml:"mcp_servers"`
+	// Server aca serve 的监听地址与任务队列
+	Server Server `yaml:"server"`
+	// Prompts 覆盖内置提示词模板的模板目录、单个模板与额外约束
+	Prompts prompt.Config `yaml:"prompts"`
+	// Pricing 各模型每百万 token 的价格，用于估算费用
+	Pricing usage.Pricing `yaml:"pricing"`
+	// Budget 单个会话的 token 与费用上限，达到后不再重试
+	Budget usage.Budget `yaml:"budget"`
+}
+
+// LLM 模型调用的容错配置，零值字段使用 DefaultLLM 中的值
+type LLM struct {
+	// Timeout 单次请求的超时
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxRetries 限流、过载、超时或网络错误时的最大重试次数，负数表示不重试
+	MaxRetries int `yaml:"max_retries"`
+	// Backoff 第一次重试前的等待时间，之后指数增长并加入随机抖动；服务返回 Retry-After 时以其为准
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// RateLimit 每秒最多发起的请求数，0 表示不限制；Burst 为允许的突发请求数
+	RateLimit float64 `yaml:"rate_limit"`
+	Burst     int     `yaml:"burst"`
+	// FallbackModels 主模型重试后仍失败时依次改用的模型
+	FallbackModels []string `yaml:"fallback_models"`
+}
+
+// DefaultLLM 模型调用容错的默认值
+var DefaultLLM = LLM{Timeout: 5 * time.Minute, MaxRetries: 3, Backoff: time.Second, MaxBackoff: 30 * time.Second, Burst: 1}
+
+// WithDefaults 返回以 DefaultLLM 填充零值字段的配置
+func (l LLM) WithDefaults() LLM {
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultLLM.Timeout
+	}
+	if l.MaxRetries == 0 {
+		l.MaxRetries = DefaultLLM.MaxRetries
+	}
+	if l.Backoff <= 0 {
+		l.Backoff = DefaultLLM.Backoff
+	}
+	if l.MaxBackoff <= 0 {
+		l.MaxBackoff = DefaultLLM.MaxBackoff
+	}
+	if l.Burst <= 0 {
+		l.Burst = DefaultLLM.Burst
+	}
+	return l
+}
+
+// Server aca serve 的配置，零值字段使用命令行参数的默认值
+type Server struct {
+	Addr      string `yaml:"addr"`
+	Workers   int    `yaml:"workers"`
+	QueueSize int    `yaml:"queue_size"`
+	// Token 非空时 API 要求 Authorization: Bearer <Token>，监听非回环地址时必须设置
+	Token string `yaml:"token"`
+	// AllowOrigins 除同源外允许访问 API 的浏览器 Origin，AllowHosts 除回环地址外允许的 Host
+	AllowOrigins []string `yaml:"allow_origins"`
+	AllowHosts   []string `yaml:"allow_hosts"`
+}
+
+// Tools 工具调用循环的限制，0 表示使用默认值
+type Tools struct {
+	MaxSteps  int `yaml:"max_steps"`
+	MaxTokens int `yaml:"max_tokens"`
+}
+
+// 审查结论的处理方式
+const (
+	ReviewGate     = "gate"     // 审查未通过时让 Coder 修改
+	ReviewAnnotate = "annotate" // 只在结果中附加审查意见
+)
+
+// Review 代码审查配置
+type Review struct {
+	Enabled bool `yaml:"enabled"`
+	// Model 审查使用的模型，为空时与生成代码的模型相同
+	Model string `yaml:"model"`
+	// Mode 为 gate 或 annotate，为空时为 gate
+	Mode string `yaml:"mode"`
 }
 
 func LoadConfig(path string) (*Config, error) {
 	cfg := &Config{}
 	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("failed to read config.yaml: %v", err)
-		return nil, err
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("failed to read %s: %w", path, err))
 	}
 	if err := yaml.Unmarshal(data, cfg); err != nil {
-		log.Fatalf("failed to parse config.yaml: %v", err)
-		return nil, err
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("failed to parse %s: %w", path, err))
+	}
+	if err := cfg.Security.Validate(); err != nil {
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("%s: %w", path, err))
+	}
+	if cfg.LLM.RateLimit < 0 {
+		return nil, errs.Errorf(errs.Config, "load config", "%s: negative llm.rate_limit", path)
+	}
+	if err := cfg.Pricing.Validate(); err != nil {
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("%s: %w", path, err))
+	}
+	if err := cfg.Budget.Validate(); err != nil {
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("%s: %w", path, err))
+	}
+	if m := cfg.Review.Mode; m != "" && m != ReviewGate && m != ReviewAnnotate {
+		return nil, errs.Errorf(errs.Config, "load config", "%s: unknown review mode %q", path, m)
+	}
+	if cfg.MCPConfig != "" {
+		mcpPath := cfg.MCPConfig
+		if !filepath.IsAbs(mcpPath) {
+			mcpPath = filepath.Join(filepath.Dir(path), mcpPath)
+		}
+		servers, err := mcp.LoadServers(mcpPath)
+		if err != nil {
+			return nil, errs.E(errs.Config, "load config", err)
+		}
+		cfg.MCPServers = mergeMCPServers(servers, cfg.MCPServers)
+	}
+	if err := mcp.ValidateServers(cfg.MCPServers); err != nil {
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("%s: %w", path, err))
+	}
+	if dir := cfg.Prompts.Dir; dir != "" && !filepath.IsAbs(dir) {
+		cfg.Prompts.Dir = filepath.Join(filepath.Dir(path), dir)
+	}
+	if _, err := prompt.Load(cfg.Prompts); err != nil {
+		return nil, errs.E(errs.Config, "load config", fmt.Errorf("%s: %w", path, err))
 	}
 	return cfg, nil
 }
+
+// mergeMCPServers 合并两组 MCP 服务配置，同名时 override 优先
+func mergeMCPServers(base, override map[string]mcp.ServerConfig) map[string]mcp.ServerConfig {
+	if len(base) == 0 {
+		return override
+	}
+	merged := make(map[string]mcp.ServerConfig, len(base)+len(override))
+	for name, s := range base {
+		merged[name] = s
+	}
+	for name, s := range override {
+		merged[name] = s
+	}
+	return merged
+}
@@ -1,6 +1,10 @@
 package config
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+)
 
 func TestLoadConfig(t *testing.T) {
 	cfg, err := LoadConfig("../../etc/config.yaml")
@@ -21,3 +25,13 @@ func TestLoadConfig(t *testing.T) {
 	}
 	t.Logf("config loaded successfully, %+v", cfg)
 }
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	_, err := LoadConfig("not-exist.yaml")
+	if err == nil {
+		t.Fatal("expect error for missing config file")
+	}
+	if errs.KindOf(err) != errs.Config {
+		t.Errorf("expect config error, got %v", errs.KindOf(err))
+	}
+}
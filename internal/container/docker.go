@@ -1,7 +1,9 @@
 package container
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 
@@ -14,6 +16,18 @@ import (
 
 type DockerClient struct {
 	cli *client.Client
+	// Output 非空时代替标准输出接收容器的标准输出与镜像拉取进度
+	Output io.Writer
+}
+
+// ExitError 容器内进程以非零状态退出，Output 为容器的合并输出
+type ExitError struct {
+	Code   int64
+	Output string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("container exited with status %d", e.Code)
 }
 
 func NewDockerClient() (*DockerClient, error) {
@@ -30,10 +44,18 @@ func (d *DockerClient) PullImage(ctx context.Context, imageName string) error {
 		return err
 	}
 	defer reader.Close()
-	io.Copy(os.Stdout, reader)
+	io.Copy(d.stdout(), reader)
 	return nil
 }
 
+func (d *DockerClient) stdout() io.Writer {
+	if d.Output != nil {
+		return d.Output
+	}
+	return os.Stdout
+}
+
+// RunContainer 运行容器并返回其输出，非零退出时返回 *ExitError
 func (d *DockerClient) RunContainer(ctx context.Context, imageName string, cmd []string) (string, error) {
 	resp, err := d.cli.ContainerCreate(ctx, &container.Config{
 		Image: imageName,
@@ -43,29 +65,15 @@ func (d *DockerClient) RunContainer(ctx context.Context, imageName string, cmd [
 	if err != nil {
 		return "", err
 	}
-	if err := d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", err
-	}
-	statusCh, errCh := d.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return "", err
-		}
-	case <-statusCh:
-	}
-	out, err := d.cli.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
-	if err != nil {
-		return "", err
-	}
-	stdcopy.StdCopy(os.Stdout, os.Stderr, out)
-	return resp.ID, nil
+	defer d.RemoveContainer(context.WithoutCancel(ctx), resp.ID)
+	return d.startAndWait(ctx, resp.ID, imageName)
 }
 
 func (d *DockerClient) RemoveContainer(ctx context.Context, containerID string) error {
 	return d.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
 }
 
+// RunContainerWithMount 将 hostDir 挂载到容器 targetDir 后运行，返回容器输出，非零退出时返回 *ExitError
 func (d *DockerClient) RunContainerWithMount(ctx context.Context, imageName string, cmd []string, hostDir, targetDir string) (string, error) {
 	resp, err := d.cli.ContainerCreate(ctx, &container.Config{
 		Image:      imageName,
@@ -84,24 +92,52 @@ func (d *DockerClient) RunContainerWithMount(ctx context.Context, imageName stri
 	if err != nil {
 		return "", err
 	}
-	defer d.RemoveContainer(ctx, resp.ID)
-	if err := d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+	defer d.RemoveContainer(context.WithoutCancel(ctx), resp.ID)
+	return d.startAndWait(ctx, resp.ID, imageName)
+}
+
+// startAndWait 启动容器，运行期间持续转发输出，等待结束后返回合并输出；
+// ctx 中有 Trace 时报告容器启动并逐行回调输出
+func (d *DockerClient) startAndWait(ctx context.Context, containerID, imageName string) (string, error) {
+	if err := d.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return "", err
+	}
+	trace := ContextTrace(ctx)
+	trace.started(containerID, imageName)
+	out, err := d.cli.ContainerLogs(ctx, containerID, container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
 		return "", err
 	}
-	statusCh, errCh := d.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	var buf bytes.Buffer
+	stdout, stderr := []io.Writer{d.stdout(), &buf}, []io.Writer{os.Stderr, &buf}
+	if w := trace.lines(StreamStdout); w != nil {
+		defer w.Close()
+		stdout = append(stdout, w)
+	}
+	if w := trace.lines(StreamStderr); w != nil {
+		defer w.Close()
+		stderr = append(stderr, w)
+	}
+	// 输出流在容器退出后结束
+	stdcopy.StdCopy(io.MultiWriter(stdout...), io.MultiWriter(stderr...), out)
+	out.Close()
+	var exitCode int64
+	statusCh, errCh := d.cli.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
 	select {
 	case err := <-errCh:
 		if err != nil {
 			return "", err
 		}
-	case <-statusCh:
+	case status := <-statusCh:
+		if status.Error != nil {
+			return "", fmt.Errorf("wait container: %s", status.Error.Message)
+		}
+		exitCode = status.StatusCode
 	}
-	out, err := d.cli.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
-	if err != nil {
-		return "", err
+	if exitCode != 0 {
+		return buf.String(), &ExitError{Code: exitCode, Output: buf.String()}
 	}
-	stdcopy.StdCopy(os.Stdout, os.Stderr, out)
-	return resp.ID, nil
+	return buf.String(), nil
 }
 
 func (d *DockerClient) Close() error {
@@ -0,0 +1,22 @@
+package container
+
+import "context"
+
+// RunSpec 一次容器运行的参数
+type RunSpec struct {
+	Image    string
+	Cmd      []string
+	HostDir  string // 挂载到容器的宿主机目录
+	MountDir string // 容器内挂载路径，同时作为工作目录
+}
+
+// Runtime 容器运行时的抽象，DockerClient 是默认实现
+type Runtime interface {
+	// Run 运行容器并返回合并输出，非零退出时返回 *ExitError
+	Run(ctx context.Context, spec RunSpec) (string, error)
+}
+
+// Run 实现 Runtime
+func (d *DockerClient) Run(ctx context.Context, spec RunSpec) (string, error) {
+	return d.RunContainerWithMount(ctx, spec.Image, spec.Cmd, spec.HostDir, spec.MountDir)
+}
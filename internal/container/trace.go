@@ -0,0 +1,81 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// 输出流名称
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Trace 容器运行过程的回调，通过 WithTrace 放入 ctx，由 Runtime 实现在运行时调用；未设置的回调会被跳过
+type Trace struct {
+	// Started 容器已启动
+	Started func(id, image string)
+	// Line 容器输出的一行，不含换行符
+	Line func(stream, line string)
+}
+
+type traceKey struct{}
+
+// WithTrace 返回携带 t 的 ctx
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// ContextTrace 返回 ctx 中的 Trace，没有时返回 nil
+func ContextTrace(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+func (t *Trace) started(id, image string) {
+	if t != nil && t.Started != nil {
+		t.Started(id, image)
+	}
+}
+
+// lines 返回按行调用 Line 的 Writer，Close 时输出最后不以换行结尾的部分；t 为 nil 时返回 nil
+func (t *Trace) lines(stream string) *lineWriter {
+	if t == nil || t.Line == nil {
+		return nil
+	}
+	return &lineWriter{stream: stream, fn: t.Line}
+}
+
+// lineWriter 将写入的内容按行回调
+type lineWriter struct {
+	mu     sync.Mutex
+	stream string
+	fn     func(stream, line string)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(w.stream, string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.fn(w.stream, string(w.buf))
+		w.buf = nil
+	}
+	return nil
+}
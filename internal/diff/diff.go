@@ -0,0 +1,158 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 行级编辑操作
+const (
+	OpEqual  = ' '
+	OpDelete = '-'
+	OpInsert = '+'
+)
+
+// Line 一行差异
+type Line struct {
+	Op   byte
+	Text string
+}
+
+// Lines 基于最长公共子序列计算 a 到 b 的逐行差异
+func Lines(a, b []string) []Line {
+	n, m := len(a), len(b)
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []Line
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{OpEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{OpDelete, a[i]})
+			i++
+		default:
+			out = append(out, Line{OpInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, Line{OpDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, Line{OpInsert, b[j]})
+	}
+	return out
+}
+
+// SplitLines 按行切分文本，忽略末尾换行
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Unified 生成 unified diff 格式的差异，context 为每个 hunk 的上下文行数，内容相同时返回空串
+func Unified(oldName, newName, a, b string, context int) string {
+	lines := Lines(SplitLines(a), SplitLines(b))
+	changed := false
+	for _, l := range lines {
+		if l.Op != OpEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(lines, context) {
+		sb.WriteString(h)
+	}
+	return sb.String()
+}
+
+// hunks 将差异按上下文切分为 hunk
+func hunks(lines []Line, context int) []string {
+	var out []string
+	// oldLine/newLine 记录每个差异行之前的行号
+	oldNo := make([]int, len(lines)+1)
+	newNo := make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+		if l.Op != OpInsert {
+			oldNo[i+1]++
+		}
+		if l.Op != OpDelete {
+			newNo[i+1]++
+		}
+	}
+	i := 0
+	for i < len(lines) {
+		// 找到下一处修改
+		for i < len(lines) && lines[i].Op == OpEqual {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		start := max(i-context, 0)
+		end := i
+		// 向后扩展，直到连续相等行超过 2*context
+		for end < len(lines) {
+			if lines[end].Op != OpEqual {
+				end++
+				continue
+			}
+			k := end
+			for k < len(lines) && lines[k].Op == OpEqual {
+				k++
+			}
+			if k == len(lines) || k-end > 2*context {
+				end = min(end+context, len(lines))
+				break
+			}
+			end = k
+		}
+		oldCount := oldNo[end] - oldNo[start]
+		newCount := newNo[end] - newNo[start]
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oldNo[start], oldCount), hunkRange(newNo[start], newCount))
+		for _, l := range lines[start:end] {
+			sb.WriteByte(l.Op)
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+		out = append(out, sb.String())
+		i = end
+	}
+	return out
+}
+
+// hunkRange 格式化 hunk 头中的起始行与行数
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
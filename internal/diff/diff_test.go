@@ -0,0 +1,34 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	a := "a\nb\nc\nd\ne\n"
+	b := "a\nb\nC\nd\ne\nf\n"
+	got := Unified("old", "new", a, b, 1)
+	expect := "--- old\n+++ new\n@@ -2,4 +2,5 @@\n b\n-c\n+C\n d\n e\n+f\n"
+	if got != expect {
+		t.Errorf("unexpected diff:\n%s\nexpect:\n%s", got, expect)
+	}
+	if Unified("old", "new", a, a, 3) != "" {
+		t.Errorf("identical inputs should produce empty diff")
+	}
+}
+
+func TestUnifiedNewFile(t *testing.T) {
+	got := Unified("/dev/null", "b/main.go", "", "x\ny\n", 3)
+	expect := "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if got != expect {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	b := "x\n2\n3\n4\n5\n6\n7\n8\ny\n"
+	got := Unified("a", "b", a, b, 1)
+	expect := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -8,2 +8,2 @@\n 8\n-9\n+y\n"
+	if got != expect {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+}
@@ -0,0 +1,110 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind 错误类别，用于区分流水线中出错的阶段
+type Kind int
+
+const (
+	Other      Kind = iota // 未分类错误
+	Usage                  // 命令行参数错误
+	Config                 // 配置加载/解析失败
+	LLM                    // 调用大模型失败
+	Extract                // 从响应中提取代码失败
+	Build                  // 代码编译失败
+	Runtime                // 代码运行失败（容器或程序本身）
+	TestFailed             // 测试未通过
+	Patch                  // 补丁无法应用到目标文件
+	Security               // 生成的代码未通过安全检查
+	Lint                   // 代码未通过质量门禁
+	Review                 // 代码审查要求修改
+	Budget                 // 会话用量达到预算
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Usage:
+		return "usage"
+	case Config:
+		return "config"
+	case LLM:
+		return "llm"
+	case Extract:
+		return "extract"
+	case Build:
+		return "build"
+	case Runtime:
+		return "runtime"
+	case TestFailed:
+		return "test failed"
+	case Patch:
+		return "patch"
+	case Security:
+		return "security"
+	case Lint:
+		return "lint"
+	case Review:
+		return "review"
+	case Budget:
+		return "budget"
+	}
+	return "other"
+}
+
+// Error 带类别的错误，Op 描述出错的操作
+type Error struct {
+	Kind Kind
+	Op   string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// E 构造带类别的错误，err 为 nil 时返回 nil
+func E(kind Kind, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Op: op, Err: err}
+}
+
+// Errorf 以格式化消息构造带类别的错误
+func Errorf(kind Kind, op, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Op: op, Err: fmt.Errorf(format, args...)}
+}
+
+// KindOf 返回错误链中最外层的类别，未分类时返回 Other
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Other
+}
+
+// Is 判断错误链中是否包含指定类别
+func Is(err error, kind Kind) bool {
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			return false
+		}
+		if e.Kind == kind {
+			return true
+		}
+		err = e.Err
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	base := errors.New("boom")
+	err := fmt.Errorf("wrapped: %w", E(Build, "run code", base))
+	if got := KindOf(err); got != Build {
+		t.Errorf("expect %v, got %v", Build, got)
+	}
+	if !errors.Is(err, base) {
+		t.Errorf("expect error chain to contain base error")
+	}
+	if KindOf(base) != Other {
+		t.Errorf("plain error should be Other")
+	}
+	if E(LLM, "x", nil) != nil {
+		t.Errorf("E with nil error should return nil")
+	}
+}
+
+func TestIs(t *testing.T) {
+	err := E(Runtime, "outer", E(TestFailed, "inner", errors.New("fail")))
+	if !Is(err, TestFailed) || !Is(err, Runtime) {
+		t.Errorf("expect both kinds in chain")
+	}
+	if Is(err, Config) {
+		t.Errorf("unexpected kind in chain")
+	}
+}
@@ -0,0 +1,116 @@
+// Package httpguard 保护本地 HTTP 服务：校验 Host 与 Origin 以防 DNS 重绑定与跨站请求，
+// 可选的 Bearer 令牌认证，以及要求请求体为 JSON
+package httpguard
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Options 访问控制选项，零值只允许以回环地址访问、不要求令牌
+type Options struct {
+	// Token 非空时要求 Authorization: Bearer <Token>，无法设置请求头的 EventSource 与 WebSocket 可使用 access_token 查询参数
+	Token string
+	// Hosts 除回环地址外允许的 Host（不含端口）
+	Hosts []string
+	// Origins 除与 Host 同源外允许的 Origin，如 https://example.com
+	Origins []string
+}
+
+// CheckListen 检查监听地址：绑定非回环地址时必须设置令牌
+func (o Options) CheckListen(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if !loopback(host) && o.Token == "" {
+		return fmt.Errorf("listening on non-loopback address %q requires a token", addr)
+	}
+	return nil
+}
+
+// Wrap 返回在 h 之前检查 Host、Origin 与令牌的处理器
+func Wrap(h http.Handler, opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.allowHost(r.Host) {
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		if !opts.AllowOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if !opts.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// RequireJSON 请求体不是 application/json 时返回 415 并返回 false
+func RequireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != "application/json" {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// AllowOrigin 判断请求的 Origin 是否允许：未携带 Origin（非浏览器客户端）、与 Host 同源或在 Origins 中
+func (o Options) AllowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range o.Origins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	_, host, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(host, r.Host)
+}
+
+func (o Options) allowHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if loopback(host) {
+		return true
+	}
+	for _, allowed := range o.Hosts {
+		if strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) authorized(r *http.Request) bool {
+	if o.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(o.Token)) == 1
+}
+
+// loopback 判断 host 是否为 localhost 或回环 IP；空地址（监听全部网卡）不是回环地址
+func loopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,88 @@
+package httpguard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name   string
+		opts   Options
+		host   string
+		header map[string]string
+		target string
+		want   int
+	}{
+		{"loopback", Options{}, "127.0.0.1:8765", nil, "/", http.StatusOK},
+		{"localhost", Options{}, "localhost:8765", nil, "/", http.StatusOK},
+		{"ipv6 loopback", Options{}, "[::1]:8765", nil, "/", http.StatusOK},
+		{"rebinding", Options{}, "evil.example:8765", nil, "/", http.StatusForbidden},
+		{"allowed host", Options{Hosts: []string{"aca.internal"}}, "aca.internal:8765", nil, "/", http.StatusOK},
+		{"same origin", Options{}, "127.0.0.1:8765", map[string]string{"Origin": "http://127.0.0.1:8765"}, "/", http.StatusOK},
+		{"cross origin", Options{}, "127.0.0.1:8765", map[string]string{"Origin": "https://evil.example"}, "/", http.StatusForbidden},
+		{"allowed origin", Options{Origins: []string{"https://app.example/"}}, "127.0.0.1:8765", map[string]string{"Origin": "https://app.example"}, "/", http.StatusOK},
+		{"missing token", Options{Token: "s3cret"}, "127.0.0.1:8765", nil, "/", http.StatusUnauthorized},
+		{"wrong token", Options{Token: "s3cret"}, "127.0.0.1:8765", map[string]string{"Authorization": "Bearer nope"}, "/", http.StatusUnauthorized},
+		{"bearer token", Options{Token: "s3cret"}, "127.0.0.1:8765", map[string]string{"Authorization": "Bearer s3cret"}, "/", http.StatusOK},
+		{"query token", Options{Token: "s3cret"}, "127.0.0.1:8765", nil, "/?access_token=s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Host = tt.host
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			Wrap(ok, tt.opts).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"application/json":                  true,
+		"application/json; charset=utf-8":   true,
+		"text/plain":                        false,
+		"application/x-www-form-urlencoded": false,
+		"":                                  false,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		r.Header.Set("Content-Type", ct)
+		w := httptest.NewRecorder()
+		if got := RequireJSON(w, r); got != want {
+			t.Errorf("RequireJSON(%q) = %v, want %v", ct, got, want)
+		}
+		if !want && w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("RequireJSON(%q) status = %d", ct, w.Code)
+		}
+	}
+}
+
+func TestCheckListen(t *testing.T) {
+	for _, tt := range []struct {
+		addr  string
+		token string
+		ok    bool
+	}{
+		{"127.0.0.1:8765", "", true},
+		{"localhost:8080", "", true},
+		{"[::1]:8080", "", true},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "", false},
+		{"0.0.0.0:8080", "s3cret", true},
+		{"bad", "", false},
+	} {
+		err := Options{Token: tt.token}.CheckListen(tt.addr)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckListen(%q, token=%q) = %v", tt.addr, tt.token, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package lang
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Language 描述一种编程语言在容器中的运行方式
+type Language struct {
+	Name     string // 语言名，如 go/python
+	Image    string // 运行代码使用的镜像
+	MainFile string // 默认主文件名
+	TestFile string // 默认测试文件名
+	// RunCmd 根据相对 workDir 的主文件与依赖文件生成运行命令
+	RunCmd func(mainFiles, depFiles []string) []string
+	// TestCmd 根据测试文件名生成测试命令
+	TestCmd func(testFile string) []string
+	// CheckCmd 在已有项目的根目录中编译并运行全部测试，用于校验补丁
+	CheckCmd []string
+	// LintCmd 根据相对 workDir 的文件与额外的检查工具生成质量门禁命令：自动修复 files 的格式，
+	// 再运行静态检查；每个工具的输出前打印一行 "::tool <name>"。为 nil 时不支持质量门禁
+	LintCmd func(files, linters []string) []string
+}
+
+// Version 返回镜像标签中的语言版本，如 golang:1.24.0 为 1.24.0；没有标签或标签为 latest 时为空
+func (l *Language) Version() string {
+	name := l.Image[strings.LastIndex(l.Image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	if i < 0 || name[i+1:] == "latest" {
+		return ""
+	}
+	return name[i+1:]
+}
+
+// Registry 语言注册表，可并发读写
+type Registry struct {
+	mu    sync.RWMutex
+	langs map[string]*Language
+}
+
+func NewRegistry(langs ...*Language) *Registry {
+	r := &Registry{langs: make(map[string]*Language)}
+	for _, l := range langs {
+		r.Register(l)
+	}
+	return r
+}
+
+// Register 注册语言，同名语言会被覆盖
+func (r *Registry) Register(l *Language) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.langs[l.Name] = l
+}
+
+func (r *Registry) Get(name string) (*Language, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	l, ok := r.langs[name]
+	return l, ok
+}
+
+// Names 返回已注册的语言名（已排序）
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.langs))
+	for name := range r.langs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var Go = &Language{
+	Name:     "go",
+	Image:    "golang:1.24.0",
+	MainFile: "main.go",
+	TestFile: "main_test.go",
+	RunCmd: func(mainFiles, depFiles []string) []string {
+		cmd := []string{"go", "run"}
+		cmd = append(cmd, mainFiles...)
+		return append(cmd, depFiles...)
+	},
+	TestCmd: func(testFile string) []string {
+		// 工作目录中没有 go.mod 时临时初始化一个模块
+		return []string{"sh", "-c", "[ -f go.mod ] || go mod init aca >/dev/null 2>&1; go test -v ."}
+	},
+	CheckCmd: []string{"sh", "-c", "[ -f go.mod ] || go mod init aca >/dev/null 2>&1; go build ./... && go test ./..."},
+	LintCmd: func(files, linters []string) []string {
+		args := quoteAll(files)
+		script := []string{
+			"[ -f go.mod ] || go mod init aca >/dev/null 2>&1",
+			// 镜像中没有 goimports 时先安装，安装失败仍由 gofmt 修复格式
+			"echo '::tool goimports'; { command -v goimports >/dev/null 2>&1 || go install golang.org/x/tools/cmd/goimports@latest >/dev/null 2>&1; } && goimports -l -w " + args,
+			"echo '::tool gofmt'; gofmt -l -w " + args,
+			"echo '::tool vet'; go vet ./... 2>&1",
+		}
+		for _, linter := range linters {
+			switch linter {
+			case "staticcheck":
+				script = append(script, "echo '::tool staticcheck'; { command -v staticcheck >/dev/null 2>&1 || go install honnef.co/go/tools/cmd/staticcheck@latest >/dev/null 2>&1; } && staticcheck ./... 2>&1")
+			case "golangci-lint":
+				script = append(script, "echo '::tool golangci-lint'; command -v golangci-lint >/dev/null 2>&1 && golangci-lint run ./... 2>&1")
+			}
+		}
+		// 发现问题时各工具以非零状态退出，由输出判断结果
+		return []string{"sh", "-c", strings.Join(script, "\n") + "\ntrue"}
+	},
+}
+
+var Python = &Language{
+	Name:     "python",
+	Image:    "python:3.11",
+	MainFile: "main.py",
+	TestFile: "test_main.py",
+	RunCmd: func(mainFiles, depFiles []string) []string {
+		return []string{"python", mainFiles[0]}
+	},
+	TestCmd: func(testFile string) []string {
+		return []string{"sh", "-c", "pip install -q pytest >/dev/null 2>&1; python -m pytest " + testFile}
+	},
+	// pytest 没有收集到测试时退出码为 5，视为通过
+	CheckCmd: []string{"sh", "-c", "pip install -q pytest >/dev/null 2>&1; python -m compileall -q . && { python -m pytest; code=$?; [ $code -eq 5 ] && exit 0; exit $code; }"},
+	LintCmd: func(files, linters []string) []string {
+		args := quoteAll(files)
+		script := []string{
+			"pip install -q ruff >/dev/null 2>&1",
+			"echo '::tool ruff-format'; for f in " + args + "; do ruff format --check -q \"$f\" >/dev/null 2>&1 || { ruff format -q \"$f\" >/dev/null 2>&1 && echo \"$f\"; }; done",
+			"echo '::tool ruff'; ruff check --output-format concise " + args + " 2>&1",
+		}
+		return []string{"sh", "-c", strings.Join(script, "\n") + "\ntrue"}
+	},
+}
+
+// quoteAll 将文件名转为 shell 单引号参数
+func quoteAll(files []string) string {
+	quoted := make([]string, len(files))
+	for i, f := range files {
+		quoted[i] = "'" + strings.ReplaceAll(f, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Default 返回包含内置语言的新注册表
+func Default() *Registry {
+	return NewRegistry(Go, Python)
+}
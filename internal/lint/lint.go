@@ -0,0 +1,124 @@
+// Package lint 解析质量门禁（格式化与静态检查）在容器中的输出
+package lint
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ToolMarker 检查命令在每个工具的输出前打印一行 "::tool <name>"，用于区分问题来源
+const ToolMarker = "::tool "
+
+// FormatTools 自动修复格式的工具，其输出为被改写的文件列表而不是问题
+var FormatTools = map[string]bool{"gofmt": true, "goimports": true, "ruff-format": true}
+
+// Gate 质量门禁配置
+type Gate struct {
+	Enabled bool `yaml:"enabled"`
+	// Linters 额外运行的检查工具，如 staticcheck、golangci-lint
+	Linters []string `yaml:"linters"`
+	// Feedback 为 true 时把剩余问题作为审查意见反馈给模型重写，否则只输出警告
+	Feedback bool `yaml:"feedback"`
+}
+
+// Issue 一条检查出的问题
+type Issue struct {
+	Tool    string `json:"tool"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	pos := fmt.Sprintf("%s:%d", i.File, i.Line)
+	if i.Column > 0 {
+		pos += fmt.Sprintf(":%d", i.Column)
+	}
+	return fmt.Sprintf("%s: %s (%s)", pos, i.Message, i.Tool)
+}
+
+// Report 一次质量门禁的结果
+type Report struct {
+	Issues    []Issue  `json:"issues,omitempty"`
+	Formatted []string `json:"formatted,omitempty"` // 被自动格式化的文件
+	Output    string   `json:"output,omitempty"`
+}
+
+// Error 格式化后仍有未解决的问题
+type Error struct {
+	Issues []Issue
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		parts[i] = issue.String()
+	}
+	return "quality gate failed: " + strings.Join(parts, "; ")
+}
+
+// issueRe 匹配 file:line[:col]: message 形式的输出
+var issueRe = regexp.MustCompile(`^(\S+?\.\w+):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// Parse 解析检查命令的输出，同一位置的重复问题只保留一条
+func Parse(output string) *Report {
+	r := &Report{Output: output}
+	tool := ""
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, ToolMarker) {
+			tool = strings.TrimSpace(strings.TrimPrefix(line, ToolMarker))
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if FormatTools[tool] {
+			if file := Clean(strings.TrimSpace(line)); !seen["format:"+file] && !strings.Contains(file, " ") {
+				seen["format:"+file] = true
+				r.Formatted = append(r.Formatted, file)
+			}
+			continue
+		}
+		// go vet 报告类型错误时带 vet: 前缀
+		m := issueRe.FindStringSubmatch(strings.TrimPrefix(strings.TrimSpace(line), "vet: "))
+		if m == nil {
+			continue
+		}
+		issue := Issue{Tool: tool, File: Clean(m[1]), Message: strings.TrimSpace(m[4])}
+		issue.Line, _ = strconv.Atoi(m[2])
+		issue.Column, _ = strconv.Atoi(m[3])
+		key := fmt.Sprintf("%s:%d:%d:%s", issue.File, issue.Line, issue.Column, issue.Message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		r.Issues = append(r.Issues, issue)
+	}
+	return r
+}
+
+// Clean 规范化工具输出中的相对路径，去掉开头的 ./
+func Clean(path string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimPrefix(path, "./")))
+}
+
+// Filter 只保留 files（相对路径）中的问题
+func Filter(issues []Issue, files []string) []Issue {
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		keep[Clean(f)] = true
+	}
+	var out []Issue
+	for _, issue := range issues {
+		if keep[issue.File] {
+			out = append(out, issue)
+		}
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package lint
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	out := `::tool goimports
+main.go
+::tool gofmt
+main.go
+::tool vet
+# aca
+./main.go:7:2: fmt.Printf format %d has arg s of wrong type string
+vet: ./util/util.go:3:8: "os" imported and not used
+::tool staticcheck
+main.go:7:2: fmt.Printf format %d has arg s of wrong type string
+main.go:9:1: should omit type int (ST1023)
+`
+	r := Parse(out)
+	if len(r.Formatted) != 1 || r.Formatted[0] != "main.go" {
+		t.Errorf("unexpected formatted files %v", r.Formatted)
+	}
+	if len(r.Issues) != 3 {
+		t.Fatalf("expect 3 issues after removing duplicates, got %v", r.Issues)
+	}
+	first := r.Issues[0]
+	if first.Tool != "vet" || first.File != "main.go" || first.Line != 7 || first.Column != 2 {
+		t.Errorf("unexpected issue %+v", first)
+	}
+	if r.Issues[1].File != "util/util.go" {
+		t.Errorf("vet prefix not stripped: %+v", r.Issues[1])
+	}
+	if got := Filter(r.Issues, []string{"./main.go"}); len(got) != 2 {
+		t.Errorf("expect 2 issues in main.go, got %v", got)
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import "fmt"
+
+// Condition 补全没有给出完整可用回复的原因
+type Condition string
+
+const (
+	NoChoices       Condition = "no choices"     // 服务返回的补全没有任何选项
+	Refused         Condition = "refusal"        // 模型拒绝回答
+	Truncated       Condition = "truncated"      // 回复达到 max tokens 被截断
+	ContentFiltered Condition = "content filter" // 回复被内容过滤中止
+)
+
+// 补全的结束原因
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishContentFilter = "content_filter"
+	FinishToolCalls     = "tool_calls"
+)
+
+// CompletionError 补全成功返回但回复不可直接使用，Response 为已返回的部分回复（NoChoices 时为 nil），
+// 其用量仍计入消耗
+type CompletionError struct {
+	Condition Condition
+	// Refusal 模型给出的拒绝理由
+	Refusal  string
+	Response *Response
+}
+
+func (e *CompletionError) Error() string {
+	switch e.Condition {
+	case Refused:
+		if e.Refusal != "" {
+			return fmt.Sprintf("model refused: %s", e.Refusal)
+		}
+		return "model refused"
+	case Truncated:
+		return "completion truncated at max tokens"
+	case ContentFiltered:
+		return "completion stopped by content filter"
+	}
+	return "completion has no choices"
+}
+
+// checkCompletion 根据结束原因与拒绝理由判断回复是否可用
+func checkCompletion(resp *Response, refusal string) error {
+	switch {
+	case refusal != "":
+		return &CompletionError{Condition: Refused, Refusal: refusal, Response: resp}
+	case resp.FinishReason == FinishLength:
+		return &CompletionError{Condition: Truncated, Response: resp}
+	case resp.FinishReason == FinishContentFilter:
+		return &CompletionError{Condition: ContentFiltered, Response: resp}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Conversation 有序的多轮对话，首条 system 消息在截断与摘要时始终保留
+type Conversation struct {
+	Messages []Message `json:"messages"`
+	// Summarized 已被摘要替换的早期消息数量
+	Summarized int `json:"summarized,omitempty"`
+}
+
+// NewConversation 创建以 system 消息开头的对话，system 为空时不添加
+func NewConversation(system string) *Conversation {
+	c := &Conversation{}
+	if system != "" {
+		c.Append(RoleSystem, system)
+	}
+	return c
+}
+
+// Append 追加一条消息
+func (c *Conversation) Append(role, content string) {
+	c.Messages = append(c.Messages, Message{Role: role, Content: content})
+}
+
+// AddUser 追加用户消息
+func (c *Conversation) AddUser(content string) {
+	c.Append(RoleUser, content)
+}
+
+// AddAssistant 追加模型回复
+func (c *Conversation) AddAssistant(content string) {
+	c.Append(RoleAssistant, content)
+}
+
+// AddToolCalls 追加发起工具调用的模型回复
+func (c *Conversation) AddToolCalls(content string, calls []ToolCall) {
+	c.Messages = append(c.Messages, Message{Role: RoleAssistant, Content: content, ToolCalls: calls})
+}
+
+// AddTool 追加工具调用结果
+func (c *Conversation) AddTool(toolCallID, content string) {
+	c.Messages = append(c.Messages, Message{Role: RoleTool, Content: content, ToolCallID: toolCallID})
+}
+
+// Len 返回消息数量
+func (c *Conversation) Len() int {
+	return len(c.Messages)
+}
+
+// Last 返回最后一条消息，对话为空时返回零值
+func (c *Conversation) Last() Message {
+	if len(c.Messages) == 0 {
+		return Message{}
+	}
+	return c.Messages[len(c.Messages)-1]
+}
+
+// Pop 移除末尾 n 条消息，不会移除首条 system 消息
+func (c *Conversation) Pop(n int) {
+	keep := len(c.Messages) - n
+	if keep < c.head() {
+		keep = c.head()
+	}
+	c.Messages = c.Messages[:keep]
+}
+
+// Clone 返回深拷贝
+func (c *Conversation) Clone() *Conversation {
+	return &Conversation{Messages: append([]Message(nil), c.Messages...), Summarized: c.Summarized}
+}
+
+// Request 以当前对话构造补全请求
+func (c *Conversation) Request(model string) Request {
+	return Request{Model: model, Messages: append([]Message(nil), c.Messages...)}
+}
+
+// head 返回需要始终保留的前缀长度（首条 system 消息）
+func (c *Conversation) head() int {
+	if len(c.Messages) > 0 && c.Messages[0].Role == RoleSystem {
+		return 1
+	}
+	return 0
+}
+
+// EstimateTokens 粗略估算文本的 token 数：ASCII 约 4 字符一个 token，其余字符各算一个
+func EstimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// Tokens 估算整个对话的 token 数，每条消息额外计入少量格式开销
+func (c *Conversation) Tokens() int {
+	total := 0
+	for _, m := range c.Messages {
+		total += EstimateTokens(m.Content) + 4
+		for _, call := range m.ToolCalls {
+			total += EstimateTokens(call.Name+call.Arguments) + 4
+		}
+	}
+	return total
+}
+
+// Truncate 从最早的非 system 消息开始丢弃，直到估算 token 数不超过 limit，
+// 最后一条消息始终保留。返回丢弃的消息数，limit <= 0 时不处理。
+func (c *Conversation) Truncate(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	head, dropped := c.head(), 0
+	for c.Tokens() > limit && len(c.Messages)-head > 1 {
+		c.Messages = append(c.Messages[:head], c.Messages[head+1:]...)
+		dropped++
+	}
+	// 工具结果不能脱离发起调用的 assistant 消息单独存在
+	for len(c.Messages)-head > 1 && c.Messages[head].Role == RoleTool {
+		c.Messages = append(c.Messages[:head], c.Messages[head+1:]...)
+		dropped++
+	}
+	return dropped
+}
+
+const (
+	summaryPrompt = "请用简洁的中文总结以下对话，保留用户的全部需求、约束以及最终代码的关键结构，供后续对话继续使用:\n\n"
+	summaryPrefix = "[此前对话摘要]\n"
+)
+
+// Summarize 当估算 token 数超过 limit 时，反复用模型将较早的一半对话压缩为一条摘要消息，
+// 无法继续摘要时退化为 Truncate。limit <= 0 时不处理。
+func (c *Conversation) Summarize(ctx context.Context, p Provider, model string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	head := c.head()
+	for c.Tokens() > limit {
+		// 至少保留最近一半对话不参与摘要
+		end := head + (len(c.Messages)-head)/2
+		// 工具结果需与发起调用的消息一起被摘要
+		for end < len(c.Messages)-1 && c.Messages[end].Role == RoleTool {
+			end++
+		}
+		if end-head < 2 {
+			c.Truncate(limit)
+			return nil
+		}
+		var sb strings.Builder
+		replaced := 0
+		for _, m := range c.Messages[head:end] {
+			fmt.Fprintf(&sb, "[%s]\n%s\n\n", m.Role, m.Content)
+			if !strings.HasPrefix(m.Content, summaryPrefix) {
+				replaced++
+			}
+		}
+		resp, err := p.Complete(ctx, Request{Model: model, Messages: []Message{{Role: RoleUser, Content: summaryPrompt + sb.String()}}})
+		if err != nil {
+			return err
+		}
+		summary := Message{Role: RoleUser, Content: summaryPrefix + resp.Content}
+		rest := append([]Message{summary}, c.Messages[end:]...)
+		c.Messages = append(c.Messages[:head], rest...)
+		c.Summarized += replaced
+	}
+	return nil
+}
+
+// Save 以 JSON 格式保存到 path，必要时创建目录
+func (c *Conversation) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadConversation 读取 Save 保存的对话
+func LoadConversation(path string) (*Conversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conversation{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse conversation %s: %w", path, err)
+	}
+	return c, nil
+}
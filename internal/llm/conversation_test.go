@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type stubProvider struct {
+	reply string
+	reqs  []Request
+}
+
+func (s *stubProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	s.reqs = append(s.reqs, req)
+	return &Response{Content: s.reply}, nil
+}
+
+func TestConversationAppendAndPop(t *testing.T) {
+	c := NewConversation("sys")
+	c.AddUser("hi")
+	c.AddAssistant("hello")
+	c.AddTool("call_1", "ok")
+	if c.Len() != 4 || c.Last().ToolCallID != "call_1" {
+		t.Fatalf("unexpected messages %+v", c.Messages)
+	}
+	c.Pop(10)
+	if c.Len() != 1 || c.Messages[0].Role != RoleSystem {
+		t.Errorf("pop should keep system message, got %+v", c.Messages)
+	}
+}
+
+func TestConversationTruncate(t *testing.T) {
+	c := NewConversation("sys")
+	for i := 0; i < 10; i++ {
+		c.AddUser(strings.Repeat("a", 400))
+		c.AddAssistant(strings.Repeat("b", 400))
+	}
+	c.AddUser("last")
+	dropped := c.Truncate(300)
+	if dropped == 0 || c.Tokens() > 300 {
+		t.Errorf("expect truncation under limit, dropped %d, tokens %d", dropped, c.Tokens())
+	}
+	if c.Messages[0].Content != "sys" || c.Last().Content != "last" {
+		t.Errorf("system and last message must be kept: %+v", c.Messages)
+	}
+}
+
+func TestConversationSummarize(t *testing.T) {
+	c := NewConversation("sys")
+	for i := 0; i < 4; i++ {
+		c.AddUser(strings.Repeat("需求", 100))
+		c.AddAssistant(strings.Repeat("代码", 100))
+	}
+	p := &stubProvider{reply: "摘要"}
+	if err := c.Summarize(context.Background(), p, "m", 500); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.reqs) == 0 {
+		t.Fatalf("expect summary requests")
+	}
+	if c.Summarized == 0 || !strings.Contains(c.Messages[1].Content, "摘要") {
+		t.Errorf("unexpected summary state: %d %+v", c.Summarized, c.Messages[1])
+	}
+	if c.Tokens() > 500 {
+		t.Errorf("still over limit: %d", c.Tokens())
+	}
+}
+
+func TestConversationSaveLoad(t *testing.T) {
+	c := NewConversation("sys")
+	c.AddUser("hi")
+	c.AddTool("call_1", "result")
+	path := filepath.Join(t.TempDir(), "conv", "conversation.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConversation(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 3 || loaded.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("unexpected loaded conversation %+v", loaded.Messages)
+	}
+}
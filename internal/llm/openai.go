@@ -2,22 +2,94 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 
 	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
 )
 
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message 一条对话消息
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // RoleAssistant 消息中模型发起的工具调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // RoleTool 消息对应的调用 ID
+}
+
+// Tool 可供模型调用的工具，Parameters 为参数的 JSON Schema
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// ToolCall 模型发起的一次工具调用，Arguments 为 JSON 字符串
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Request 一次补全请求
+type Request struct {
+	Model    string
+	Messages []Message
+	// Schema 非空时要求模型按 JSON Schema 输出
+	Schema *Schema
+	// Tools 非空时允许模型调用这些工具
+	Tools []Tool
+	// OnDelta 非空时以流式请求补全，每收到一段增量文本调用一次
+	OnDelta func(delta string)
+}
+
+// Usage 一次补全消耗的 token 数，服务未返回时为零值
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// CachedTokens PromptTokens 中命中服务端缓存的部分
+	CachedTokens int `json:"cached_tokens,omitempty"`
+}
+
+// Response 一次补全的结果
+type Response struct {
+	Content   string
+	ToolCalls []ToolCall
+	Usage     Usage
+	// Model 实际生成回复的模型，使用备用模型时与请求的模型不同；为空时即请求的模型
+	Model string
+	// FinishReason 服务给出的结束原因，如 stop、length
+	FinishReason string
+}
+
+// Provider 大模型服务的抽象，OpenAIClient 是默认实现
+type Provider interface {
+	Complete(ctx context.Context, req Request) (*Response, error)
+}
+
 type OpenAIClient struct {
 	client openai.Client
 }
 
 func NewOpenAIClient(cfg config.Config) *OpenAIClient {
 	return &OpenAIClient{
-		client: openai.NewClient(option.WithAPIKey(cfg.ApiKey), option.WithBaseURL(cfg.BaseUrl)),
+		// 重试由 Resilient 负责
+		client: openai.NewClient(option.WithAPIKey(cfg.ApiKey), option.WithBaseURL(cfg.BaseUrl), option.WithMaxRetries(0)),
 	}
 }
 
@@ -56,18 +128,138 @@ func ExtractCodeFilesFromLLMResponse(content, defaultFile string) map[string]str
 }
 
 // RawChatCompletion 返回原始 LLM 响应内容
-func (c *OpenAIClient) RawChatCompletion(ctx context.Context, prompt, language, model string) (string, error) {
-	systemPrompt := "你精通" + language + ", 请你根据用户需求生成代码, 如有多个文件请用注释标明文件名, 每个代码块以markdown单独输出, 例如 // main.go 放在代码块首行"
-	params := openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(systemPrompt),
-			openai.UserMessage(prompt),
-		},
-		Model: model,
+func (c *OpenAIClient) RawChatCompletion(ctx context.Context, text, language, model string) (string, error) {
+	system, err := prompt.Default().Render(prompt.ModeGen, prompt.RoleSystem, prompt.NewData(nil, language, text))
+	if err != nil {
+		return "", err
 	}
-	completion, err := c.client.Chat.Completions.New(ctx, params)
+	resp, err := c.Complete(ctx, Request{
+		Model: model,
+		Messages: []Message{
+			{Role: RoleSystem, Content: system},
+			{Role: RoleUser, Content: text},
+		},
+	})
 	if err != nil {
 		return "", err
 	}
-	return completion.Choices[0].Message.Content, nil
+	return resp.Content, nil
+}
+
+// Complete 发送完整的消息列表并返回补全内容；没有选项、拒绝回答、被截断或被内容过滤时返回 *CompletionError
+func (c *OpenAIClient) Complete(ctx context.Context, req Request) (*Response, error) {
+	params := openai.ChatCompletionNewParams{
+		Messages: toOpenAIMessages(req.Messages),
+		Model:    req.Model,
+	}
+	if s := req.Schema; s != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:        s.Name,
+				Description: openai.String(s.Description),
+				Schema:      s.Schema,
+				Strict:      openai.Bool(true),
+			}},
+		}
+	}
+	for _, t := range req.Tools {
+		params.Tools = append(params.Tools, openai.ChatCompletionToolParam{Function: shared.FunctionDefinitionParam{
+			Name:        t.Name,
+			Description: openai.String(t.Description),
+			Parameters:  shared.FunctionParameters(t.Parameters),
+		}})
+	}
+	completion, err := c.create(ctx, params, req.OnDelta)
+	var apiErr *openai.Error
+	if req.Schema != nil && errors.As(err, &apiErr) && schemaUnsupported(apiErr) {
+		// 服务不支持 json_schema 时去掉约束重试，由提示词要求 JSON 输出，解析失败时回退到 markdown 提取
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{}
+		completion, err = c.create(ctx, params, req.OnDelta)
+	}
+	if errors.As(err, &apiErr) {
+		return nil, &StatusError{StatusCode: apiErr.StatusCode, RetryAfter: retryAfter(apiErr.Response.Header), Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return nil, &CompletionError{Condition: NoChoices}
+	}
+	choice := completion.Choices[0]
+	msg := choice.Message
+	resp := &Response{Content: msg.Content, FinishReason: choice.FinishReason, Usage: Usage{
+		PromptTokens:     int(completion.Usage.PromptTokens),
+		CompletionTokens: int(completion.Usage.CompletionTokens),
+		TotalTokens:      int(completion.Usage.TotalTokens),
+		CachedTokens:     int(completion.Usage.PromptTokensDetails.CachedTokens),
+	}}
+	for _, call := range msg.ToolCalls {
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	if err := checkCompletion(resp, msg.Refusal); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// create 发送补全请求，onDelta 非空时使用流式接口并合并为完整的补全结果
+func (c *OpenAIClient) create(ctx context.Context, params openai.ChatCompletionNewParams, onDelta func(string)) (*openai.ChatCompletion, error) {
+	if onDelta == nil {
+		return c.client.Chat.Completions.New(ctx, params)
+	}
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return &acc.ChatCompletion, nil
+}
+
+// schemaUnsupported 判断 400 错误是否由 response_format/json_schema 引起，其他无效请求去掉约束也不会成功
+func schemaUnsupported(err *openai.Error) bool {
+	if err.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	text := strings.ToLower(err.Message + " " + err.Param + " " + err.RawJSON())
+	return strings.Contains(text, "response_format") || strings.Contains(text, "json_schema")
+}
+
+func toOpenAIMessages(msgs []Message) []openai.ChatCompletionMessageParamUnion {
+	out := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
+	for _, m := range msgs {
+		switch m.Role {
+		case RoleSystem:
+			out = append(out, openai.SystemMessage(m.Content))
+		case RoleAssistant:
+			if len(m.ToolCalls) == 0 {
+				out = append(out, openai.AssistantMessage(m.Content))
+				continue
+			}
+			msg := openai.ChatCompletionAssistantMessageParam{}
+			if m.Content != "" {
+				msg.Content.OfString = openai.String(m.Content)
+			}
+			for _, call := range m.ToolCalls {
+				msg.ToolCalls = append(msg.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+					ID:       call.ID,
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: call.Name, Arguments: call.Arguments},
+				})
+			}
+			out = append(out, openai.ChatCompletionMessageParamUnion{OfAssistant: &msg})
+		case RoleTool:
+			out = append(out, openai.ToolMessage(m.Content, m.ToolCallID))
+		default:
+			out = append(out, openai.UserMessage(m.Content))
+		}
+	}
+	return out
 }
@@ -1,7 +1,16 @@
 package llm
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
 )
 
 func TestExtractCodeFilesFromLLMResponse(t *testing.T) {
@@ -50,3 +59,110 @@ func TestExtractCodeFilesFromLLMResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestCompleteStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("request is not streaming: %v", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"hel"}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+			`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	c := NewOpenAIClient(config.Config{ApiKey: "k", BaseUrl: srv.URL})
+	var deltas []string
+	resp, err := c.Complete(context.Background(), Request{
+		Model:    "m",
+		Messages: []Message{{Role: RoleUser, Content: "hi"}},
+		OnDelta:  func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello" || strings.Join(deltas, "|") != "hel|lo" {
+		t.Errorf("content %q, deltas %q", resp.Content, deltas)
+	}
+	if resp.Usage.TotalTokens != 5 {
+		t.Errorf("usage %+v", resp.Usage)
+	}
+}
+
+func TestCompleteConditions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		choices string
+		want    Condition
+		content string
+	}{
+		{"no choices", `[]`, NoChoices, ""},
+		{"refusal", `[{"index":0,"message":{"role":"assistant","content":"","refusal":"I can't help with that"},"finish_reason":"stop"}]`, Refused, ""},
+		{"truncated", `[{"index":0,"message":{"role":"assistant","content":"` + "```go\\npackage ma" + `"},"finish_reason":"length"}]`, Truncated, "```go\npackage ma"},
+		{"content filter", `[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}]`, ContentFiltered, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"id":"1","object":"chat.completion","created":1,"model":"m","choices":%s,"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`, tc.choices)
+			}))
+			defer srv.Close()
+			c := NewOpenAIClient(config.Config{ApiKey: "k", BaseUrl: srv.URL})
+			_, err := c.Complete(context.Background(), Request{Model: "m", Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+			var incomplete *CompletionError
+			if !errors.As(err, &incomplete) || incomplete.Condition != tc.want {
+				t.Fatalf("expect %s, got %v", tc.want, err)
+			}
+			if tc.want == NoChoices {
+				return
+			}
+			if incomplete.Response == nil || incomplete.Response.Content != tc.content || incomplete.Response.Usage.TotalTokens != 5 {
+				t.Errorf("unexpected partial response %+v", incomplete.Response)
+			}
+		})
+	}
+}
+
+func TestCompleteSchemaFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		message  string
+		requests int
+		ok       bool
+	}{
+		{"unsupported schema", "response_format json_schema is not supported", 2, true},
+		{"other bad request", "messages must not be empty", 1, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				var body map[string]any
+				json.NewDecoder(r.Body).Decode(&body)
+				w.Header().Set("Content-Type", "application/json")
+				if body["response_format"] != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprintf(w, `{"error":{"message":%q,"type":"invalid_request_error"}}`, tc.message)
+					return
+				}
+				fmt.Fprint(w, `{"id":"1","object":"chat.completion","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"{}"},"finish_reason":"stop"}]}`)
+			}))
+			defer srv.Close()
+			c := NewOpenAIClient(config.Config{ApiKey: "k", BaseUrl: srv.URL})
+			schema := &Schema{Name: "code", Schema: map[string]any{"type": "object"}}
+			_, err := c.Complete(context.Background(), Request{Model: "m", Messages: []Message{{Role: RoleUser, Content: "hi"}}, Schema: schema})
+			var status *StatusError
+			if requests != tc.requests || (err == nil) != tc.ok || !tc.ok && (!errors.As(err, &status) || status.StatusCode != http.StatusBadRequest) {
+				t.Errorf("got %d requests and %v", requests, err)
+			}
+		})
+	}
+}
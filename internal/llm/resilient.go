@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
+)
+
+// StatusError 大模型服务返回的 HTTP 错误，RetryAfter 为服务要求的等待时间
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// retryAfter 解析 Retry-After（秒数或 HTTP 日期）与 retry-after-ms 响应头
+func retryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := h.Get("Retry-After")
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// Retryable 判断错误是否是暂时的：限流、过载、服务端错误、请求超时、网络错误或没有返回任何选项
+func Retryable(err error) bool {
+	var incomplete *CompletionError
+	if errors.As(err, &incomplete) {
+		return incomplete.Condition == NoChoices
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		switch code := status.StatusCode; {
+		case code == http.StatusRequestTimeout, code == http.StatusConflict, code == http.StatusTooManyRequests, code >= 500:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// Limiter 令牌桶限流器：每秒补充 rate 个令牌，最多积攒 burst 个，可并发使用；nil 表示不限制
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter 创建限流器，rate 不大于 0 时返回 nil
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(max(burst, 1))
+	return &Limiter{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// Wait 取得一个令牌，令牌不足时等待，ctx 取消时返回其错误
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if err := sleep(ctx, wait); err != nil {
+		// 归还预留的令牌
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Resilient 为 Provider 加上单次请求超时、失败重试（指数退避与随机抖动，遵循 Retry-After）、
+// 令牌桶限流与备用模型，可并发使用
+type Resilient struct {
+	Provider Provider
+	Options  config.LLM
+	limiter  *Limiter
+	// OnRetry 非空时在每次重试前调用
+	OnRetry func(model string, retry int, delay time.Duration, err error)
+	// OnFallback 非空时在改用备用模型前调用
+	OnFallback func(from, to string, err error)
+}
+
+// NewResilient 以 opts（零值字段使用默认值）包装 p
+func NewResilient(p Provider, opts config.LLM) *Resilient {
+	opts = opts.WithDefaults()
+	return &Resilient{Provider: p, Options: opts, limiter: NewLimiter(opts.RateLimit, opts.Burst)}
+}
+
+// Complete 依次用请求的模型与备用模型补全，每个模型在暂时性错误时按退避策略重试，
+// 重试耗尽或模型不存在时改用下一个模型；认证失败、请求无效等其他错误、流式请求已输出内容时直接返回。
+// 返回的 Response.Model 为实际使用的模型
+func (r *Resilient) Complete(ctx context.Context, req Request) (*Response, error) {
+	streamed := false
+	if onDelta := req.OnDelta; onDelta != nil {
+		req.OnDelta = func(delta string) {
+			streamed = true
+			onDelta(delta)
+		}
+	}
+	models := []string{req.Model}
+	for _, m := range r.Options.FallbackModels {
+		if m != req.Model {
+			models = append(models, m)
+		}
+	}
+	var err error
+	for i, model := range models {
+		if i > 0 && r.OnFallback != nil {
+			r.OnFallback(models[i-1], model, err)
+		}
+		req.Model = model
+		var resp *Response
+		if resp, err = r.complete(ctx, req, &streamed); err == nil {
+			resp.Model = model
+			return resp, nil
+		}
+		var incomplete *CompletionError
+		if errors.As(err, &incomplete) && incomplete.Response != nil {
+			incomplete.Response.Model = model
+		}
+		if ctx.Err() != nil || streamed || !fallbackable(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// fallbackable 判断改用备用模型是否可能成功：暂时性错误，或模型不存在（404 或 model_not_found）。
+// 认证失败、请求无效、回复被截断或拒绝等错误换模型无济于事
+func fallbackable(err error) bool {
+	if Retryable(err) {
+		return true
+	}
+	var status *StatusError
+	return errors.As(err, &status) && (status.StatusCode == http.StatusNotFound || strings.Contains(status.Error(), "model_not_found"))
+}
+
+// complete 用 req.Model 补全，暂时性错误时重试
+func (r *Resilient) complete(ctx context.Context, req Request, streamed *bool) (*Response, error) {
+	for retry := 0; ; retry++ {
+		if err := r.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		rctx, cancel := context.WithTimeout(ctx, r.Options.Timeout)
+		resp, err := r.Provider.Complete(rctx, req)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil || *streamed || retry >= r.Options.MaxRetries || !Retryable(err) {
+			return nil, err
+		}
+		delay := r.backoff(retry, err)
+		if r.OnRetry != nil {
+			r.OnRetry(req.Model, retry+1, delay, err)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff 返回第 retry 次重试前的等待时间：服务给出 Retry-After 时以其为准，
+// 否则为 Backoff·2^retry 的一半加上随机抖动；两者都不超过 MaxBackoff
+func (r *Resilient) backoff(retry int, err error) time.Duration {
+	var status *StatusError
+	if errors.As(err, &status) && status.RetryAfter > 0 {
+		return min(status.RetryAfter, r.Options.MaxBackoff)
+	}
+	d := r.Options.MaxBackoff
+	if retry < 32 {
+		d = min(r.Options.Backoff<<retry, r.Options.MaxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
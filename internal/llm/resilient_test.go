@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
+)
+
+// funcProvider 用函数实现 Provider
+type funcProvider func(ctx context.Context, req Request) (*Response, error)
+
+func (f funcProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	return f(ctx, req)
+}
+
+func TestResilientRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0.02")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"rate limited"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	r := NewResilient(NewOpenAIClient(config.Config{ApiKey: "k", BaseUrl: srv.URL}), config.LLM{Backoff: time.Hour})
+	var delays []time.Duration
+	r.OnRetry = func(model string, retry int, delay time.Duration, err error) { delays = append(delays, delay) }
+	resp, err := r.Complete(context.Background(), Request{Model: "m", Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "ok" || resp.Model != "m" || requests.Load() != 2 {
+		t.Errorf("unexpected response %+v after %d requests", resp, requests.Load())
+	}
+	// 遵循 Retry-After 而不是一小时的退避
+	if len(delays) != 1 || delays[0] != 20*time.Millisecond {
+		t.Errorf("unexpected retry delays %v", delays)
+	}
+}
+
+func TestBackoffCapsRetryAfter(t *testing.T) {
+	r := NewResilient(nil, config.LLM{Backoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	if d := r.backoff(0, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}); d != 50*time.Millisecond {
+		t.Errorf("expect Retry-After capped at MaxBackoff, got %v", d)
+	}
+	if d := r.backoff(40, errors.New("eof")); d > 50*time.Millisecond {
+		t.Errorf("expect backoff capped at MaxBackoff, got %v", d)
+	}
+}
+
+func TestResilientFallback(t *testing.T) {
+	var calls []string
+	provider := funcProvider(func(ctx context.Context, req Request) (*Response, error) {
+		calls = append(calls, req.Model)
+		switch req.Model {
+		case "primary":
+			return nil, &StatusError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("overloaded")}
+		case "missing":
+			return nil, &StatusError{StatusCode: http.StatusNotFound, Err: errors.New("model not found")}
+		case "unauthorized":
+			return nil, &StatusError{StatusCode: http.StatusUnauthorized, Err: errors.New("invalid api key")}
+		case "bad":
+			return nil, &StatusError{StatusCode: http.StatusBadRequest, Err: errors.New("bad request")}
+		}
+		return &Response{Content: "ok"}, nil
+	})
+	r := NewResilient(provider, config.LLM{MaxRetries: 1, Backoff: time.Millisecond, FallbackModels: []string{"missing", "backup"}})
+	var fallbacks []string
+	r.OnFallback = func(from, to string, err error) { fallbacks = append(fallbacks, from+"->"+to) }
+	resp, err := r.Complete(context.Background(), Request{Model: "primary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// primary 可重试，重试一次后改用 missing；missing 不存在，不重试直接改用 backup
+	if resp.Model != "backup" || fmt.Sprint(calls) != "[primary primary missing backup]" || fmt.Sprint(fallbacks) != "[primary->missing missing->backup]" {
+		t.Errorf("model %s, calls %v, fallbacks %v", resp.Model, calls, fallbacks)
+	}
+
+	// 认证失败与无效请求换模型同样失败，直接返回
+	for _, model := range []string{"unauthorized", "bad"} {
+		calls, fallbacks = nil, nil
+		if _, err := r.Complete(context.Background(), Request{Model: model}); err == nil || len(calls) != 1 || len(fallbacks) != 0 {
+			t.Errorf("%s: expect no fallback, got calls %v, fallbacks %v, err %v", model, calls, fallbacks, err)
+		}
+	}
+}
+
+func TestResilientTimeout(t *testing.T) {
+	var calls atomic.Int32
+	provider := funcProvider(func(ctx context.Context, req Request) (*Response, error) {
+		calls.Add(1)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	r := NewResilient(provider, config.LLM{Timeout: 10 * time.Millisecond, MaxRetries: 2, Backoff: time.Millisecond})
+	if _, err := r.Complete(context.Background(), Request{Model: "m"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expect 3 calls, got %d", calls.Load())
+	}
+
+	// 流式请求已输出内容后不再重试
+	calls.Store(0)
+	stream := funcProvider(func(ctx context.Context, req Request) (*Response, error) {
+		calls.Add(1)
+		req.OnDelta("partial")
+		return nil, &StatusError{StatusCode: http.StatusBadGateway, Err: errors.New("stream broken")}
+	})
+	r = NewResilient(stream, config.LLM{Backoff: time.Millisecond, FallbackModels: []string{"other"}})
+	if _, err := r.Complete(context.Background(), Request{Model: "m", OnDelta: func(string) {}}); err == nil || calls.Load() != 1 {
+		t.Errorf("expect a single failed call, got %d calls and %v", calls.Load(), err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(100, 2)
+	start := time.Now()
+	for range 4 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 前 2 个令牌立即可用，之后每 10ms 补充一个
+	if d := time.Since(start); d < 15*time.Millisecond {
+		t.Errorf("4 requests at 100/s with burst 2 took only %v", d)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewLimiter(0.001, 1).Wait(ctx); err != nil {
+		t.Errorf("first token should be available, got %v", err)
+	}
+	l = NewLimiter(0.001, 1)
+	l.Wait(context.Background())
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expect canceled, got %v", err)
+	}
+	if NewLimiter(0, 1) != nil {
+		t.Error("expect nil limiter without rate")
+	}
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Schema 要求模型按 JSON Schema 输出，不支持结构化输出的 Provider 可以忽略
+type Schema struct {
+	Name        string
+	Description string
+	Schema      map[string]any
+}
+
+// CodeFile 结构化输出中的一个文件
+type CodeFile struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// CodeOutput 结构化输出的代码生成结果
+type CodeOutput struct {
+	Files      []CodeFile `json:"files"`
+	Entrypoint string     `json:"entrypoint"`  // 运行的主文件，有多个主文件时优先运行
+	RunCommand string     `json:"run_command"` // 模型建议的运行命令，仅供参考
+}
+
+// FileMap 返回路径到内容的映射
+func (o *CodeOutput) FileMap() map[string]string {
+	files := make(map[string]string, len(o.Files))
+	for _, f := range o.Files {
+		files[f.Path] = f.Content
+	}
+	return files
+}
+
+// CodeSchema 代码生成结果的 JSON Schema，满足 OpenAI strict 模式的要求（全部字段必填、不允许额外字段、
+// 不使用 minLength 等不支持的关键字），非空等约束由 ParseCodeOutput 检查
+var CodeSchema = &Schema{
+	Name:        "code_output",
+	Description: "generated source files with entrypoint and run command",
+	Schema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"files": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path":     map[string]any{"type": "string"},
+						"language": map[string]any{"type": "string"},
+						"content":  map[string]any{"type": "string"},
+					},
+					"required":             []any{"path", "language", "content"},
+					"additionalProperties": false,
+				},
+			},
+			"entrypoint":  map[string]any{"type": "string"},
+			"run_command": map[string]any{"type": "string"},
+		},
+		"required":             []any{"files", "entrypoint", "run_command"},
+		"additionalProperties": false,
+	},
+}
+
+// IsStructured 判断模型回复是否为 JSON 对象（允许被 ```json 代码块包裹）
+func IsStructured(content string) bool {
+	content = strings.TrimSpace(content)
+	return strings.HasPrefix(content, "{") || strings.HasPrefix(content, "```json")
+}
+
+// ParseCodeOutput 解析结构化输出并按 CodeSchema 校验
+func ParseCodeOutput(content string) (*CodeOutput, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+	var raw any
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return nil, fmt.Errorf("invalid json output: %w", err)
+	}
+	if err := ValidateSchema(CodeSchema.Schema, raw); err != nil {
+		return nil, err
+	}
+	var out CodeOutput
+	if err := json.Unmarshal([]byte(content), &out); err != nil {
+		return nil, fmt.Errorf("invalid json output: %w", err)
+	}
+	if len(out.Files) == 0 {
+		return nil, fmt.Errorf("$.files: expect at least one file")
+	}
+	seen := make(map[string]bool, len(out.Files))
+	for i, f := range out.Files {
+		if strings.TrimSpace(f.Path) == "" || strings.TrimSpace(f.Content) == "" {
+			return nil, fmt.Errorf("$.files[%d]: path and content must not be empty", i)
+		}
+		p := path.Clean(f.Path)
+		if seen[p] {
+			return nil, fmt.Errorf("duplicate file %q", f.Path)
+		}
+		seen[p] = true
+	}
+	if out.Entrypoint != "" && !seen[path.Clean(out.Entrypoint)] {
+		return nil, fmt.Errorf("entrypoint %q is not one of the files", out.Entrypoint)
+	}
+	return &out, nil
+}
+
+// ValidateSchema 按 JSON Schema 的常用子集（type、properties、required、additionalProperties、
+// items、enum、minLength、minItems）校验已解码的 JSON 值
+func ValidateSchema(schema map[string]any, v any) error {
+	return validate(schema, v, "$")
+}
+
+func validate(schema map[string]any, v any, at string) error {
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v is not one of %v", at, v, enum)
+		}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expect object", at)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := obj[name.(string)]; !ok {
+					return fmt.Errorf("%s: missing required field %q", at, name)
+				}
+			}
+		}
+		for name, value := range obj {
+			sub, ok := props[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: unexpected field %q", at, name)
+				}
+				continue
+			}
+			if err := validate(sub, value, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expect array", at)
+		}
+		if n, ok := schema["minItems"].(int); ok && len(arr) < n {
+			return fmt.Errorf("%s: expect at least %d items", at, n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range arr {
+				if err := validate(items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expect string", at)
+		}
+		if n, ok := schema["minLength"].(int); ok && len([]rune(s)) < n {
+			return fmt.Errorf("%s: expect at least %d characters", at, n)
+		}
+	case "integer", "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expect number", at)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expect boolean", at)
+		}
+	}
+	return nil
+}
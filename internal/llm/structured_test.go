@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCodeOutput(t *testing.T) {
+	out, err := ParseCodeOutput("```json\n" + `{"files": [{"path": "main.go", "language": "go", "content": "package main\n\nfunc main() {}\n"}, {"path": "util/util.go", "language": "go", "content": "package util\n"}], "entrypoint": "main.go", "run_command": "go run ."}` + "\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := out.FileMap()
+	if len(files) != 2 || !strings.HasPrefix(files["main.go"], "package main") || out.RunCommand != "go run ." {
+		t.Errorf("unexpected output %+v", out)
+	}
+
+	cases := map[string]string{
+		"not json":           `{"files": [`,
+		"missing field":      `{"files": [{"path": "a.py", "language": "python", "content": "x"}], "entrypoint": "a.py"}`,
+		"wrong type":         `{"files": "a.py", "entrypoint": "", "run_command": ""}`,
+		"extra field":        `{"files": [{"path": "a.py", "language": "python", "content": "x", "mode": 1}], "entrypoint": "", "run_command": ""}`,
+		"no files":           `{"files": [], "entrypoint": "", "run_command": ""}`,
+		"empty content":      `{"files": [{"path": "a.py", "language": "python", "content": " "}], "entrypoint": "", "run_command": ""}`,
+		"unknown entrypoint": `{"files": [{"path": "a.py", "language": "python", "content": "x"}], "entrypoint": "b.py", "run_command": ""}`,
+	}
+	for name, content := range cases {
+		if _, err := ParseCodeOutput(content); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+}
+
+func TestIsStructured(t *testing.T) {
+	if !IsStructured("  {\"files\": []}") || !IsStructured("```json\n{}\n```") {
+		t.Error("expect json output to be structured")
+	}
+	if IsStructured("```go\npackage main\n```") {
+		t.Error("markdown output is not structured")
+	}
+}
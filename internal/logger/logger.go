@@ -2,7 +2,9 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sync"
 )
 
 func Info(args ...interface{}) {
@@ -16,3 +18,48 @@ func Warning(args ...interface{}) {
 func Error(args ...interface{}) {
 	fmt.Fprintln(os.Stderr, "[ERROR]", fmt.Sprint(args...))
 }
+
+// Logger 日志接口，便于嵌入方替换输出
+type Logger interface {
+	Info(args ...interface{})
+	Warning(args ...interface{})
+	Error(args ...interface{})
+}
+
+type std struct{}
+
+func (std) Info(args ...interface{})    { Info(args...) }
+func (std) Warning(args ...interface{}) { Warning(args...) }
+func (std) Error(args ...interface{})   { Error(args...) }
+
+type discard struct{}
+
+func (discard) Info(args ...interface{})    {}
+func (discard) Warning(args ...interface{}) {}
+func (discard) Error(args ...interface{})   {}
+
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *writer) log(level string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, level, fmt.Sprint(args...))
+}
+
+func (l *writer) Info(args ...interface{})    { l.log("[INFO]", args) }
+func (l *writer) Warning(args ...interface{}) { l.log("[WARN]", args) }
+func (l *writer) Error(args ...interface{})   { l.log("[ERROR]", args) }
+
+// New 返回将所有级别的日志写到 w 的 Logger，可并发使用
+func New(w io.Writer) Logger {
+	return &writer{w: w}
+}
+
+// Std 输出到标准输出/标准错误的默认 Logger
+var Std Logger = std{}
+
+// Discard 丢弃所有日志的 Logger
+var Discard Logger = discard{}
@@ -50,3 +50,14 @@ func TestInfoAndWarningAndError(t *testing.T) {
 		t.Errorf("Error log not found: %q", err)
 	}
 }
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf)
+	l.Info("hello", 123)
+	l.Warning("warn")
+	l.Error("err")
+	if got, want := buf.String(), "[INFO] hello123\n[WARN] warn\n[ERROR] err\n"; got != want {
+		t.Errorf("New output = %q, want %q", got, want)
+	}
+}
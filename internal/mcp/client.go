@@ -0,0 +1,493 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Client 连接一个外部 MCP 服务的客户端
+type Client struct {
+	Name string
+	// Server 服务端在 initialize 时返回的信息
+	Server Implementation
+
+	t      transport
+	nextID atomic.Int64
+}
+
+// transport 发送 JSON-RPC 消息；call 等待对应 ID 的响应
+type transport interface {
+	call(ctx context.Context, req *Request) (*Response, error)
+	notify(ctx context.Context, req *Request) error
+	close() error
+}
+
+// Connect 启动（stdio）或连接（HTTP）服务并完成 initialize 握手
+func Connect(ctx context.Context, name string, cfg ServerConfig) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var (
+		t   transport
+		err error
+	)
+	switch {
+	case cfg.Command != "":
+		t, err = startStdio(cfg)
+	case strings.HasSuffix(strings.TrimRight(cfg.URL, "/"), "/sse"):
+		t, err = openSSE(ctx, cfg)
+	default:
+		t = &streamableTransport{url: cfg.URL, headers: cfg.Headers, client: http.DefaultClient}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{Name: name, t: t}
+	var init InitializeResult
+	err = c.request(ctx, "initialize", InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: "aca", Version: "0.1.0"},
+	}, &init)
+	if err == nil {
+		c.Server = init.ServerInfo
+		err = t.notify(ctx, &Request{JSONRPC: "2.0", Method: "notifications/initialized"})
+	}
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	return c, nil
+}
+
+// ListTools 返回服务提供的全部工具
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool 调用工具，工具本身的失败体现在结果的 IsError 中
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var res CallToolResult
+	err := c.request(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &res)
+	return &res, err
+}
+
+// Close 断开连接，stdio 服务会被终止
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+func (c *Client) request(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	resp, err := c.t.call(ctx, &Request{JSONRPC: "2.0", ID: id, Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// pending 按请求 ID 分发异步到达的响应，stdio 与 SSE 传输共用
+type pending struct {
+	mu      sync.Mutex
+	waiting map[string]chan *Response
+	err     error // 连接断开的原因
+}
+
+func (p *pending) add(id json.RawMessage) (chan *Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.waiting == nil {
+		p.waiting = make(map[string]chan *Response)
+	}
+	ch := make(chan *Response, 1)
+	p.waiting[string(id)] = ch
+	return ch, nil
+}
+
+func (p *pending) remove(id json.RawMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.waiting, string(id))
+}
+
+// deliver 处理收到的一条消息，服务端发来的请求交给 reply 响应
+func (p *pending) deliver(msg []byte, reply func([]byte)) {
+	var m struct {
+		Request
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if json.Unmarshal(msg, &m) != nil {
+		return
+	}
+	if m.Method != "" {
+		if m.IsNotification() {
+			return
+		}
+		// 服务端请求：只支持 ping
+		resp := &Response{JSONRPC: "2.0", ID: m.ID, Result: json.RawMessage("{}")}
+		if m.Method != "ping" {
+			resp = errorResponse(m.ID, CodeMethodNotFound, "method not found: "+m.Method)
+		}
+		reply(marshal(resp))
+		return
+	}
+	p.mu.Lock()
+	ch, ok := p.waiting[string(m.ID)]
+	delete(p.waiting, string(m.ID))
+	p.mu.Unlock()
+	if ok {
+		ch <- &Response{JSONRPC: m.JSONRPC, ID: m.ID, Result: m.Result, Error: m.Error}
+	}
+}
+
+// fail 连接断开，唤醒全部等待中的请求
+func (p *pending) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	for id, ch := range p.waiting {
+		close(ch)
+		delete(p.waiting, id)
+	}
+}
+
+func (p *pending) wait(ctx context.Context, id json.RawMessage, ch chan *Response) (*Response, error) {
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return nil, p.err
+		}
+		return resp, nil
+	case <-ctx.Done():
+		p.remove(id)
+		return nil, ctx.Err()
+	}
+}
+
+// stdioTransport 通过子进程的标准输入输出逐行收发消息
+type stdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	mu      sync.Mutex // 保护 stdin 写入
+	pending pending
+	done    chan struct{}
+}
+
+func startStdio(cfg ServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	t := &stdioTransport{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			t.pending.deliver(bytes.Clone(scanner.Bytes()), func(msg []byte) { t.write(msg) })
+		}
+		t.pending.fail(fmt.Errorf("mcp server %s exited", cfg.Command))
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) write(msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.stdin.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, req *Request) (*Response, error) {
+	ch, err := t.pending.add(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.write(marshal(req)); err != nil {
+		t.pending.remove(req.ID)
+		return nil, err
+	}
+	return t.pending.wait(ctx, req.ID, ch)
+}
+
+func (t *stdioTransport) notify(ctx context.Context, req *Request) error {
+	return t.write(marshal(req))
+}
+
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	t.cmd.Process.Kill()
+	<-t.done
+	t.cmd.Wait()
+	return nil
+}
+
+// streamableTransport Streamable HTTP：每条消息一个 POST，响应为 JSON 或 SSE 流
+type streamableTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	session atomic.Value // Mcp-Session-Id
+}
+
+func (t *streamableTransport) post(ctx context.Context, req *Request) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(marshal(req)))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range t.headers {
+		httpReq.Header.Set(k, v)
+	}
+	if id, _ := t.session.Load().(string); id != "" {
+		httpReq.Header.Set("Mcp-Session-Id", id)
+	}
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.session.Store(id)
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s %s", t.url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *streamableTransport) call(ctx context.Context, req *Request) (*Response, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var out Response
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		return &out, nil
+	}
+	// SSE 流中可能夹带服务端通知，取第一个 ID 匹配的响应
+	var found *Response
+	err = readEvents(resp.Body, func(event, data string) bool {
+		var out Response
+		if json.Unmarshal([]byte(data), &out) == nil && string(out.ID) == string(req.ID) {
+			found = &out
+			return false
+		}
+		return true
+	})
+	if found != nil {
+		return found, nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, fmt.Errorf("read event stream: %w", err)
+}
+
+func (t *streamableTransport) notify(ctx context.Context, req *Request) error {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (t *streamableTransport) close() error {
+	return nil
+}
+
+// sseTransport 旧版 HTTP+SSE：GET 建立事件流并取得消息端点，请求 POST 到端点，响应从事件流返回
+type sseTransport struct {
+	endpoint string
+	headers  map[string]string
+	body     io.Closer
+	pending  pending
+}
+
+func openSSE(ctx context.Context, cfg ServerConfig) (*sseTransport, error) {
+	// 事件流的生命周期与连接一致，不随 ctx 取消
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", cfg.URL, resp.Status)
+	}
+	t := &sseTransport{headers: cfg.Headers, body: resp.Body}
+	endpoint := make(chan string, 1)
+	go func() {
+		err := readEvents(resp.Body, func(event, data string) bool {
+			if event == "endpoint" {
+				select {
+				case endpoint <- data:
+				default:
+				}
+				return true
+			}
+			t.pending.deliver([]byte(data), func(msg []byte) { t.post(context.Background(), msg) })
+			return true
+		})
+		if err == nil {
+			err = io.EOF
+		}
+		t.pending.fail(fmt.Errorf("event stream closed: %w", err))
+		close(endpoint)
+	}()
+	select {
+	case e, ok := <-endpoint:
+		if !ok {
+			return nil, fmt.Errorf("%s: event stream closed before endpoint", cfg.URL)
+		}
+		base, _ := url.Parse(cfg.URL)
+		ref, err := url.Parse(e)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+		return t, nil
+	case <-ctx.Done():
+		resp.Body.Close()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *sseTransport) post(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", t.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (t *sseTransport) call(ctx context.Context, req *Request) (*Response, error) {
+	ch, err := t.pending.add(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.post(ctx, marshal(req)); err != nil {
+		t.pending.remove(req.ID)
+		return nil, err
+	}
+	return t.pending.wait(ctx, req.ID, ch)
+}
+
+func (t *sseTransport) notify(ctx context.Context, req *Request) error {
+	return t.post(ctx, marshal(req))
+}
+
+func (t *sseTransport) close() error {
+	return t.body.Close()
+}
+
+// readEvents 逐个读取 SSE 事件，handle 返回 false 时停止
+func readEvents(r io.Reader, handle func(event, data string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 && !handle(event, strings.Join(data, "\n")) {
+				return nil
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return scanner.Err()
+}
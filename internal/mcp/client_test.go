@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+)
+
+// 以 ACA_MCP_STUB=1 运行测试二进制时作为 stdio 桩服务
+func TestMain(m *testing.M) {
+	if os.Getenv("ACA_MCP_STUB") == "1" {
+		testServer().ServeStdio(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(testServer().Handler(httpguard.Options{Token: "s3cret"}))
+	defer srv.Close()
+	auth := map[string]string{"Authorization": "Bearer s3cret"}
+	configs := map[string]ServerConfig{
+		"stdio":      {Command: os.Args[0], Args: []string{"-test.run=^$"}, Env: map[string]string{"ACA_MCP_STUB": "1"}},
+		"streamable": {URL: srv.URL + "/mcp", Headers: auth},
+		"sse":        {URL: srv.URL + "/sse", Headers: auth},
+	}
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c, err := Connect(ctx, name, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if c.Server.Name != "test" {
+				t.Errorf("unexpected server info %+v", c.Server)
+			}
+			tools, err := c.ListTools(ctx)
+			if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+				t.Fatalf("unexpected tools %+v (%v)", tools, err)
+			}
+			res, err := c.CallTool(ctx, "echo", []byte(`{"text":"hi"}`))
+			if err != nil || res.IsError || res.Text() != "hi" {
+				t.Errorf("unexpected result %+v (%v)", res, err)
+			}
+			res, err = c.CallTool(ctx, "echo", nil)
+			if err != nil || !res.IsError {
+				t.Errorf("expect tool error, got %+v (%v)", res, err)
+			}
+			if _, err := c.CallTool(ctx, "nope", nil); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+				t.Errorf("expect protocol error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadServers(t *testing.T) {
+	path := t.TempDir() + "/mcp.json"
+	os.WriteFile(path, []byte(`{"mcpServers": {"docs": {"url": "https://example.com/mcp"}, "search": {"command": "search-mcp", "tools": ["grep"]}}}`), 0644)
+	servers, err := LoadServers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if servers["docs"].URL == "" || servers["search"].Tools[0] != "grep" {
+		t.Errorf("unexpected servers %+v", servers)
+	}
+	os.WriteFile(path, []byte(`{"mcpServers": {"bad": {}}}`), 0644)
+	if _, err := LoadServers(path); err == nil {
+		t.Error("expect error for server without command or url")
+	}
+	if ToolName("my docs", "search.v2") != "my_docs__search_v2" {
+		t.Errorf("unexpected tool name %s", ToolName("my docs", "search.v2"))
+	}
+}
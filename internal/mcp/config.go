@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ServerConfig 一个外部 MCP 服务的配置，与 .cursor/mcp.json 中 mcpServers 的条目格式相同：
+// Command 为 stdio 服务的启动命令，URL 为 HTTP 服务地址（以 /sse 结尾时使用旧版 SSE 传输）
+type ServerConfig struct {
+	Command string            `json:"command,omitempty" yaml:"command"`
+	Args    []string          `json:"args,omitempty" yaml:"args"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env"`
+	URL     string            `json:"url,omitempty" yaml:"url"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers"`
+	// Tools 允许模型调用的工具名，为空时允许全部
+	Tools    []string `json:"tools,omitempty" yaml:"tools"`
+	Disabled bool     `json:"disabled,omitempty" yaml:"disabled"`
+}
+
+// Validate 检查 Command 与 URL 有且只有一个
+func (c ServerConfig) Validate() error {
+	if (c.Command == "") == (c.URL == "") {
+		return fmt.Errorf("exactly one of command and url must be set")
+	}
+	return nil
+}
+
+// LoadServers 读取 .cursor/mcp.json 格式的文件，返回其中的 mcpServers
+func LoadServers(path string) (map[string]ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		MCPServers map[string]ServerConfig `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return file.MCPServers, ValidateServers(file.MCPServers)
+}
+
+// ValidateServers 按名称顺序检查每个服务的配置
+func ValidateServers(servers map[string]ServerConfig) error {
+	for _, name := range ServerNames(servers) {
+		if err := servers[name].Validate(); err != nil {
+			return fmt.Errorf("mcp server %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ServerNames 返回按名称排序的服务名
+func ServerNames(servers map[string]ServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
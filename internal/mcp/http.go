@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+)
+
+// maxBodySize HTTP 请求体的最大字节数
+const maxBodySize = 64 << 20
+
+// Handler 返回 HTTP 传输：
+//   - POST /mcp：Streamable HTTP，响应直接以 application/json 返回
+//   - GET /sse 与 POST /message?sessionId=：旧版 HTTP+SSE 传输，响应通过 SSE 流推送
+//
+// 所有请求按 guard 校验 Host、Origin 与令牌，POST 请求体必须是 application/json
+func (s *Server) Handler(guard httpguard.Options) http.Handler {
+	h := &httpTransport{server: s, streams: make(map[string]*sseStream)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", h.streamable)
+	mux.HandleFunc("/sse", h.sse)
+	mux.HandleFunc("/message", h.message)
+	return httpguard.Wrap(mux, guard)
+}
+
+type httpTransport struct {
+	server  *Server
+	mu      sync.Mutex
+	streams map[string]*sseStream
+}
+
+// sseStream 一个 SSE 连接，ctx 在连接断开时取消
+type sseStream struct {
+	ctx context.Context
+	ch  chan []byte
+}
+
+func (h *httpTransport) streamable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !httpguard.RequireJSON(w, r) {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := h.server.Handle(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+func (h *httpTransport) sse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	id := newSessionID()
+	stream := &sseStream{ctx: r.Context(), ch: make(chan []byte, 16)}
+	h.mu.Lock()
+	h.streams[id] = stream
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.streams, id)
+		h.mu.Unlock()
+	}()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\n\n", id)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-stream.ch:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *httpTransport) message(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !httpguard.RequireJSON(w, r) {
+		return
+	}
+	id := r.URL.Query().Get("sessionId")
+	h.mu.Lock()
+	stream, ok := h.streams[id]
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	// 响应通过 SSE 流返回，工具调用可能耗时较长，不阻塞本次请求；SSE 连接断开时取消并丢弃
+	go func() {
+		if resp := h.server.Handle(stream.ctx, body); resp != nil {
+			select {
+			case stream.ch <- resp:
+			case <-stream.ctx.Done():
+			}
+		}
+	}()
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,291 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// CodeResourceNotFound 资源不存在
+const CodeResourceNotFound = -32002
+
+// ToolHandler 执行工具调用；返回的 error 作为工具失败（IsError）告诉客户端
+type ToolHandler func(ctx context.Context, args json.RawMessage) (*CallToolResult, error)
+
+// Resources 服务端提供的资源
+type Resources interface {
+	ListResources() ([]Resource, error)
+	ResourceTemplates() []ResourceTemplate
+	ReadResource(uri string) ([]ResourceContents, error)
+}
+
+// Server MCP 服务端，注册工具与资源后通过 ServeStdio 或 Handler 提供服务
+type Server struct {
+	Info         Implementation
+	Instructions string
+	Resources    Resources // 为 nil 时不提供资源
+
+	mu       sync.RWMutex
+	tools    map[string]Tool
+	handlers map[string]ToolHandler
+}
+
+// NewServer 创建服务端
+func NewServer(name, version string) *Server {
+	return &Server{
+		Info:     Implementation{Name: name, Version: version},
+		tools:    make(map[string]Tool),
+		handlers: make(map[string]ToolHandler),
+	}
+}
+
+// AddTool 注册工具，同名工具会被替换
+func (s *Server) AddTool(tool Tool, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools[tool.Name] = tool
+	s.handlers[tool.Name] = handler
+}
+
+// Tools 返回已注册的工具（按名称排序）
+func (s *Server) Tools() []Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tools := make([]Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Handle 处理一条 JSON-RPC 消息（单个请求或批量请求），没有需要返回的响应时返回 nil
+func (s *Server) Handle(ctx context.Context, msg []byte) []byte {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) > 0 && msg[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			return marshal(errorResponse(nil, CodeParseError, err.Error()))
+		}
+		var out []json.RawMessage
+		for _, m := range batch {
+			if resp := s.handleOne(ctx, m); resp != nil {
+				out = append(out, marshal(resp))
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return marshal(out)
+	}
+	if resp := s.handleOne(ctx, msg); resp != nil {
+		return marshal(resp)
+	}
+	return nil
+}
+
+func (s *Server) handleOne(ctx context.Context, msg []byte) *Response {
+	var req struct {
+		Request
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return errorResponse(nil, CodeParseError, err.Error())
+	}
+	if req.Method == "" && (req.Result != nil || req.Error != nil) {
+		// 客户端发来的响应，服务端不发请求，忽略
+		return nil
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request")
+	}
+	result, rpcErr := s.dispatch(ctx, &req.Request)
+	if req.IsNotification() {
+		return nil
+	}
+	if rpcErr != nil {
+		return &Response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, CodeInternalError, err.Error())
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: data}
+}
+
+func (s *Server) dispatch(ctx context.Context, req *Request) (any, *Error) {
+	switch req.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		caps := map[string]any{"tools": map[string]any{}}
+		if s.Resources != nil {
+			caps["resources"] = map[string]any{}
+		}
+		return InitializeResult{ProtocolVersion: version, Capabilities: caps, ServerInfo: s.Info, Instructions: s.Instructions}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.Tools()}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	case "resources/list":
+		if s.Resources == nil {
+			return map[string]any{"resources": []Resource{}}, nil
+		}
+		resources, err := s.Resources.ListResources()
+		if err != nil {
+			return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		if resources == nil {
+			resources = []Resource{}
+		}
+		return map[string]any{"resources": resources}, nil
+	case "resources/templates/list":
+		templates := []ResourceTemplate{}
+		if s.Resources != nil {
+			templates = append(templates, s.Resources.ResourceTemplates()...)
+		}
+		return map[string]any{"resourceTemplates": templates}, nil
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if s.Resources == nil {
+			return nil, &Error{Code: CodeResourceNotFound, Message: "resource not found: " + params.URI}
+		}
+		contents, err := s.Resources.ReadResource(params.URI)
+		if err != nil {
+			return nil, &Error{Code: CodeResourceNotFound, Message: err.Error()}
+		}
+		return map[string]any{"contents": contents}, nil
+	}
+	if req.IsNotification() {
+		// notifications/initialized、notifications/cancelled 等无需处理
+		return nil, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, *Error) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	handler, ok := s.handlers[params.Name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+	if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+		params.Arguments = json.RawMessage("{}")
+	}
+	res, err := handler(ctx, params.Arguments)
+	if err != nil {
+		if res == nil {
+			res = &CallToolResult{}
+		}
+		res.Content = append(res.Content, TextContent("error: "+err.Error()))
+		res.IsError = true
+	}
+	if res == nil {
+		res = &CallToolResult{}
+	}
+	if res.Content == nil {
+		res.Content = []Content{}
+	}
+	return res, nil
+}
+
+// ServeStdio 从 in 逐行读取 JSON-RPC 消息并把响应逐行写到 out，直到 in 结束或 ctx 取消。
+// 请求并发处理，耗时的工具调用不会阻塞 ping 等请求
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	write := func(data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		out.Write(append(data, '\n'))
+	}
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			select {
+			case lines <- bytes.Clone(line):
+			case <-ctx.Done():
+				return
+			}
+		}
+		errc <- scanner.Err()
+	}()
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			return err
+		case line := <-lines:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := s.Handle(ctx, line); resp != nil {
+					write(resp)
+				}
+			}()
+		}
+	}
+}
+
+func unmarshalParams(raw json.RawMessage, v any) *Error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: "2.0", ID: id, Error: &Error{Code: code, Message: message}}
+}
+
+func marshal(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+)
+
+func testServer() *Server {
+	s := NewServer("test", "1.0")
+	s.AddTool(Tool{Name: "echo", InputSchema: map[string]any{"type": "object"}}, func(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+		var in struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(args, &in); err != nil {
+			return nil, err
+		}
+		if in.Text == "" {
+			return nil, errors.New("text is required")
+		}
+		return &CallToolResult{Content: []Content{TextContent(in.Text)}}, nil
+	})
+	return s
+}
+
+func call(t *testing.T, s *Server, msg string) Response {
+	t.Helper()
+	out := s.Handle(context.Background(), []byte(msg))
+	var resp Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", out, err)
+	}
+	return resp
+}
+
+func TestHandle(t *testing.T) {
+	s := testServer()
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"c","version":"1"}}}`)
+	var init InitializeResult
+	json.Unmarshal(resp.Result, &init)
+	if init.ProtocolVersion != "2024-11-05" || init.ServerInfo.Name != "test" || init.Capabilities["tools"] == nil {
+		t.Errorf("unexpected initialize result %s", resp.Result)
+	}
+	if out := s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); out != nil {
+		t.Errorf("expect no response to notification, got %s", out)
+	}
+	resp = call(t, s, `{"jsonrpc":"2.0","id":"a","method":"tools/list"}`)
+	if string(resp.ID) != `"a"` || !strings.Contains(string(resp.Result), `"name":"echo"`) {
+		t.Errorf("unexpected tools/list response %+v", resp)
+	}
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`)
+	var res CallToolResult
+	json.Unmarshal(resp.Result, &res)
+	if res.IsError || res.Text() != "hi" {
+		t.Errorf("unexpected tools/call result %s", resp.Result)
+	}
+	// 工具失败作为 isError 结果返回
+	resp = call(t, s, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo"}}`)
+	json.Unmarshal(resp.Result, &res)
+	if !res.IsError || !strings.Contains(res.Text(), "text is required") {
+		t.Errorf("expect tool error, got %s", resp.Result)
+	}
+	cases := map[string]int{
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"nope"}}`: CodeInvalidParams,
+		`{"jsonrpc":"2.0","id":5,"method":"nope"}`:                                CodeMethodNotFound,
+		`{"jsonrpc":"2.0","id":6,"method":"resources/read","params":{"uri":"x"}}`: CodeResourceNotFound,
+		`{`: CodeParseError,
+	}
+	for msg, code := range cases {
+		if resp := call(t, s, msg); resp.Error == nil || resp.Error.Code != code {
+			t.Errorf("%s: expect error %d, got %+v", msg, code, resp.Error)
+		}
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`[{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"x"}}}]` + "\n")
+	var out strings.Builder
+	if err := testServer().ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expect 2 responses, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), `"id":1,"result":{}`) || !strings.Contains(out.String(), `[{"jsonrpc":"2.0","id":2`) {
+		t.Errorf("unexpected responses %q", out.String())
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(testServer().Handler(httpguard.Options{}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/mcp", "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/json" || !strings.Contains(string(body), "echo") {
+		t.Errorf("unexpected response %s", body)
+	}
+
+	// 旧版 SSE 传输：先取得消息端点，响应通过事件流返回
+	stream, err := http.Get(srv.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	events := bufio.NewReader(stream.Body)
+	next := func() string {
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				return strings.TrimSpace(data)
+			}
+		}
+	}
+	endpoint := next()
+	if !strings.HasPrefix(endpoint, "/message?sessionId=") {
+		t.Fatalf("unexpected endpoint %q", endpoint)
+	}
+	resp, err = http.Post(srv.URL+endpoint, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"ping"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expect 202, got %d", resp.StatusCode)
+	}
+	if msg := next(); msg != `{"jsonrpc":"2.0","id":7,"result":{}}` {
+		t.Errorf("unexpected message %s", msg)
+	}
+	resp, _ = http.Post(srv.URL+"/message?sessionId=nope", "application/json", strings.NewReader(`{}`))
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expect 404 for unknown session, got %d", resp.StatusCode)
+	}
+
+	// 跨站表单提交与其他来源的请求被拒绝
+	resp, _ = http.Post(srv.URL+"/mcp", "text/plain", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expect 415 for non-JSON body, got %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://evil.example")
+	resp, _ = http.DefaultClient.Do(req)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expect 403 for cross-origin request, got %d", resp.StatusCode)
+	}
+}
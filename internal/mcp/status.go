@@ -0,0 +1,22 @@
+package mcp
+
+import "regexp"
+
+// ServerStatus 一个外部服务的连接结果，记录在会话中
+type ServerStatus struct {
+	Name  string   `json:"name"`
+	Tools []string `json:"tools,omitempty"` // 提供给模型的工具名
+	Error string   `json:"error,omitempty"` // 连接或列出工具失败的原因
+}
+
+// invalidToolChars 模型工具名只能包含字母、数字、下划线与连字符
+var invalidToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolName 返回服务 server 的工具 tool 提供给模型时使用的名称
+func ToolName(server, tool string) string {
+	name := invalidToolChars.ReplaceAllString(server, "_") + "__" + invalidToolChars.ReplaceAllString(tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,331 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/diff"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// ConflictError 补丁与文件当前内容不匹配
+type ConflictError struct {
+	Path   string
+	Hunk   int // 从 1 开始，编辑块同样按序编号
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: hunk %d: %s", e.Path, e.Hunk, e.Reason)
+}
+
+// Change 一个文件在应用补丁前后的内容，nil 表示文件不存在
+type Change struct {
+	Path   string
+	Before *string
+	After  *string
+}
+
+// Result 应用补丁的结果，可用于回滚
+type Result struct {
+	Dir     string
+	Changes []Change
+}
+
+// Diff 返回本次修改的 unified diff
+func (r *Result) Diff() string {
+	var sb strings.Builder
+	for _, c := range r.Changes {
+		oldName, newName := "a/"+c.Path, "b/"+c.Path
+		if c.Before == nil {
+			oldName = DevNull
+		}
+		if c.After == nil {
+			newName = DevNull
+		}
+		sb.WriteString(diff.Unified(oldName, newName, deref(c.Before), deref(c.After), 3))
+	}
+	return sb.String()
+}
+
+// Revert 将文件恢复为应用补丁前的内容
+func (r *Result) Revert() error {
+	for _, c := range r.Changes {
+		path := filepath.Join(r.Dir, c.Path)
+		if c.Before == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(*c.Before), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Options 应用补丁的容错设置
+type Options struct {
+	// Fuzz 匹配失败时允许忽略的 hunk 首尾上下文行数
+	Fuzz int
+	// IgnoreWhitespace 精确匹配失败时忽略行首尾空白再匹配
+	IgnoreWhitespace bool
+}
+
+// DefaultOptions 默认容错：最多忽略 2 行上下文，允许空白差异
+var DefaultOptions = Options{Fuzz: 2, IgnoreWhitespace: true}
+
+// Apply 先在内存中校验并应用全部修改，全部成功后才写入 dir；任何文件冲突时不修改磁盘
+func Apply(dir string, p *Patch, opts Options) (*Result, error) {
+	contents := make(map[string]*string)
+	load := func(path string) (*string, error) {
+		if c, ok := contents[path]; ok {
+			return c, nil
+		}
+		full, err := workspace.SafePath(dir, path)
+		if err != nil {
+			return nil, &ConflictError{Path: path, Reason: err.Error()}
+		}
+		data, err := os.ReadFile(full)
+		if os.IsNotExist(err) {
+			contents[path] = nil
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s := string(data)
+		contents[path] = &s
+		return &s, nil
+	}
+
+	before := make(map[string]*string)
+	for _, path := range p.Paths() {
+		c, err := load(path)
+		if err != nil {
+			return nil, err
+		}
+		before[path] = c
+	}
+	for _, f := range p.Files {
+		path, src := f.Path(), f.Path()
+		if f.Renamed() {
+			// 重命名：对旧文件应用修改后写到新路径并删除旧文件
+			src = f.OldPath
+			if contents[path] != nil {
+				return nil, &ConflictError{Path: path, Hunk: 1, Reason: "rename target already exists"}
+			}
+		}
+		after, err := applyFilePatch(before[src], contents[src], f, opts)
+		if err != nil {
+			return nil, err
+		}
+		contents[src] = nil
+		contents[path] = after
+	}
+	for i, e := range p.Edits {
+		after, err := applyEdit(contents[e.Path], e, opts)
+		if err != nil {
+			if ce, ok := err.(*ConflictError); ok {
+				ce.Hunk = i + 1
+			}
+			return nil, err
+		}
+		contents[e.Path] = after
+	}
+
+	res := &Result{Dir: dir}
+	paths := p.Paths()
+	sort.Strings(paths)
+	for _, path := range paths {
+		if deref(before[path]) == deref(contents[path]) && (before[path] == nil) == (contents[path] == nil) {
+			continue
+		}
+		res.Changes = append(res.Changes, Change{Path: path, Before: before[path], After: contents[path]})
+	}
+	for i, c := range res.Changes {
+		full := filepath.Join(dir, c.Path)
+		var err error
+		if c.After == nil {
+			err = os.Remove(full)
+		} else if err = os.MkdirAll(filepath.Dir(full), 0755); err == nil {
+			err = os.WriteFile(full, []byte(*c.After), 0644)
+		}
+		if err != nil {
+			// 回滚已写入的文件
+			(&Result{Dir: dir, Changes: res.Changes[:i]}).Revert()
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// applyFilePatch 对单个文件应用 unified diff
+func applyFilePatch(original, current *string, f FilePatch, opts Options) (*string, error) {
+	path := f.Path()
+	if f.OldPath == DevNull && current != nil && original != nil {
+		return nil, &ConflictError{Path: path, Hunk: 1, Reason: "file already exists"}
+	}
+	if f.OldPath != DevNull && current == nil {
+		return nil, &ConflictError{Path: path, Hunk: 1, Reason: "file does not exist"}
+	}
+	lines := diff.SplitLines(deref(current))
+	offset := 0
+	for i, h := range f.Hunks {
+		var oldLines, newLines []string
+		for _, l := range h.Lines {
+			if l.Op != diff.OpInsert {
+				oldLines = append(oldLines, l.Text)
+			}
+			if l.Op != diff.OpDelete {
+				newLines = append(newLines, l.Text)
+			}
+		}
+		pos, lead, trail, ok := locate(lines, oldLines, h, h.OldStart-1+offset, opts)
+		if !ok {
+			return nil, &ConflictError{Path: path, Hunk: i + 1, Reason: "context does not match file content"}
+		}
+		// 被 fuzz 忽略的上下文行不参与替换
+		replaced := len(oldLines) - lead - trail
+		newPart := newLines[lead : len(newLines)-trail]
+		lines = append(lines[:pos], append(append([]string(nil), newPart...), lines[pos+replaced:]...)...)
+		offset += len(newPart) - replaced
+	}
+	if f.NewPath == DevNull {
+		if len(lines) > 0 {
+			return nil, &ConflictError{Path: path, Hunk: len(f.Hunks), Reason: "deleted file still has content"}
+		}
+		return nil, nil
+	}
+	out := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		out += "\n"
+	}
+	return &out, nil
+}
+
+// locate 查找 hunk 旧内容在文件中的位置，优先靠近 expected 的位置；
+// 精确匹配失败时依次尝试忽略空白与 fuzz，返回匹配位置及忽略的首尾上下文行数
+func locate(lines, old []string, h Hunk, expected int, opts Options) (pos, lead, trail int, ok bool) {
+	eqs := []func(a, b string) bool{func(a, b string) bool { return a == b }}
+	if opts.IgnoreWhitespace {
+		eqs = append(eqs, func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) })
+	}
+	// 首尾可以被忽略的上下文行数
+	maxLead, maxTrail := 0, 0
+	for maxLead < len(h.Lines) && h.Lines[maxLead].Op == diff.OpEqual {
+		maxLead++
+	}
+	for maxTrail < len(h.Lines) && h.Lines[len(h.Lines)-1-maxTrail].Op == diff.OpEqual {
+		maxTrail++
+	}
+	for fuzz := 0; fuzz <= opts.Fuzz; fuzz++ {
+		lead, trail = min(fuzz, maxLead), min(fuzz, maxTrail)
+		if fuzz > 0 && lead+trail == 0 {
+			break
+		}
+		if lead+trail >= len(old) && len(old) > 0 {
+			break
+		}
+		part := old[lead : len(old)-trail]
+		for _, eq := range eqs {
+			if pos, ok = search(lines, part, expected+lead, eq); ok {
+				return pos, lead, trail, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// search 从 expected 开始向两侧查找 part 在 lines 中的位置
+func search(lines, part []string, expected int, eq func(a, b string) bool) (int, bool) {
+	if len(part) == 0 {
+		return max(0, min(expected, len(lines))), true
+	}
+	matchAt := func(pos int) bool {
+		if pos < 0 || pos+len(part) > len(lines) {
+			return false
+		}
+		for i, l := range part {
+			if !eq(lines[pos+i], l) {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; d <= len(lines); d++ {
+		if matchAt(expected - d) {
+			return expected - d, true
+		}
+		if d > 0 && matchAt(expected+d) {
+			return expected + d, true
+		}
+	}
+	return 0, false
+}
+
+// applyEdit 应用一个 search/replace 编辑块，Search 必须在文件中唯一出现
+func applyEdit(current *string, e Edit, opts Options) (*string, error) {
+	if e.Search == "" {
+		if current != nil && *current != "" {
+			return nil, &ConflictError{Path: e.Path, Reason: "empty search block but file already exists"}
+		}
+		s := e.Replace
+		return &s, nil
+	}
+	if current == nil {
+		return nil, &ConflictError{Path: e.Path, Reason: "file does not exist"}
+	}
+	switch n := strings.Count(*current, e.Search); {
+	case n == 1:
+		s := strings.Replace(*current, e.Search, e.Replace, 1)
+		return &s, nil
+	case n > 1:
+		return nil, &ConflictError{Path: e.Path, Reason: "search block matches multiple locations"}
+	}
+	if !opts.IgnoreWhitespace {
+		return nil, &ConflictError{Path: e.Path, Reason: "search block not found"}
+	}
+	// 逐行忽略首尾空白匹配
+	lines := diff.SplitLines(*current)
+	search := diff.SplitLines(e.Search)
+	eq := func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) }
+	pos, ok := search1(lines, search, eq)
+	if !ok {
+		return nil, &ConflictError{Path: e.Path, Reason: "search block not found"}
+	}
+	if _, dup := search1(lines[pos+1:], search, eq); dup {
+		return nil, &ConflictError{Path: e.Path, Reason: "search block matches multiple locations"}
+	}
+	out := append(append(append([]string(nil), lines[:pos]...), diff.SplitLines(e.Replace)...), lines[pos+len(search):]...)
+	s := strings.Join(out, "\n") + "\n"
+	return &s, nil
+}
+
+// search1 返回 part 在 lines 中第一次出现的位置
+func search1(lines, part []string, eq func(a, b string) bool) (int, bool) {
+	for pos := 0; pos+len(part) <= len(lines); pos++ {
+		match := true
+		for i, l := range part {
+			if !eq(lines[pos+i], l) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return pos, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,251 @@
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/diff"
+)
+
+// DevNull unified diff 中表示文件不存在的路径
+const DevNull = "/dev/null"
+
+// Hunk unified diff 中的一段修改，Lines 包含上下文、删除与新增行
+type Hunk struct {
+	OldStart int
+	Lines    []diff.Line
+}
+
+// FilePatch 对单个文件的 unified diff
+type FilePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Path 返回补丁作用的文件路径（删除文件时为旧路径，重命名时为新路径）
+func (f FilePatch) Path() string {
+	if f.NewPath == DevNull {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// Renamed 补丁是否将 OldPath 重命名为 NewPath
+func (f FilePatch) Renamed() bool {
+	return f.OldPath != DevNull && f.NewPath != DevNull && f.OldPath != f.NewPath
+}
+
+// Edit search/replace 形式的编辑块，Search 为空表示新建文件
+type Edit struct {
+	Path    string
+	Search  string
+	Replace string
+}
+
+// Patch 从模型回复中解析出的修改，Files 与 Edits 至多一个非空
+type Patch struct {
+	Files []FilePatch
+	Edits []Edit
+}
+
+// Paths 返回补丁涉及的全部文件路径，重命名的新旧路径都包括在内
+func (p *Patch) Paths() []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			out = append(out, path)
+		}
+	}
+	for _, f := range p.Files {
+		if f.Renamed() {
+			add(f.OldPath)
+		}
+		add(f.Path())
+	}
+	for _, e := range p.Edits {
+		add(e.Path)
+	}
+	return out
+}
+
+// Empty 补丁是否不含任何修改
+func (p *Patch) Empty() bool {
+	return len(p.Files) == 0 && len(p.Edits) == 0
+}
+
+const (
+	searchMarker  = "<<<<<<< SEARCH"
+	dividerMarker = "======="
+	replaceMarker = ">>>>>>> REPLACE"
+)
+
+// Parse 从模型回复中解析补丁：包含 SEARCH/REPLACE 标记时按编辑块解析，否则按 unified diff 解析
+func Parse(text string) (*Patch, error) {
+	if strings.Contains(text, searchMarker) {
+		edits, err := ParseEdits(text)
+		if err != nil {
+			return nil, err
+		}
+		return &Patch{Edits: edits}, nil
+	}
+	files, err := ParseUnified(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Patch{Files: files}, nil
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// ParseUnified 解析 unified diff。模型给出的 hunk 行数经常不准确，因此忽略头部的行数，
+// 以下一个 hunk 头、文件头或代码块结束为 hunk 边界。
+func ParseUnified(text string) ([]FilePatch, error) {
+	var files []FilePatch
+	var cur *FilePatch
+	var hunk *Hunk
+	flushHunk := func() {
+		if cur != nil && hunk != nil {
+			// 去掉末尾因代码块换行产生的空上下文行
+			for len(hunk.Lines) > 0 && hunk.Lines[len(hunk.Lines)-1] == (diff.Line{Op: diff.OpEqual}) {
+				hunk.Lines = hunk.Lines[:len(hunk.Lines)-1]
+			}
+			cur.Hunks = append(cur.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			flushHunk()
+			files = append(files, FilePatch{
+				OldPath: diffPath(line[4:]),
+				NewPath: diffPath(strings.TrimSuffix(lines[i+1], "\r")[4:]),
+			})
+			cur = &files[len(files)-1]
+			i++
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			m := hunkHeaderRe.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", i+1, line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			hunk = &Hunk{OldStart: start}
+		case hunk == nil:
+			// 文件头之外的说明文字、diff --git、index 等行
+		case strings.HasPrefix(line, "```"):
+			flushHunk()
+		case strings.HasPrefix(line, `\`):
+			// \ No newline at end of file
+		case line == "":
+			hunk.Lines = append(hunk.Lines, diff.Line{Op: diff.OpEqual})
+		case line[0] == diff.OpEqual || line[0] == diff.OpDelete || line[0] == diff.OpInsert:
+			hunk.Lines = append(hunk.Lines, diff.Line{Op: line[0], Text: line[1:]})
+		default:
+			flushHunk()
+		}
+	}
+	flushHunk()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no unified diff found")
+	}
+	for _, f := range files {
+		if len(f.Hunks) == 0 {
+			return nil, fmt.Errorf("diff for %s has no hunks", f.Path())
+		}
+	}
+	return files, nil
+}
+
+// diffPath 去掉 a/ b/ 前缀与时间戳
+func diffPath(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if s == DevNull {
+		return s
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// ParseEdits 解析 search/replace 编辑块，文件名取 SEARCH 标记前最近的非空行：
+//
+//	path/to/file.go
+//	<<<<<<< SEARCH
+//	old
+//	=======
+//	new
+//	>>>>>>> REPLACE
+func ParseEdits(text string) ([]Edit, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var edits []Edit
+	path := ""
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line != searchMarker {
+			if p := editPath(line); p != "" {
+				path = p
+			}
+			continue
+		}
+		if path == "" {
+			return nil, fmt.Errorf("line %d: edit block without file name", i+1)
+		}
+		var search, replace []string
+		j := i + 1
+		for ; j < len(lines) && strings.TrimSpace(lines[j]) != dividerMarker; j++ {
+			search = append(search, lines[j])
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("line %d: edit block for %s missing %s", i+1, path, dividerMarker)
+		}
+		for j++; j < len(lines) && strings.TrimSpace(lines[j]) != replaceMarker; j++ {
+			replace = append(replace, lines[j])
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("line %d: edit block for %s missing %s", i+1, path, replaceMarker)
+		}
+		edits = append(edits, Edit{Path: path, Search: joinLines(search), Replace: joinLines(replace)})
+		i = j
+	}
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("no edit blocks found")
+	}
+	return edits, nil
+}
+
+// editPath 从编辑块前的一行中识别文件名，允许 `// file.go`、`# file.py`、**file** 等写法
+func editPath(line string) string {
+	if line == "" || strings.HasPrefix(line, "```") || line == dividerMarker || line == replaceMarker {
+		return ""
+	}
+	for _, prefix := range []string{"//", "#", "File:", "file:"} {
+		line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	}
+	line = strings.Trim(line, "*`:")
+	if line == "" || strings.ContainsAny(line, " \t") || !strings.Contains(line, ".") {
+		return ""
+	}
+	return line
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,182 @@
+package patch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const source = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseUnified(t *testing.T) {
+	text := "修改如下:\n```diff\n--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"world\")\n }\n```\n"
+	p, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Files) != 1 || p.Files[0].Path() != "main.go" || len(p.Files[0].Hunks) != 1 {
+		t.Fatalf("unexpected patch %+v", p)
+	}
+	if h := p.Files[0].Hunks[0]; h.OldStart != 5 || len(h.Lines) != 4 {
+		t.Errorf("unexpected hunk %+v", h)
+	}
+}
+
+func TestParseEdits(t *testing.T) {
+	text := "```go\n// main.go\n<<<<<<< SEARCH\n\tfmt.Println(\"hello\")\n=======\n\tfmt.Println(\"world\")\n>>>>>>> REPLACE\n```\n\n**util.go**\n<<<<<<< SEARCH\n=======\npackage main\n>>>>>>> REPLACE\n"
+	p, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Edits) != 2 || p.Edits[0].Path != "main.go" || p.Edits[1].Path != "util.go" {
+		t.Fatalf("unexpected edits %+v", p.Edits)
+	}
+	if p.Edits[0].Search != "\tfmt.Println(\"hello\")\n" || p.Edits[1].Search != "" {
+		t.Errorf("unexpected edit content %+v", p.Edits)
+	}
+	if _, err := Parse("<<<<<<< SEARCH\nx\n=======\ny\n>>>>>>> REPLACE"); err == nil {
+		t.Errorf("edit block without file name should fail")
+	}
+	if _, err := Parse("no patch here"); err == nil {
+		t.Errorf("text without patch should fail")
+	}
+}
+
+func TestApplyUnifiedWithOffsetAndFuzz(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", "// header\n"+source)
+	// 行号偏移 1 行，且首行上下文与文件不一致
+	text := "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() { // entry\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"world\")\n }\n"
+	p, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(dir, p, Options{}); err == nil {
+		t.Fatalf("expect conflict without fuzz")
+	}
+	res, err := Apply(dir, p, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "// header\npackage main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"world\")\n}\n"
+	if got := readFile(t, dir, "main.go"); got != expect {
+		t.Errorf("unexpected content:\n%s", got)
+	}
+	if res.Diff() == "" {
+		t.Errorf("expect non-empty diff")
+	}
+	if err := res.Revert(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, dir, "main.go"); got != "// header\n"+source {
+		t.Errorf("revert did not restore content:\n%s", got)
+	}
+}
+
+func TestApplyEditsIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", source)
+	p := &Patch{Edits: []Edit{
+		{Path: "main.go", Search: "\"hello\"", Replace: "\"world\""},
+		{Path: "util.go", Search: "", Replace: "package main\n"},
+		{Path: "main.go", Search: "missing", Replace: "x"},
+	}}
+	_, err := Apply(dir, p, DefaultOptions)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Hunk != 3 {
+		t.Fatalf("expect conflict in edit 3, got %v", err)
+	}
+	if readFile(t, dir, "main.go") != source {
+		t.Errorf("failed patch should not modify files")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "util.go")); !os.IsNotExist(err) {
+		t.Errorf("failed patch should not create files")
+	}
+
+	res, err := Apply(dir, &Patch{Edits: p.Edits[:2]}, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 2 || readFile(t, dir, "util.go") != "package main\n" {
+		t.Errorf("unexpected changes %+v", res.Changes)
+	}
+	res.Revert()
+	if _, err := os.Stat(filepath.Join(dir, "util.go")); !os.IsNotExist(err) {
+		t.Errorf("revert should remove created files")
+	}
+}
+
+func TestApplyEditIgnoresWhitespace(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", source)
+	p := &Patch{Edits: []Edit{{Path: "main.go", Search: "func main() {\n    fmt.Println(\"hello\")\n", Replace: "func main() {\n\tfmt.Println(\"hi\")\n"}}}
+	if _, err := Apply(dir, p, DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, dir, "main.go"); got != "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n" {
+		t.Errorf("unexpected content:\n%s", got)
+	}
+}
+
+func TestApplyRejectsEscapingPaths(t *testing.T) {
+	p := &Patch{Edits: []Edit{{Path: "../evil.go", Replace: "x\n"}}}
+	if _, err := Apply(t.TempDir(), p, DefaultOptions); err == nil {
+		t.Errorf("expect error for path outside target directory")
+	}
+}
+
+func TestApplyRename(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", source)
+	text := "--- a/main.go\n+++ b/app.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"world\")\n }\n"
+	p, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Apply(dir, p, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "main.go")); !os.IsNotExist(err) {
+		t.Errorf("rename should remove the old file")
+	}
+	if got := readFile(t, dir, "app.go"); !strings.Contains(got, "\"world\"") {
+		t.Errorf("unexpected content:\n%s", got)
+	}
+	if err := res.Revert(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, dir, "main.go") != source {
+		t.Errorf("revert did not restore the old file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.go")); !os.IsNotExist(err) {
+		t.Errorf("revert should remove the new file")
+	}
+
+	writeFile(t, dir, "app.go", "package main\n")
+	var conflict *ConflictError
+	if _, err := Apply(dir, p, DefaultOptions); !errors.As(err, &conflict) || conflict.Reason != "rename target already exists" {
+		t.Errorf("expect conflict for existing rename target, got %v", err)
+	}
+	if readFile(t, dir, "main.go") != source {
+		t.Errorf("failed rename should not modify files")
+	}
+}
@@ -0,0 +1,265 @@
+// Package prompt 管理发给模型的提示词模板（text/template）：内置模板按模式、语言与角色组织，
+// 可由模板目录或配置中的同名模板覆盖
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// 模板对应的模式
+const (
+	ModeGen      = "gen"      // 生成代码
+	ModeTest     = "test"     // 生成测试
+	ModeFix      = "fix"      // 尝试失败后的修复反馈
+	ModeReview   = "review"   // 代码审查
+	ModePatch    = "patch"    // 修改已有代码
+	ModeAgent    = "agent"    // 工具调用
+	ModeContinue = "continue" // 回复被截断后请求续写
+)
+
+// 模板对应的消息角色
+const (
+	RoleSystem = "system"
+	RoleUser   = "user"
+)
+
+// roles 每种模式可用的角色，fix 与 continue 只有用户消息
+var roles = map[string][]string{
+	ModeGen:      {RoleSystem, RoleUser},
+	ModeTest:     {RoleSystem, RoleUser},
+	ModeFix:      {RoleUser},
+	ModeReview:   {RoleSystem, RoleUser},
+	ModePatch:    {RoleSystem, RoleUser},
+	ModeAgent:    {RoleSystem, RoleUser},
+	ModeContinue: {RoleUser},
+}
+
+// 模板来源
+const (
+	SourceBuiltin = "builtin"
+	SourceConfig  = "config"
+)
+
+// Config 提示词模板配置
+type Config struct {
+	// Dir 模板目录，其中的 <模式>[.<语言>].<角色>.tmpl 覆盖内置的同名模板，相对路径相对于配置文件
+	Dir string `yaml:"dir"`
+	// Templates 以模板名（如 gen.python.system）为键覆盖模板目录与内置模板
+	Templates map[string]string `yaml:"templates"`
+	// Constraints 追加到系统提示词末尾的额外约束
+	Constraints []string `yaml:"constraints"`
+}
+
+// Data 模板可用的变量
+type Data struct {
+	Mode     string
+	Language string
+	Version  string // 语言版本，取自运行镜像的标签，如 1.24.0
+	MainFile string
+	TestFile string
+	Prompt   string           // 用户需求
+	Files    []workspace.File // 文件上下文：patch 的目标文件、review 的待审查代码
+	// Constraints 额外约束，为 nil 时使用配置中的约束
+	Constraints []string
+	// Structured 要求模型按 JSON Schema 输出
+	Structured bool
+	// 以下字段只用于 fix 模板
+	Attempt  int      // 失败的尝试序号
+	Kind     string   // 失败阶段，对应 errs.Kind
+	Reason   string   // 失败原因
+	Output   string   // 截断后的运行输出
+	Details  []string // 安全检查、质量门禁或审查发现的问题
+	Rejected []string // 被拒绝写入的文件
+	Errors   []string // 更早的失败尝试的原因，按时间顺序
+}
+
+// NewData 返回 language 的模板变量，语言版本与默认文件名取自 registry（为 nil 时使用内置语言）
+func NewData(registry *lang.Registry, language, prompt string) Data {
+	if registry == nil {
+		registry = lang.Default()
+	}
+	d := Data{Language: language, Prompt: prompt}
+	if l, ok := registry.Get(language); ok {
+		d.Version, d.MainFile, d.TestFile = l.Version(), l.MainFile, l.TestFile
+	}
+	return d
+}
+
+// Template 一个模板的名称、来源与内容
+type Template struct {
+	Name   string `json:"name"`
+	Source string `json:"source"` // builtin、config 或模板文件路径
+	Text   string `json:"text"`
+}
+
+// Set 一组已解析的模板，可并发使用
+type Set struct {
+	root        *template.Template
+	templates   map[string]Template
+	constraints []string
+}
+
+var funcs = template.FuncMap{
+	"join":   strings.Join,
+	"trim":   strings.TrimSpace,
+	"trimnl": func(s string) string { return strings.TrimSuffix(s, "\n") },
+}
+
+var defaultSet = sync.OnceValue(func() *Set {
+	s, err := Load(Config{})
+	if err != nil {
+		panic(err)
+	}
+	return s
+})
+
+// Default 返回只包含内置模板的模板集
+func Default() *Set {
+	return defaultSet()
+}
+
+// Load 依次以内置模板、cfg.Dir 中的模板文件、cfg.Templates 构造模板集，后者覆盖前者的同名模板；
+// 模板名无效、解析失败或无法用示例数据渲染时返回 Config 错误
+func Load(cfg Config) (*Set, error) {
+	templates := make(map[string]Template)
+	entries, _ := builtin.ReadDir("templates")
+	for _, e := range entries {
+		data, _ := builtin.ReadFile("templates/" + e.Name())
+		name := strings.TrimSuffix(e.Name(), ".tmpl")
+		templates[name] = Template{Name: name, Source: SourceBuiltin, Text: string(data)}
+	}
+	if cfg.Dir != "" {
+		if _, err := os.Stat(cfg.Dir); err != nil {
+			return nil, errs.E(errs.Config, "load prompts", err)
+		}
+		files, err := filepath.Glob(filepath.Join(cfg.Dir, "*.tmpl"))
+		if err != nil {
+			return nil, errs.E(errs.Config, "load prompts", err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, errs.E(errs.Config, "load prompts", err)
+			}
+			name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+			templates[name] = Template{Name: name, Source: file, Text: string(data)}
+		}
+	}
+	for name, text := range cfg.Templates {
+		templates[name] = Template{Name: name, Source: SourceConfig, Text: text}
+	}
+
+	s := &Set{root: template.New("").Funcs(funcs), templates: templates, constraints: cfg.Constraints}
+	names := s.names()
+	for _, name := range names {
+		if err := checkName(name); err != nil {
+			return nil, errs.Errorf(errs.Config, "load prompts", "%s: %v", templates[name].Source, err)
+		}
+		if _, err := s.root.New(name).Parse(templates[name].Text); err != nil {
+			return nil, errs.Errorf(errs.Config, "load prompts", "%s: %v", templates[name].Source, err)
+		}
+	}
+	sample := Data{Language: "go", Version: "1.24.0", MainFile: "main.go", TestFile: "main_test.go", Prompt: "prompt",
+		Files: []workspace.File{{Path: "main.go", Content: "package main\n"}}, Constraints: []string{"constraint"},
+		Attempt: 1, Kind: "build", Reason: "reason", Output: "output", Details: []string{"detail"}, Rejected: []string{"file"}, Errors: []string{"error"}}
+	for _, name := range names {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		if err := s.root.Lookup(name).Execute(&bytes.Buffer{}, sample); err != nil {
+			return nil, errs.Errorf(errs.Config, "load prompts", "%s: %v", templates[name].Source, err)
+		}
+	}
+	return s, nil
+}
+
+// checkName 校验模板名：以 _ 开头的是供其他模板引用的片段，其余为 <模式>[.<语言>].<角色>
+func checkName(name string) error {
+	if strings.HasPrefix(name, "_") {
+		return nil
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) != 2 && len(parts) != 3 {
+		return fmt.Errorf("invalid template name %q, want <mode>[.<language>].<role>", name)
+	}
+	mode, role := parts[0], parts[len(parts)-1]
+	allowed, ok := roles[mode]
+	if !ok {
+		return fmt.Errorf("unknown mode %q in template name %q", mode, name)
+	}
+	if !slices.Contains(allowed, role) {
+		return fmt.Errorf("mode %s has no %q template", mode, role)
+	}
+	return nil
+}
+
+// Modes 返回全部模式
+func Modes() []string {
+	return []string{ModeGen, ModeTest, ModeFix, ModeReview, ModePatch, ModeAgent, ModeContinue}
+}
+
+// Roles 返回 mode 可用的角色
+func Roles(mode string) []string {
+	return roles[mode]
+}
+
+// Resolve 返回 mode 与 language 下 role 使用的模板：优先 <模式>.<语言>.<角色>，其次 <模式>.<角色>
+func (s *Set) Resolve(mode, language, role string) (Template, bool) {
+	if t, ok := s.templates[mode+"."+language+"."+role]; ok && language != "" {
+		return t, true
+	}
+	t, ok := s.templates[mode+"."+role]
+	return t, ok
+}
+
+// Render 渲染 d.Language 下 mode 的 role 消息，结果去除首尾空白
+func (s *Set) Render(mode, role string, d Data) (string, error) {
+	t, ok := s.Resolve(mode, d.Language, role)
+	if !ok {
+		return "", errs.Errorf(errs.Config, "render prompt", "no %s template for mode %s", role, mode)
+	}
+	d.Mode = mode
+	if d.Constraints == nil {
+		d.Constraints = s.constraints
+	}
+	var buf bytes.Buffer
+	if err := s.root.Lookup(t.Name).Execute(&buf, d); err != nil {
+		return "", errs.Errorf(errs.Config, "render prompt", "%s: %v", t.Name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Templates 返回全部模板（按名称排序）
+func (s *Set) Templates() []Template {
+	names := s.names()
+	out := make([]Template, len(names))
+	for i, name := range names {
+		out[i] = s.templates[name]
+	}
+	return out
+}
+
+func (s *Set) names() []string {
+	names := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
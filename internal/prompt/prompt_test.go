@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+func TestDefault(t *testing.T) {
+	d := NewData(lang.Default(), "go", "生成一个矩阵乘法")
+	for _, mode := range Modes() {
+		for _, role := range Roles(mode) {
+			text, err := Default().Render(mode, role, d)
+			if err != nil || text == "" {
+				t.Errorf("render %s.%s: %q, %v", mode, role, text, err)
+			}
+		}
+	}
+	system, _ := Default().Render(ModeGen, RoleSystem, d)
+	if !strings.Contains(system, "go 1.24.0") {
+		t.Errorf("gen system prompt should mention the language version, got %q", system)
+	}
+	d.Structured = true
+	if system, _ = Default().Render(ModeTest, RoleSystem, d); !strings.Contains(system, `"files"`) {
+		t.Errorf("structured test prompt should describe the json schema, got %q", system)
+	}
+	d.Files = []workspace.File{{Path: "main.go", Content: "package main\n"}}
+	if user, _ := Default().Render(ModePatch, RoleUser, d); !strings.Contains(user, "main.go\n```\npackage main\n```") {
+		t.Errorf("patch user prompt should contain the files, got %q", user)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "gen.system.tmpl"), []byte("dir {{.Language}}{{template \"_constraints\" .}}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "fix.user.tmpl"), []byte("attempt {{.Attempt}}: {{.Reason}} after {{join .Errors \", \"}}"), 0644)
+	set, err := Load(Config{
+		Dir:         dir,
+		Templates:   map[string]string{"gen.python.system": "config {{.Language}} {{.Version}}"},
+		Constraints: []string{"不要使用第三方库"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := set.Render(ModeGen, RoleSystem, NewData(nil, "go", "")); text != "dir go\n额外约束:\n- 不要使用第三方库" {
+		t.Errorf("unexpected go system prompt %q", text)
+	}
+	if text, _ := set.Render(ModeGen, RoleSystem, NewData(nil, "python", "")); text != "config python 3.11" {
+		t.Errorf("language template should take precedence, got %q", text)
+	}
+	if text, _ := set.Render(ModeFix, RoleUser, Data{Attempt: 2, Reason: "boom", Errors: []string{"a", "b"}}); text != "attempt 2: boom after a, b" {
+		t.Errorf("unexpected fix prompt %q", text)
+	}
+	if tmpl, _ := set.Resolve(ModeGen, "go", RoleSystem); tmpl.Source != filepath.Join(dir, "gen.system.tmpl") {
+		t.Errorf("unexpected source %q", tmpl.Source)
+	}
+	if tmpl, _ := set.Resolve(ModeReview, "go", RoleSystem); tmpl.Source != SourceBuiltin {
+		t.Errorf("unexpected source %q", tmpl.Source)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"unknown mode":  {Templates: map[string]string{"deploy.system": "x"}},
+		"unknown role":  {Templates: map[string]string{"fix.system": "x"}},
+		"parse error":   {Templates: map[string]string{"gen.user": "{{.Prompt"}},
+		"unknown field": {Templates: map[string]string{"gen.user": "{{.Requirement}}"}},
+		"missing dir":   {Dir: filepath.Join(t.TempDir(), "missing")},
+	} {
+		if _, err := Load(cfg); errs.KindOf(err) != errs.Config {
+			t.Errorf("%s: expect config error, got %v", name, err)
+		}
+	}
+}
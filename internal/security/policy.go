@@ -0,0 +1,89 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Action 对一类检查结果的处理方式
+type Action string
+
+const (
+	ActionOff     Action = "off"     // 忽略
+	ActionWarn    Action = "warn"    // 只输出警告，继续运行
+	ActionBlock   Action = "block"   // 拒绝运行，不重试
+	ActionRewrite Action = "rewrite" // 拒绝运行，并把检查结果反馈给模型重写
+)
+
+// Policy 安全检查策略，未配置的规则使用 Default，Default 为空时为 warn
+type Policy struct {
+	Default Action            `yaml:"default"`
+	Rules   map[string]Action `yaml:"rules"`
+}
+
+// Action 返回规则对应的处理方式
+func (p Policy) Action(rule string) Action {
+	if a, ok := p.Rules[rule]; ok && a != "" {
+		return a
+	}
+	if p.Default != "" {
+		return p.Default
+	}
+	return ActionWarn
+}
+
+// Validate 检查策略中的处理方式是否合法
+func (p Policy) Validate() error {
+	check := func(a Action) error {
+		switch a {
+		case "", ActionOff, ActionWarn, ActionBlock, ActionRewrite:
+			return nil
+		}
+		return fmt.Errorf("unknown security action %q", a)
+	}
+	if err := check(p.Default); err != nil {
+		return err
+	}
+	for _, a := range p.Rules {
+		if err := check(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Error 检查结果中存在被策略拒绝的项
+type Error struct {
+	Findings []Finding // 被拒绝的检查结果
+	Rewrite  bool      // 全部为 rewrite 时为 true，应让模型重写
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		parts[i] = f.String()
+	}
+	return "security check failed: " + strings.Join(parts, "; ")
+}
+
+// Apply 按策略处理检查结果，返回需要警告的结果；存在 block/rewrite 结果时返回 *Error
+func (p Policy) Apply(findings []Finding) ([]Finding, error) {
+	var warnings, denied []Finding
+	rewrite := true
+	for _, f := range findings {
+		switch p.Action(f.Rule) {
+		case ActionOff:
+		case ActionBlock:
+			denied = append(denied, f)
+			rewrite = false
+		case ActionRewrite:
+			denied = append(denied, f)
+		default:
+			warnings = append(warnings, f)
+		}
+	}
+	if len(denied) > 0 {
+		return warnings, &Error{Findings: denied, Rewrite: rewrite}
+	}
+	return warnings, nil
+}
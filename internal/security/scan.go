@@ -0,0 +1,262 @@
+// Package security 在运行前对生成的 Go/Python 代码做静态安全检查
+package security
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// 检查规则
+const (
+	RuleExecShell = "exec-shell"     // 通过 shell 执行命令
+	RuleExec      = "exec"           // 执行外部命令
+	RuleUnsafe    = "unsafe"         // unsafe / ctypes 直接操作内存
+	RuleSyscall   = "syscall"        // 原始系统调用
+	RuleListen    = "network-listen" // 监听网络端口
+	RuleDelete    = "file-delete"    // 删除工作目录之外的文件
+	RuleSecret    = "secret"         // 硬编码的密钥
+	RuleEval      = "eval"           // 动态执行代码
+	RulePickle    = "pickle"         // 反序列化不可信数据
+)
+
+// 严重程度
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Finding 一条检查结果
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: [%s/%s] %s", f.File, f.Line, f.Rule, f.Severity, f.Message)
+}
+
+// Scan 检查文件，按扩展名选择 Go 或 Python 规则，其他文件只检查硬编码密钥；结果按文件与行号排序
+func Scan(files []workspace.File) []Finding {
+	var findings []Finding
+	for _, f := range files {
+		switch filepath.Ext(f.Path) {
+		case ".go":
+			findings = append(findings, scanGo(f.Path, f.Content)...)
+		case ".py":
+			findings = append(findings, scanPython(f.Path, f.Content)...)
+		default:
+			findings = append(findings, scanSecrets(f.Path, f.Content)...)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+var secretRes = []*regexp.Regexp{
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+}
+
+// secretAssignRe 形如 password = "..." 的赋值，值至少 8 个字符
+var secretAssignRe = regexp.MustCompile(`(?i)\b[\w.]*(password|passwd|secret|token|api_?key)\w*\s*:?=\s*["']([^"'\s]{8,})["']`)
+
+// scanSecrets 逐行检查硬编码的密钥
+func scanSecrets(file, content string) []Finding {
+	var findings []Finding
+	for i, line := range strings.Split(content, "\n") {
+		matched := secretAssignRe.MatchString(line)
+		for _, re := range secretRes {
+			matched = matched || re.MatchString(line)
+		}
+		if matched {
+			findings = append(findings, Finding{RuleSecret, SeverityHigh, file, i + 1, "hard-coded secret"})
+		}
+	}
+	return findings
+}
+
+// shells 通过 -c 执行命令字符串的解释器
+var shells = map[string]bool{"sh": true, "bash": true, "zsh": true, "/bin/sh": true, "/bin/bash": true, "cmd": true, "cmd.exe": true, "powershell": true}
+
+// shellFlags 让解释器执行后续字符串参数的选项
+var shellFlags = map[string]bool{"-c": true, "/c": true, "/C": true, "-Command": true}
+
+// goCallRules 按 包路径.函数名 匹配的调用规则
+var goCallRules = map[string]Finding{
+	"net.Listen":                 {Rule: RuleListen, Severity: SeverityMedium, Message: "listens on a network port"},
+	"net.ListenPacket":           {Rule: RuleListen, Severity: SeverityMedium, Message: "listens on a network port"},
+	"net.ListenTCP":              {Rule: RuleListen, Severity: SeverityMedium, Message: "listens on a network port"},
+	"net.ListenUDP":              {Rule: RuleListen, Severity: SeverityMedium, Message: "listens on a network port"},
+	"net/http.ListenAndServe":    {Rule: RuleListen, Severity: SeverityMedium, Message: "starts an HTTP server"},
+	"net/http.ListenAndServeTLS": {Rule: RuleListen, Severity: SeverityMedium, Message: "starts an HTTP server"},
+	"net/http.Serve":             {Rule: RuleListen, Severity: SeverityMedium, Message: "starts an HTTP server"},
+}
+
+// scanGo 基于 go/ast 检查 Go 源码，无法解析时只检查硬编码密钥
+func scanGo(file, content string) []Finding {
+	findings := scanSecrets(file, content)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, content, parser.SkipObjectResolution)
+	if err != nil {
+		return findings
+	}
+	add := func(pos token.Pos, rule, severity, msg string) {
+		findings = append(findings, Finding{rule, severity, file, fset.Position(pos).Line, msg})
+	}
+	// 本地包名到导入路径
+	imports := make(map[string]string)
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = path
+		switch path {
+		case "unsafe":
+			add(imp.Pos(), RuleUnsafe, SeverityHigh, "imports unsafe")
+		case "syscall", "golang.org/x/sys/unix", "golang.org/x/sys/windows":
+			add(imp.Pos(), RuleSyscall, SeverityHigh, "imports "+path+" for raw system calls")
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok || imports[pkg.Name] == "" {
+			return true
+		}
+		name := imports[pkg.Name] + "." + sel.Sel.Name
+		if rule, ok := goCallRules[name]; ok {
+			add(call.Pos(), rule.Rule, rule.Severity, rule.Message)
+			return true
+		}
+		switch name {
+		case "os/exec.Command", "os/exec.CommandContext":
+			args := call.Args
+			if name == "os/exec.CommandContext" && len(args) > 0 {
+				args = args[1:]
+			}
+			if len(args) >= 2 && shells[stringLit(args[0])] && shellFlags[stringLit(args[1])] {
+				add(call.Pos(), RuleExecShell, SeverityHigh, "runs a shell command string")
+			} else {
+				add(call.Pos(), RuleExec, SeverityMedium, "executes an external command")
+			}
+		case "os.Remove", "os.RemoveAll":
+			if len(call.Args) == 0 {
+				return true
+			}
+			if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				if path := stringLit(lit); outsideCwd(path) {
+					add(call.Pos(), RuleDelete, SeverityHigh, "deletes "+path+" outside the working directory")
+				}
+			} else if sel.Sel.Name == "RemoveAll" {
+				add(call.Pos(), RuleDelete, SeverityMedium, "recursively deletes a computed path")
+			}
+		}
+		return true
+	})
+	return findings
+}
+
+// stringLit 返回字符串字面量的值，非字面量返回空串
+func stringLit(e ast.Expr) string {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// outsideCwd 路径是否指向工作目录之外
+func outsideCwd(path string) bool {
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(path, "~") || filepath.VolumeName(path) != "" {
+		return true
+	}
+	return !filepath.IsLocal(filepath.Clean(path)) && filepath.Clean(path) != "."
+}
+
+type pyRule struct {
+	re *regexp.Regexp
+	Finding
+}
+
+var pyRules = []pyRule{
+	{regexp.MustCompile(`\bsubprocess\.\w+\(.*shell\s*=\s*True`), Finding{Rule: RuleExecShell, Severity: SeverityHigh, Message: "runs a shell command string"}},
+	{regexp.MustCompile(`\bos\.(system|popen)\(`), Finding{Rule: RuleExecShell, Severity: SeverityHigh, Message: "runs a shell command string"}},
+	{regexp.MustCompile(`\bsubprocess\.(run|call|check_call|check_output|Popen)\(`), Finding{Rule: RuleExec, Severity: SeverityMedium, Message: "executes an external command"}},
+	{regexp.MustCompile(`(^|[^.\w])(eval|exec)\(`), Finding{Rule: RuleEval, Severity: SeverityHigh, Message: "evaluates dynamic code"}},
+	{regexp.MustCompile(`\b(pickle|cPickle|marshal|dill)\.loads?\(`), Finding{Rule: RulePickle, Severity: SeverityHigh, Message: "deserializes untrusted data"}},
+	{regexp.MustCompile(`^\s*(import\s+ctypes|from\s+ctypes\s+import)`), Finding{Rule: RuleUnsafe, Severity: SeverityHigh, Message: "imports ctypes"}},
+	{regexp.MustCompile(`\.(bind|listen)\(|\b(HTTPServer|TCPServer|UDPServer)\(|\bapp\.run\(`), Finding{Rule: RuleListen, Severity: SeverityMedium, Message: "listens on a network port"}},
+}
+
+var pyYAMLLoadRe = regexp.MustCompile(`\byaml\.(load|load_all)\(`)
+
+// pyDeleteRe 删除文件的调用，捕获第一个参数为字符串字面量时的路径
+var pyDeleteRe = regexp.MustCompile(`\b(shutil\.rmtree|os\.remove|os\.unlink|os\.rmdir|os\.removedirs)\(\s*(?:["']([^"']*)["'])?`)
+
+// scanPython 逐行匹配 Python 源码中的危险调用，忽略注释行
+func scanPython(file, content string) []Finding {
+	findings := scanSecrets(file, content)
+	for i, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		shell := false
+		for _, r := range pyRules {
+			if r.Rule == RuleExec && shell {
+				// 同一行已按 shell 执行报告
+				continue
+			}
+			if r.re.MatchString(line) {
+				shell = shell || r.Rule == RuleExecShell
+				f := r.Finding
+				f.File, f.Line = file, i+1
+				findings = append(findings, f)
+			}
+		}
+		if pyYAMLLoadRe.MatchString(line) && !strings.Contains(line, "SafeLoader") {
+			findings = append(findings, Finding{RulePickle, SeverityMedium, file, i + 1, "yaml.load without SafeLoader"})
+		}
+		if m := pyDeleteRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case m[2] != "" && outsideCwd(m[2]):
+				findings = append(findings, Finding{RuleDelete, SeverityHigh, file, i + 1, "deletes " + m[2] + " outside the working directory"})
+			case m[2] == "" && m[1] == "shutil.rmtree":
+				findings = append(findings, Finding{RuleDelete, SeverityMedium, file, i + 1, "recursively deletes a computed path"})
+			}
+		}
+	}
+	return findings
+}
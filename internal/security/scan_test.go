@@ -0,0 +1,99 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+func rules(findings []Finding) map[string]string {
+	m := make(map[string]string)
+	for _, f := range findings {
+		m[f.Rule] = f.Severity
+	}
+	return m
+}
+
+func TestScanGo(t *testing.T) {
+	src := `package main
+
+import (
+	"net/http"
+	"os"
+	x "os/exec"
+	"unsafe"
+)
+
+func main() {
+	x.Command("sh", "-c", "rm -rf /").Run()
+	x.Command("ls").Run()
+	os.RemoveAll("/etc")
+	os.Remove("out.txt")
+	http.ListenAndServe(":8080", nil)
+	_ = unsafe.Sizeof(0)
+}
+`
+	got := rules(Scan([]workspace.File{{Path: "main.go", Content: src}}))
+	want := map[string]string{
+		RuleExecShell: SeverityHigh,
+		RuleExec:      SeverityMedium,
+		RuleDelete:    SeverityHigh,
+		RuleListen:    SeverityMedium,
+		RuleUnsafe:    SeverityHigh,
+	}
+	for rule, sev := range want {
+		if got[rule] != sev {
+			t.Errorf("rule %s: expect %s, got %q (all %v)", rule, sev, got[rule], got)
+		}
+	}
+	if len(Scan([]workspace.File{{Path: "main.go", Content: "package main\n\nfunc main() { println(1) }\n"}})) != 0 {
+		t.Error("expect no findings for harmless code")
+	}
+}
+
+func TestScanPython(t *testing.T) {
+	src := `import subprocess, os, pickle
+# os.system("ignored comment")
+subprocess.run("ls", shell=True)
+os.remove("data.txt")
+os.remove("/etc/passwd")
+pickle.loads(b"")
+eval("1+1")
+API_KEY = "abcdefgh12345678"
+`
+	findings := Scan([]workspace.File{{Path: "main.py", Content: src}})
+	got := rules(findings)
+	for _, rule := range []string{RuleExecShell, RuleDelete, RulePickle, RuleEval, RuleSecret} {
+		if got[rule] == "" {
+			t.Errorf("expect finding for %s, got %v", rule, findings)
+		}
+	}
+	if got[RuleExec] != "" {
+		t.Errorf("shell=True should not also be reported as %s", RuleExec)
+	}
+	for _, f := range findings {
+		if f.Line == 2 || f.Line == 4 {
+			t.Errorf("unexpected finding %s", f)
+		}
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	findings := []Finding{{Rule: RuleExec}, {Rule: RuleSecret}, {Rule: RuleListen}}
+	p := Policy{Rules: map[string]Action{RuleSecret: ActionRewrite, RuleListen: ActionOff}}
+	warnings, err := p.Apply(findings)
+	if len(warnings) != 1 || warnings[0].Rule != RuleExec {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	e, ok := err.(*Error)
+	if !ok || !e.Rewrite || len(e.Findings) != 1 {
+		t.Fatalf("expect rewrite error, got %v", err)
+	}
+	p.Default = ActionBlock
+	if _, err := p.Apply(findings); err == nil || err.(*Error).Rewrite {
+		t.Errorf("expect block error, got %v", err)
+	}
+	if err := (Policy{Default: "deny"}).Validate(); err == nil {
+		t.Error("expect invalid action error")
+	}
+}
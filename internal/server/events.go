@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+)
+
+// pingInterval 事件流的保活间隔
+const pingInterval = 15 * time.Second
+
+// events 推送任务的事件：默认为 Server-Sent Events，WebSocket 握手请求改为每个事件一条文本消息。
+// 从 Last-Event-ID 请求头或 after 参数指定的序号之后开始重放，任务结束后关闭连接
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	bus, ok := s.queue.Events(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("after")
+	}
+	var after int64
+	if last != "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid event id "+strconv.Quote(last))
+			return
+		}
+		after = n
+	}
+	if isWebSocket(r) {
+		s.eventsWebSocket(w, r, bus, after)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	ch, cancel := bus.Subscribe(after)
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+			flusher.Flush()
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// eventsWebSocket 以 WebSocket 文本消息推送事件，客户端关闭连接时取消订阅
+func (s *Server) eventsWebSocket(w http.ResponseWriter, r *http.Request, bus *aca.EventBus, after int64) {
+	conn, err := upgradeWebSocket(w, r, s.guard)
+	if err != nil {
+		return
+	}
+	defer conn.close()
+	ch, cancel := bus.Subscribe(after)
+	defer cancel()
+	closed := make(chan struct{})
+	go conn.readLoop(closed)
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(e)
+			if err := conn.writeFrame(opText, data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package server 提供 aca serve 的 HTTP API：提交任务、查询状态与结果、获取生成的文件、订阅事件、取消任务、
+// 查看会话记录，以及在根路径提供监控面板。所有请求校验 Host 与 Origin，配置令牌时 API 要求 Bearer 认证
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/web"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+)
+
+// maxBodySize 请求体的最大字节数
+const maxBodySize = 1 << 20
+
+// Server HTTP API
+type Server struct {
+	queue *aca.JobQueue
+	store *aca.SessionStore
+	guard httpguard.Options
+	mux   *http.ServeMux
+}
+
+// New 创建基于 queue 的 HTTP API，store 为 nil 时会话接口返回 404；guard 为访问控制，
+// 面板的静态文件不要求令牌，由页面向 API 请求时携带
+func New(queue *aca.JobQueue, store *aca.SessionStore, guard httpguard.Options) *Server {
+	s := &Server{queue: queue, store: store, guard: guard, mux: http.NewServeMux()}
+	s.Handle("POST /jobs", http.HandlerFunc(s.submit))
+	s.Handle("GET /jobs", http.HandlerFunc(s.list))
+	s.Handle("GET /jobs/{id}", http.HandlerFunc(s.get))
+	s.Handle("GET /jobs/{id}/files", http.HandlerFunc(s.files))
+	s.Handle("GET /jobs/{id}/events", http.HandlerFunc(s.events))
+	s.Handle("DELETE /jobs/{id}", http.HandlerFunc(s.cancel))
+	s.Handle("GET /sessions", http.HandlerFunc(s.sessions))
+	s.Handle("GET /sessions/{id}", http.HandlerFunc(s.session))
+	s.Handle("GET /sessions/{id}/diff", http.HandlerFunc(s.diff))
+	static := guard
+	static.Token = ""
+	s.mux.Handle("GET /", httpguard.Wrap(web.Handler(), static))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle 在同一路由上注册额外的处理器，与 API 使用相同的访问控制
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, httpguard.Wrap(h, s.guard))
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	if !httpguard.RequireJSON(w, r) {
+		return
+	}
+	var req aca.JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	job, err := s.queue.Submit(req)
+	switch {
+	case errors.Is(err, aca.ErrQueueFull):
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.queue.List())
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.queue.Get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// files 返回全部文件，带 path 参数时以纯文本返回单个文件的内容
+func (s *Server) files(w http.ResponseWriter, r *http.Request) {
+	files, ok := s.queue.Files(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		if files == nil {
+			files = []aca.File{}
+		}
+		writeJSON(w, http.StatusOK, files)
+		return
+	}
+	for _, f := range files {
+		if f.Path == path {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(f.Content))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "file not found")
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	job, ok := s.queue.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if job.Finished() {
+		writeError(w, http.StatusConflict, "job already "+job.Status)
+		return
+	}
+	job, _ = s.queue.Cancel(id)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
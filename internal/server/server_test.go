@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+)
+
+type fakeProvider struct{}
+
+func (fakeProvider) Complete(ctx context.Context, req aca.CompletionRequest) (*aca.Completion, error) {
+	return &aca.Completion{Content: "```python\nprint('hi')\n```"}, nil
+}
+
+type fakeRuntime struct{}
+
+func (fakeRuntime) Run(ctx context.Context, spec aca.RunSpec) (string, error) {
+	return "hi\n", nil
+}
+
+func do(t *testing.T, method, url, body string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestServer(t *testing.T) {
+	p, _ := aca.New(aca.WithProvider(fakeProvider{}), aca.WithRuntime(fakeRuntime{}), aca.WithLogger(aca.DiscardLogger))
+	queue := aca.NewJobQueue(p, aca.Task{WorkDir: t.TempDir()}, 1, 10)
+	defer queue.Close()
+	srv := httptest.NewServer(New(queue, nil, httpguard.Options{}))
+	defer srv.Close()
+
+	resp, body := do(t, "POST", srv.URL+"/jobs", `{"mode":"gen","prompt":"say hi","language":"python"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expect 202, got %d: %s", resp.StatusCode, body)
+	}
+	var job aca.Job
+	json.Unmarshal([]byte(body), &job)
+	if resp.Header.Get("Location") != "/jobs/"+job.ID {
+		t.Errorf("unexpected location %q", resp.Header.Get("Location"))
+	}
+	queue.Wait(context.Background(), job.ID)
+
+	resp, body = do(t, "GET", srv.URL+"/jobs/"+job.ID, "")
+	json.Unmarshal([]byte(body), &job)
+	if resp.StatusCode != http.StatusOK || job.Status != aca.JobPassed || job.Output != "hi\n" {
+		t.Errorf("unexpected job %s", body)
+	}
+	_, body = do(t, "GET", srv.URL+"/jobs/"+job.ID+"/files", "")
+	if !strings.Contains(body, `"path": "main.py"`) {
+		t.Errorf("unexpected files %s", body)
+	}
+	_, body = do(t, "GET", srv.URL+"/jobs/"+job.ID+"/files?path=main.py", "")
+	if body != "print('hi')" {
+		t.Errorf("unexpected file content %q", body)
+	}
+	if resp, _ = do(t, "DELETE", srv.URL+"/jobs/"+job.ID, ""); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expect 409 for finished job, got %d", resp.StatusCode)
+	}
+	_, body = do(t, "GET", srv.URL+"/jobs", "")
+	if !strings.Contains(body, job.ID) {
+		t.Errorf("job missing from list: %s", body)
+	}
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/jobs", `{"prompt":"x","mode":"patch"}`, http.StatusBadRequest},
+		{"POST", "/jobs", `{"prompt":"x","unknown":1}`, http.StatusBadRequest},
+		{"GET", "/jobs/nope", "", http.StatusNotFound},
+		{"GET", "/jobs/nope/files", "", http.StatusNotFound},
+		{"GET", "/jobs/nope/events", "", http.StatusNotFound},
+		{"DELETE", "/jobs/nope", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if resp, body := do(t, c.method, srv.URL+c.path, c.body); resp.StatusCode != c.status {
+			t.Errorf("%s %s: expect %d, got %d: %s", c.method, c.path, c.status, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	p, _ := aca.New(aca.WithProvider(fakeProvider{}), aca.WithRuntime(fakeRuntime{}), aca.WithLogger(aca.DiscardLogger))
+	queue := aca.NewJobQueue(p, aca.Task{WorkDir: t.TempDir()}, 1, 10)
+	defer queue.Close()
+	srv := httptest.NewServer(New(queue, nil, httpguard.Options{}))
+	defer srv.Close()
+	job, err := queue.Submit(aca.JobRequest{Prompt: "say hi", Language: "python"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.Wait(context.Background(), job.ID)
+
+	// 任务已结束，事件流重放全部事件后关闭
+	resp, body := do(t, "GET", srv.URL+"/jobs/"+job.ID+"/events", "")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{"id: 1\nevent: job\n", "event: files\n", "event: verdict\n", "event: done\n", `"status":"passed"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("event stream missing %q:\n%s", want, body)
+		}
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/jobs/"+job.ID+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if strings.Contains(string(data), "id: 1\n") || !strings.Contains(string(data), "id: 2\n") {
+		t.Errorf("Last-Event-ID not honored:\n%s", data)
+	}
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	io.WriteString(conn, "GET /jobs/"+job.ID+"/events HTTP/1.1\r\nHost: "+host+"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	br := bufio.NewReader(conn)
+	hs, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hs.StatusCode != http.StatusSwitchingProtocols || hs.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake %d %v", hs.StatusCode, hs.Header)
+	}
+	ws := &wsConn{conn: conn, br: br}
+	var types []string
+	for {
+		op, payload, err := ws.readFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op == opClose {
+			break
+		}
+		var e aca.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			t.Fatalf("invalid event %s: %v", payload, err)
+		}
+		types = append(types, e.Type)
+	}
+	if len(types) == 0 || types[0] != aca.EventJob || types[len(types)-1] != aca.EventJob {
+		t.Errorf("unexpected websocket events %v", types)
+	}
+}
+
+func TestSessionsAndDashboard(t *testing.T) {
+	store := aca.NewSessionStore(t.TempDir())
+	p, _ := aca.New(aca.WithProvider(fakeProvider{}), aca.WithRuntime(fakeRuntime{}), aca.WithLogger(aca.DiscardLogger), aca.WithSessionStore(store))
+	queue := aca.NewJobQueue(p, aca.Task{WorkDir: t.TempDir()}, 1, 10)
+	defer queue.Close()
+	srv := httptest.NewServer(New(queue, store, httpguard.Options{}))
+	defer srv.Close()
+	job, _ := queue.Submit(aca.JobRequest{Prompt: "say hi", Language: "python"})
+	job, _ = queue.Wait(context.Background(), job.ID)
+
+	_, body := do(t, "GET", srv.URL+"/sessions", "")
+	if !strings.Contains(body, job.SessionID) {
+		t.Errorf("session missing from list: %s", body)
+	}
+	resp, body := do(t, "GET", srv.URL+"/sessions/"+job.SessionID, "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"conversation"`) || !strings.Contains(body, `"content": "print('hi')"`) {
+		t.Errorf("unexpected session detail %d: %s", resp.StatusCode, body)
+	}
+	_, body = do(t, "GET", srv.URL+"/sessions/"+job.SessionID+"/diff?to=1", "")
+	if !strings.Contains(body, "+++ b/main.py") || !strings.Contains(body, "+print('hi')") {
+		t.Errorf("unexpected diff %q", body)
+	}
+	if resp, _ = do(t, "GET", srv.URL+"/sessions/"+job.SessionID+"/diff?to=2", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expect 400 for unknown attempt, got %d", resp.StatusCode)
+	}
+	if resp, _ = do(t, "GET", srv.URL+"/sessions/nope", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expect 404 for unknown session, got %d", resp.StatusCode)
+	}
+
+	resp, body = do(t, "GET", srv.URL+"/", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "<title>Agent Cat Agent</title>") {
+		t.Errorf("unexpected dashboard %d: %.200s", resp.StatusCode, body)
+	}
+	if resp, _ = do(t, "GET", srv.URL+"/app.js", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("expect app.js, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerGuard(t *testing.T) {
+	p, _ := aca.New(aca.WithProvider(fakeProvider{}), aca.WithRuntime(fakeRuntime{}), aca.WithLogger(aca.DiscardLogger))
+	queue := aca.NewJobQueue(p, aca.Task{WorkDir: t.TempDir()}, 1, 10)
+	defer queue.Close()
+	srv := httptest.NewServer(New(queue, nil, httpguard.Options{Token: "s3cret"}))
+	defer srv.Close()
+
+	if resp, _ := do(t, "GET", srv.URL+"/jobs", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expect 401 without token, got %d", resp.StatusCode)
+	}
+	// 面板的静态文件不要求令牌
+	if resp, _ := do(t, "GET", srv.URL+"/", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("expect 200 for dashboard, got %d", resp.StatusCode)
+	}
+
+	send := func(method, path, contentType, origin, body string) int {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := send("GET", "/jobs", "", "", ""); code != http.StatusOK {
+		t.Errorf("expect 200 with token, got %d", code)
+	}
+	if code := send("GET", "/jobs", "", "https://evil.example", ""); code != http.StatusForbidden {
+		t.Errorf("expect 403 for cross-origin request, got %d", code)
+	}
+	if code := send("GET", "/jobs", "", srv.URL, ""); code != http.StatusOK {
+		t.Errorf("expect 200 for same-origin request, got %d", code)
+	}
+	// 跨站表单无法设置 application/json
+	if code := send("POST", "/jobs", "text/plain", "", `{"mode":"gen","prompt":"say hi","language":"python"}`); code != http.StatusUnsupportedMediaType {
+		t.Errorf("expect 415 for non-JSON body, got %d", code)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	handshake := func(origin string, guard httpguard.Options) int {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:8080/jobs/1/events", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		w := httptest.NewRecorder()
+		upgradeWebSocket(w, r, guard)
+		return w.Code
+	}
+	if code := handshake("https://evil.example", httpguard.Options{}); code != http.StatusForbidden {
+		t.Errorf("expect 403 for cross-origin handshake, got %d", code)
+	}
+	// 同源或允许的 Origin 通过检查，ResponseRecorder 不支持接管连接
+	if code := handshake("http://127.0.0.1:8080", httpguard.Options{}); code != http.StatusInternalServerError {
+		t.Errorf("expect same-origin handshake to pass the origin check, got %d", code)
+	}
+	if code := handshake("https://app.example", httpguard.Options{Origins: []string{"https://app.example"}}); code != http.StatusInternalServerError {
+		t.Errorf("expect allowed origin to pass the origin check, got %d", code)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/pkg/aca"
+)
+
+// sessionDetail 会话详情：会话记录、对话历史以及每次尝试的文件
+type sessionDetail struct {
+	*aca.Session
+	Conversation *aca.Conversation `json:"conversation,omitempty"`
+	// Files 与 Attempts 一一对应的文件内容
+	Files [][]aca.File `json:"attempt_files"`
+}
+
+func (s *Server) sessions(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeError(w, http.StatusNotFound, "session store is not configured")
+		return
+	}
+	list, err := s.store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if list == nil {
+		list = []*aca.Session{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// loadSession 读取路径中的会话，失败时已写入错误响应
+func (s *Server) loadSession(w http.ResponseWriter, r *http.Request) (*aca.Session, bool) {
+	if s.store == nil {
+		writeError(w, http.StatusNotFound, "session store is not configured")
+		return nil, false
+	}
+	sess, err := s.store.Load(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	return sess, true
+}
+
+func (s *Server) session(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.loadSession(w, r)
+	if !ok {
+		return
+	}
+	conv, err := s.store.LoadConversation(sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	detail := sessionDetail{Session: sess, Conversation: conv, Files: make([][]aca.File, len(sess.Attempts))}
+	for i, a := range sess.Attempts {
+		detail.Files[i] = a.Files
+		if detail.Files[i] == nil {
+			detail.Files[i] = []aca.File{}
+		}
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// diff 以纯文本返回两次尝试之间文件的 unified diff，from 默认为 to 的上一次尝试，0 表示空文件集
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.loadSession(w, r)
+	if !ok {
+		return
+	}
+	to, err := attemptParam(r, "to", len(sess.Attempts))
+	if err != nil || to < 1 || to > len(sess.Attempts) {
+		writeError(w, http.StatusBadRequest, "invalid attempt to="+r.URL.Query().Get("to"))
+		return
+	}
+	from, err := attemptParam(r, "from", to-1)
+	if err != nil || from < 0 || from > len(sess.Attempts) {
+		writeError(w, http.StatusBadRequest, "invalid attempt from="+r.URL.Query().Get("from"))
+		return
+	}
+	var before []aca.File
+	if from > 0 {
+		before = sess.Attempts[from-1].Files
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(aca.DiffFiles(before, sess.Attempts[to-1].Files)))
+}
+
+// attemptParam 读取尝试序号参数，未指定时返回 def
+func attemptParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
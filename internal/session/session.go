@@ -0,0 +1,95 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/mcp"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/usage"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// 会话状态
+const (
+	StatusRunning     = "running"
+	StatusPassed      = "passed"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+)
+
+// Session 一次 aca 运行的持久化记录
+type Session struct {
+	ID        string    `json:"id"`
+	Mode      string    `json:"mode"` // gen/test/chat/patch
+	Prompt    string    `json:"prompt"`
+	Targets   []string  `json:"targets,omitempty"` // patch 模式指定的文件
+	Language  string    `json:"language"`
+	Model     string    `json:"model"`
+	WorkDir   string    `json:"workdir"`
+	MountDir  string    `json:"mount"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Attempts  []Attempt `json:"attempts,omitempty"`
+	Git       *Git      `json:"git,omitempty"` // 启用 git 集成时的分支信息
+	// MCP 配置了外部 MCP 服务时各服务的连接结果与可用工具
+	MCP []mcp.ServerStatus `json:"mcp,omitempty"`
+	// Usage 会话中全部模型调用的累计用量，包括未计入某次尝试的调用（如对话摘要）
+	Usage usage.Usage `json:"usage,omitzero"`
+}
+
+// Git 会话在 git 工作区中的分支
+type Git struct {
+	Branch string `json:"branch"`           // 会话分支，每次尝试一个提交
+	Base   string `json:"base"`             // 会话分支的起点提交
+	Origin string `json:"origin,omitempty"` // 创建会话分支前所在的分支
+}
+
+// Attempt 一次生成尝试；文件内容单独保存在 attempts/<n>/files 下
+type Attempt struct {
+	Number    int                    `json:"number"`
+	Prompt    string                 `json:"prompt"` // 本次发给模型的用户消息
+	Response  string                 `json:"response"`
+	Files     []workspace.File       `json:"-"`
+	FileNames []string               `json:"files,omitempty"`
+	Output    string                 `json:"output,omitempty"`
+	Verdict   agent.Verdict          `json:"verdict"`
+	Commit    string                 `json:"commit,omitempty"`     // 启用 git 集成时本次尝试的提交
+	Review    *agent.Review          `json:"review,omitempty"`     // 启用代码审查时 Reviewer 的结论
+	ToolCalls []agent.ToolCallRecord `json:"tool_calls,omitempty"` // 模型在本次尝试中的工具调用
+	Calls     []usage.Call           `json:"calls,omitempty"`      // 本次尝试中的模型调用及其用量
+	Usage     usage.Usage            `json:"usage,omitzero"`
+	StartedAt time.Time              `json:"started_at"`
+	Duration  time.Duration          `json:"duration"`
+}
+
+// NewID 生成会话 ID：时间戳加随机后缀，按字典序即按创建时间排序
+func NewID() string {
+	var b [3]byte
+	rand.Read(b[:])
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+var idRe = regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{6}$`)
+
+// IsID 判断 name 是否为 NewID 生成的会话 ID
+func IsID(name string) bool {
+	return idRe.MatchString(name)
+}
+
+// LastAttempt 返回最后一次尝试，没有尝试时返回 nil
+func (s *Session) LastAttempt() *Attempt {
+	if len(s.Attempts) == 0 {
+		return nil
+	}
+	return &s.Attempts[len(s.Attempts)-1]
+}
+
+// Finished 会话是否已结束（通过或失败），中断与运行中的会话可以恢复
+func (s *Session) Finished() bool {
+	return s.Status == StatusPassed || s.Status == StatusFailed
+}
@@ -0,0 +1,234 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// Store 基于本地目录的会话存储，布局为:
+//
+//	<dir>/<id>/session.json
+//	<dir>/<id>/conversation.json
+//	<dir>/<id>/attempts/<n>/files/...
+type Store struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// DefaultDir 返回默认的会话目录：$XDG_STATE_HOME/aca/sessions 或 ~/.local/state/aca/sessions
+func DefaultDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "aca", "sessions")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".aca", "sessions")
+	}
+	return filepath.Join(home, ".local", "state", "aca", "sessions")
+}
+
+// validID 判断 id（或其前缀）只包含小写字母、数字与 -，不会跳出 Dir；
+// id 可能来自 HTTP 路径或 MCP 资源 URI，必须在访问文件系统前检查
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) path(id string, elem ...string) string {
+	return filepath.Join(append([]string{s.Dir, id}, elem...)...)
+}
+
+// Save 保存会话元数据与各次尝试的文件
+func (s *Store) Save(sess *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess.ID == "" {
+		sess.ID = NewID()
+	}
+	if !validID(sess.ID) {
+		return fmt.Errorf("invalid session id %q", sess.ID)
+	}
+	sess.UpdatedAt = time.Now()
+	if sess.CreatedAt.IsZero() {
+		sess.CreatedAt = sess.UpdatedAt
+	}
+	for i := range sess.Attempts {
+		a := &sess.Attempts[i]
+		if a.Files == nil {
+			continue
+		}
+		dir := s.path(sess.ID, "attempts", strconv.Itoa(a.Number), "files")
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := workspace.WriteFiles(dir, a.Files); err != nil {
+			return err
+		}
+		a.FileNames = a.FileNames[:0]
+		for _, f := range a.Files {
+			a.FileNames = append(a.FileNames, fileName(f))
+		}
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.path(sess.ID), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免中断时留下半个 session.json
+	tmp := s.path(sess.ID, "session.json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(sess.ID, "session.json"))
+}
+
+// fileName 在文件名前用 * 标记主文件
+func fileName(f workspace.File) string {
+	if f.Main {
+		return "*" + f.Path
+	}
+	return f.Path
+}
+
+// Load 按 ID 或唯一前缀读取会话，并加载各次尝试的文件
+func (s *Store) Load(id string) (*Session, error) {
+	id, err := s.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(id, "session.json"))
+	if err != nil {
+		return nil, err
+	}
+	sess := &Session{}
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	for i := range sess.Attempts {
+		a := &sess.Attempts[i]
+		dir := s.path(id, "attempts", strconv.Itoa(a.Number), "files")
+		for _, name := range a.FileNames {
+			path := strings.TrimPrefix(name, "*")
+			file, err := workspace.SafePath(dir, path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file %q in session %s: %w", path, id, err)
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			a.Files = append(a.Files, workspace.File{Path: path, Content: string(content), Main: name != path})
+		}
+	}
+	return sess, nil
+}
+
+// resolve 将 ID 前缀解析为完整 ID
+func (s *Store) resolve(prefix string) (string, error) {
+	if prefix == "" {
+		return "", fmt.Errorf("session id is required")
+	}
+	if !validID(prefix) {
+		return "", fmt.Errorf("invalid session id %q", prefix)
+	}
+	if _, err := os.Stat(s.path(prefix, "session.json")); err == nil {
+		return prefix, nil
+	}
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	var matches []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			matches = append(matches, e.Name())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("session %s not found", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("session id %s is ambiguous: %s", prefix, strings.Join(matches, ", "))
+}
+
+// List 返回所有会话（不加载文件内容），按创建时间倒序
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []*Session
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(s.path(e.Name(), "session.json"))
+		if err != nil {
+			continue
+		}
+		sess := &Session{}
+		if json.Unmarshal(data, sess) == nil {
+			out = append(out, sess)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Delete 删除会话及其全部文件
+func (s *Store) Delete(id string) error {
+	id, err := s.resolve(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(s.path(id))
+}
+
+// SaveConversation 保存会话的对话历史
+func (s *Store) SaveConversation(id string, conv *llm.Conversation) error {
+	if !validID(id) {
+		return fmt.Errorf("invalid session id %q", id)
+	}
+	return conv.Save(s.path(id, "conversation.json"))
+}
+
+// LoadConversation 读取会话的对话历史，不存在时返回 nil
+func (s *Store) LoadConversation(id string) (*llm.Conversation, error) {
+	id, err := s.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := llm.LoadConversation(s.path(id, "conversation.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return conv, err
+}
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+func TestStoreSaveLoad(t *testing.T) {
+	store := NewStore(t.TempDir())
+	sess := &Session{Mode: "gen", Prompt: "hello", Language: "go", Status: StatusRunning}
+	sess.Attempts = append(sess.Attempts, Attempt{
+		Number:   1,
+		Response: "```go\npackage main\n```",
+		Files: []workspace.File{
+			{Path: "main.go", Content: "package main", Main: true},
+			{Path: "util/util.go", Content: "package util"},
+		},
+		Verdict: agent.Verdict{Kind: "build", Reason: "undefined: foo", Retry: true},
+	})
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	conv := llm.NewConversation("sys")
+	conv.AddUser("hello")
+	if err := store.SaveConversation(sess.ID, conv); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load(sess.ID[:len(sess.ID)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ID != sess.ID || len(loaded.Attempts) != 1 {
+		t.Fatalf("unexpected session %+v", loaded)
+	}
+	files := loaded.Attempts[0].Files
+	if len(files) != 2 || !files[0].Main || files[1].Path != "util/util.go" || files[1].Content != "package util" {
+		t.Errorf("unexpected files %+v", files)
+	}
+	if loaded.Attempts[0].Verdict.Reason != "undefined: foo" {
+		t.Errorf("verdict not persisted: %+v", loaded.Attempts[0].Verdict)
+	}
+	loadedConv, err := store.LoadConversation(sess.ID)
+	if err != nil || loadedConv.Len() != 2 {
+		t.Errorf("unexpected conversation %+v, %v", loadedConv, err)
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expect 1 session, got %d, %v", len(list), err)
+	}
+	if err := store.Delete(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(sess.ID); err == nil {
+		t.Errorf("expect error loading deleted session")
+	}
+}
+
+func TestStoreRejectsUnsafeIDs(t *testing.T) {
+	root := t.TempDir()
+	store := NewStore(filepath.Join(root, "sessions"))
+	// Dir 之外的 session.json 不能通过 .. 或分隔符读到
+	os.WriteFile(filepath.Join(root, "session.json"), []byte(`{"id":"x"}`), 0644)
+	for _, id := range []string{"..", "../", "..%2F", "a/b", `a\b`, ".", "ABC"} {
+		if _, err := store.Load(id); err == nil || !strings.Contains(err.Error(), "invalid session id") {
+			t.Errorf("Load(%q) = %v, want invalid session id", id, err)
+		}
+	}
+	if err := store.Save(&Session{ID: "../x"}); err == nil {
+		t.Error("expect Save to reject an unsafe id")
+	}
+
+	// 被篡改的文件名不能跳出尝试目录
+	sess := &Session{Mode: "gen"}
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	sess.Attempts = []Attempt{{Number: 1, FileNames: []string{"../../../session.json"}}}
+	data, _ := json.Marshal(sess)
+	os.WriteFile(filepath.Join(store.Dir, sess.ID, "session.json"), data, 0644)
+	if _, err := store.Load(sess.ID); err == nil || !strings.Contains(err.Error(), "invalid file") {
+		t.Errorf("expect invalid file error, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package usage 统计模型调用消耗的 token 与费用，按模型价格表估算费用，并按预算限制会话
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+)
+
+// Tokens 一次或多次调用消耗的 token 数，Cached 是 Prompt 中命中缓存的部分
+type Tokens struct {
+	Prompt     int `json:"prompt_tokens"`
+	Completion int `json:"completion_tokens"`
+	Cached     int `json:"cached_tokens,omitempty"`
+	Total      int `json:"total_tokens"`
+}
+
+// Add 累加 t 到 s
+func (s *Tokens) Add(t Tokens) {
+	s.Prompt += t.Prompt
+	s.Completion += t.Completion
+	s.Cached += t.Cached
+	s.Total += t.Total
+}
+
+// Price 模型每百万 token 的价格
+type Price struct {
+	Prompt     float64 `yaml:"prompt" json:"prompt"`
+	Completion float64 `yaml:"completion" json:"completion"`
+	// Cached 命中缓存的输入 token 的价格，为 0 时按 Prompt 计费
+	Cached float64 `yaml:"cached" json:"cached,omitempty"`
+}
+
+// Cost 返回 t 按该价格的费用
+func (p Price) Cost(t Tokens) float64 {
+	cached := p.Cached
+	if cached == 0 {
+		cached = p.Prompt
+	}
+	return (float64(t.Prompt-t.Cached)*p.Prompt + float64(t.Cached)*cached + float64(t.Completion)*p.Completion) / 1e6
+}
+
+// Pricing 以模型名为键的价格表，以 * 结尾的键匹配该前缀的全部模型
+type Pricing map[string]Price
+
+// Lookup 返回 model 的价格：优先完全匹配，其次最长的前缀匹配
+func (p Pricing) Lookup(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+	var (
+		best  Price
+		found bool
+		n     = -1
+	)
+	for key, price := range p {
+		prefix, ok := strings.CutSuffix(key, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) > n {
+			best, found, n = price, true, len(prefix)
+		}
+	}
+	return best, found
+}
+
+// Validate 检查价格表中没有负数价格
+func (p Pricing) Validate() error {
+	for model, price := range p {
+		if price.Prompt < 0 || price.Completion < 0 || price.Cached < 0 {
+			return fmt.Errorf("negative price for model %q", model)
+		}
+	}
+	return nil
+}
+
+// Call 一次模型调用
+type Call struct {
+	Model string `json:"model"`
+	Tokens
+	Cost float64 `json:"cost,omitempty"` // 价格表中没有该模型时为 0
+	// Estimated 服务未返回用量，token 数按消息与回复估算
+	Estimated bool          `json:"estimated,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Usage 多次模型调用的累计用量
+type Usage struct {
+	Calls int `json:"calls"`
+	Tokens
+	Cost float64 `json:"cost,omitempty"`
+	// Estimated 至少一次调用的用量是估算的
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Add 累加一次调用
+func (u *Usage) Add(c Call) {
+	u.Calls++
+	u.Tokens.Add(c.Tokens)
+	u.Cost += c.Cost
+	u.Estimated = u.Estimated || c.Estimated
+}
+
+// Merge 累加另一组用量
+func (u *Usage) Merge(o Usage) {
+	u.Calls += o.Calls
+	u.Tokens.Add(o.Tokens)
+	u.Cost += o.Cost
+	u.Estimated = u.Estimated || o.Estimated
+}
+
+func (u Usage) String() string {
+	s := fmt.Sprintf("%d 次调用，%d tokens（输入 %d", u.Calls, u.Total, u.Prompt)
+	if u.Cached > 0 {
+		s += fmt.Sprintf("，缓存 %d", u.Cached)
+	}
+	s += fmt.Sprintf("，输出 %d）", u.Completion)
+	if u.Estimated {
+		s += "，部分为估算"
+	}
+	if u.Cost > 0 {
+		s += fmt.Sprintf("，费用 $%.4f", u.Cost)
+	}
+	return s
+}
+
+// Budget 单个会话的用量上限，0 表示不限制
+type Budget struct {
+	Tokens int     `yaml:"tokens" json:"tokens,omitempty"`
+	Cost   float64 `yaml:"cost" json:"cost,omitempty"` // 美元
+}
+
+// Check 用量达到预算时返回 Budget 错误
+func (b Budget) Check(u Usage) error {
+	if b.Tokens > 0 && u.Total >= b.Tokens {
+		return errs.Errorf(errs.Budget, "check budget", "token budget %d exhausted (used %d)", b.Tokens, u.Total)
+	}
+	if b.Cost > 0 && u.Cost >= b.Cost {
+		return errs.Errorf(errs.Budget, "check budget", "cost budget $%.4f exhausted (used $%.4f)", b.Cost, u.Cost)
+	}
+	return nil
+}
+
+// Validate 检查预算不为负数
+func (b Budget) Validate() error {
+	if b.Tokens < 0 || b.Cost < 0 {
+		return fmt.Errorf("negative budget")
+	}
+	return nil
+}
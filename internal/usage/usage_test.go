@@ -0,0 +1,61 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+)
+
+func TestPricing(t *testing.T) {
+	pricing := Pricing{
+		"gpt-4o":       {Prompt: 2.5, Completion: 10, Cached: 1.25},
+		"gpt-4o*":      {Prompt: 5, Completion: 15},
+		"gpt-4o-mini*": {Prompt: 0.15, Completion: 0.6},
+		"*":            {Prompt: 1, Completion: 1},
+	}
+	for model, want := range map[string]float64{"gpt-4o": 2.5, "gpt-4o-2024-08-06": 5, "gpt-4o-mini-2024-07-18": 0.15, "other": 1} {
+		if p, ok := pricing.Lookup(model); !ok || p.Prompt != want {
+			t.Errorf("Lookup(%q) = %+v, %v, want prompt price %v", model, p, ok, want)
+		}
+	}
+	if _, ok := (Pricing{"gpt-4o": {}}).Lookup("deepseek-chat"); ok {
+		t.Error("expect no price for unknown model")
+	}
+
+	// 1000 个输入 token 中 400 个命中缓存
+	tokens := Tokens{Prompt: 1000, Completion: 500, Cached: 400, Total: 1500}
+	if got, want := pricing["gpt-4o"].Cost(tokens), (600*2.5+400*1.25+500*10)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", got, want)
+	}
+	if got, want := pricing["gpt-4o*"].Cost(tokens), (1000*5+500*15)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("cost without cached price = %v, want %v", got, want)
+	}
+	if err := (Pricing{"x": {Prompt: -1}}).Validate(); err == nil {
+		t.Error("expect error for negative price")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	var u Usage
+	u.Add(Call{Model: "m", Tokens: Tokens{Prompt: 80, Completion: 20, Total: 100}, Cost: 0.5})
+	u.Add(Call{Model: "m", Tokens: Tokens{Prompt: 40, Completion: 10, Total: 50}, Estimated: true})
+	if u.Calls != 2 || u.Total != 150 || u.Prompt != 120 || u.Cost != 0.5 || !u.Estimated {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+	for _, tc := range []struct {
+		budget Budget
+		over   bool
+	}{
+		{Budget{}, false},
+		{Budget{Tokens: 200}, false},
+		{Budget{Tokens: 150}, true},
+		{Budget{Cost: 1}, false},
+		{Budget{Cost: 0.5}, true},
+	} {
+		err := tc.budget.Check(u)
+		if (err != nil) != tc.over || (err != nil && errs.KindOf(err) != errs.Budget) {
+			t.Errorf("Check(%+v) = %v, want over budget %v", tc.budget, err, tc.over)
+		}
+	}
+}
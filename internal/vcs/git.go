@@ -0,0 +1,197 @@
+// Package vcs 通过本地 git 命令记录每次尝试：每个会话一个分支，每次尝试一个提交
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepo 目录不在 git 工作区中
+var ErrNotRepo = errors.New("not a git work tree")
+
+// Repo 一个 git 工作区；Dir 为 aca 的工作目录，可以是工作区的子目录，
+// 提交、恢复等操作只作用于 Dir 下的文件
+type Repo struct {
+	Dir  string
+	Root string // 工作区根目录
+	// 未配置 user.name/user.email 时使用的提交身份
+	env []string
+}
+
+// Open 打开 dir 所在的 git 工作区
+func Open(dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, err
+	}
+	r := &Repo{Dir: dir}
+	root, err := r.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotRepo)
+	}
+	r.Root = root
+	if name, _ := r.git("config", "user.name"); name == "" {
+		r.env = append(r.env, "GIT_AUTHOR_NAME=aca", "GIT_COMMITTER_NAME=aca")
+	}
+	if email, _ := r.git("config", "user.email"); email == "" {
+		r.env = append(r.env, "GIT_AUTHOR_EMAIL=aca@localhost", "GIT_COMMITTER_EMAIL=aca@localhost")
+	}
+	return r, nil
+}
+
+// Init 在 dir 中初始化仓库，dir 已在工作区中时直接打开
+func Init(dir string) (*Repo, error) {
+	if r, err := Open(dir); err == nil || !errors.Is(err, ErrNotRepo) {
+		return r, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := (&Repo{Dir: dir}).git("init", "-q"); err != nil {
+		return nil, err
+	}
+	return Open(dir)
+}
+
+// git 在 Dir 中执行 git 命令，返回去掉首尾空白的标准输出
+func (r *Repo) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), r.env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Head 返回 HEAD 指向的提交，仓库还没有提交时返回空串
+func (r *Repo) Head() string {
+	head, _ := r.git("rev-parse", "--verify", "-q", "HEAD")
+	return head
+}
+
+// Branch 返回当前分支名，分离 HEAD 时返回空串
+func (r *Repo) Branch() string {
+	branch, _ := r.git("symbolic-ref", "-q", "--short", "HEAD")
+	return branch
+}
+
+// EnsureHead 仓库还没有提交时创建一个空的初始提交，返回 HEAD
+func (r *Repo) EnsureHead() (string, error) {
+	if head := r.Head(); head != "" {
+		return head, nil
+	}
+	if _, err := r.git("commit", "-q", "--allow-empty", "-m", "aca: initial commit"); err != nil {
+		return "", err
+	}
+	return r.Head(), nil
+}
+
+// CreateBranch 从 HEAD 创建并切换到新分支，未提交的修改随之保留，调用方应先用 Dirty 检查
+func (r *Repo) CreateBranch(name string) error {
+	_, err := r.git("checkout", "-q", "-b", name)
+	return err
+}
+
+// Checkout 切换到已有分支，已在该分支时不做任何事
+func (r *Repo) Checkout(name string) error {
+	if r.Branch() == name {
+		return nil
+	}
+	_, err := r.git("checkout", "-q", name)
+	return err
+}
+
+// Dirty 返回 Dir 下相对 HEAD 有未提交修改（包括已暂存）的已跟踪文件，未跟踪文件不计入
+func (r *Repo) Dirty() ([]string, error) {
+	out, err := r.git("diff", "--name-only", "--relative", "HEAD", "--", ".")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// Commit 提交 Dir 下已跟踪文件的修改与删除，以及 paths（相对 Dir）中新建的文件；
+// 其他未跟踪文件不会被提交。没有修改时创建空提交，返回提交哈希
+func (r *Repo) Commit(message string, paths ...string) (string, error) {
+	if _, err := r.git("add", "-u", "--", "."); err != nil {
+		return "", err
+	}
+	if len(paths) > 0 {
+		// 只暂存 paths 中存在、未跟踪且未被忽略的文件
+		out, err := r.git(append([]string{"ls-files", "-z", "--others", "--exclude-standard", "--"}, paths...)...)
+		if err != nil {
+			return "", err
+		}
+		var added []string
+		for _, f := range strings.Split(out, "\x00") {
+			if f != "" {
+				added = append(added, f)
+			}
+		}
+		if len(added) > 0 {
+			if _, err := r.git(append([]string{"add", "--"}, added...)...); err != nil {
+				return "", err
+			}
+		}
+	}
+	args := []string{"commit", "-q", "-m", message}
+	if _, err := r.git("diff", "--cached", "--quiet", "--", "."); err == nil {
+		// Dir 下没有修改，只记录本次尝试
+		args = append(args, "--only", "--allow-empty")
+	} else {
+		args = append(args, "--", ".")
+	}
+	if _, err := r.git(args...); err != nil {
+		return "", err
+	}
+	return r.Head(), nil
+}
+
+// Restore 将 Dir 下的文件恢复为 rev 中的内容：rev 中不存在的已跟踪文件会被删除，未跟踪文件保持不变
+func (r *Repo) Restore(rev string) error {
+	_, err := r.git("restore", "--source="+rev, "--staged", "--worktree", "--", ".")
+	if err != nil && strings.Contains(err.Error(), "did not match any file") {
+		// rev 与索引中 Dir 下都没有文件
+		return nil
+	}
+	return err
+}
+
+// Squash 将 branch 上 base 之后的提交合并为一个提交，不改动工作区，返回新提交哈希
+func (r *Repo) Squash(branch, base, message string) (string, error) {
+	commit, err := r.git("commit-tree", branch+"^{tree}", "-p", base, "-m", message)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.git("update-ref", "refs/heads/"+branch, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// FormatPatch 将 base..branch 的提交导出为 outDir 中的补丁文件，返回文件路径
+func (r *Repo) FormatPatch(branch, base, outDir string) ([]string, error) {
+	abs, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.git("format-patch", "-o", abs, base+".."+branch)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
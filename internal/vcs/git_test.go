@@ -0,0 +1,104 @@
+package vcs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func newRepo(t *testing.T) *Repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if _, err := Open(dir); err == nil {
+		t.Skip("temp dir is inside a git work tree")
+	}
+	r, err := Init(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func write(t *testing.T, r *Repo, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(r.Dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitRestoreSquash(t *testing.T) {
+	r := newRepo(t)
+	base, err := r.EnsureHead()
+	if err != nil || base == "" {
+		t.Fatalf("EnsureHead: %q %v", base, err)
+	}
+	if err := r.CreateBranch("aca/test"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, r, "main.go", "v1\n")
+	write(t, r, "notes.txt", "scratch\n")
+	first, err := r.Commit("attempt 1", "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, r, "main.go", "v2\n")
+	write(t, r, "util.go", "util\n")
+	if _, err := r.Commit("attempt 2", "main.go", "util.go"); err != nil {
+		t.Fatal(err)
+	}
+	// 没有修改时仍记录一次提交
+	empty, err := r.Commit("attempt 3")
+	if err != nil || empty == "" {
+		t.Fatalf("empty commit: %q %v", empty, err)
+	}
+
+	// 只提交会话写入的文件，其他未跟踪文件保持不变
+	if files, _ := r.git("ls-files"); files != "main.go\nutil.go" {
+		t.Errorf("unexpected tracked files %q", files)
+	}
+
+	if err := r.Restore(first); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(r.Dir, "main.go")); string(data) != "v1\n" {
+		t.Errorf("restore did not reset main.go: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(r.Dir, "util.go")); !os.IsNotExist(err) {
+		t.Errorf("restore should remove files added after the revision")
+	}
+	if _, err := r.Commit("undo"); err != nil {
+		t.Fatal(err)
+	}
+
+	squashed, err := r.Squash("aca/test", base, "squashed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent, _ := r.git("rev-parse", squashed+"^"); parent != base {
+		t.Errorf("squashed commit parent %s, want %s", parent, base)
+	}
+	files, err := r.FormatPatch("aca/test", base, t.TempDir())
+	if err != nil || len(files) != 1 {
+		t.Fatalf("format-patch: %v %v", files, err)
+	}
+}
+
+func TestDirty(t *testing.T) {
+	r := newRepo(t)
+	write(t, r, "main.go", "v1\n")
+	if _, err := r.Commit("initial", "main.go"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, r, "notes.txt", "scratch\n")
+	if dirty, err := r.Dirty(); err != nil || len(dirty) != 0 {
+		t.Errorf("untracked files should not count as dirty: %v %v", dirty, err)
+	}
+	write(t, r, "main.go", "wip\n")
+	if dirty, err := r.Dirty(); err != nil || len(dirty) != 1 || dirty[0] != "main.go" {
+		t.Errorf("expect main.go to be dirty, got %v %v", dirty, err)
+	}
+}
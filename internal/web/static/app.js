@@ -0,0 +1,352 @@
+// Agent Cat Agent 监控面板：只依赖 aca serve 的 HTTP API，不使用第三方库
+"use strict";
+
+const FINISHED = ["passed", "failed", "canceled"];
+
+const state = { tab: "jobs", selected: null, events: null, timer: null };
+
+const $ = (sel, root = document) => root.querySelector(sel);
+
+// el 创建元素，attrs 中的 class/text/on* 分别设置类名、文本与事件
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) {
+    if (k === "class") node.className = v;
+    else if (k === "text") node.textContent = v;
+    else if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) if (c != null) node.append(c);
+  return node;
+}
+
+// token aca serve 配置了令牌时，通过 /#token=<令牌> 打开面板，令牌保存在本标签页的 sessionStorage 中
+const token = (() => {
+  const m = location.hash.match(/token=([^&]+)/);
+  if (m) {
+    sessionStorage.setItem("aca-token", decodeURIComponent(m[1]));
+    history.replaceState(null, "", location.pathname + location.search);
+  }
+  return sessionStorage.getItem("aca-token") || "";
+})();
+
+// withToken 为 EventSource 等无法设置请求头的连接在 URL 上附加令牌
+function withToken(path) {
+  return token ? path + (path.includes("?") ? "&" : "?") + "access_token=" + encodeURIComponent(token) : path;
+}
+
+async function api(path, opts = {}) {
+  if (token) opts.headers = { ...opts.headers, Authorization: "Bearer " + token };
+  const resp = await fetch(path, opts);
+  const type = resp.headers.get("Content-Type") || "";
+  const body = type.includes("json") ? await resp.json() : await resp.text();
+  if (!resp.ok) throw new Error(body.error || body || resp.statusText);
+  return body;
+}
+
+function badge(status) {
+  return el("span", { class: "badge " + status, text: status });
+}
+
+function fmtTime(t) {
+  return t ? new Date(t).toLocaleString() : "";
+}
+
+function fmtDuration(ns) {
+  return ns ? (ns / 1e9).toFixed(1) + "s" : "";
+}
+
+// ---------- 语法高亮 ----------
+
+const KEYWORDS = {
+  go: "break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota",
+  python: "and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self",
+};
+
+function languageOf(path) {
+  if (path.endsWith(".go")) return "go";
+  if (path.endsWith(".py")) return "python";
+  return "";
+}
+
+// highlight 返回带语法高亮的 pre，只识别注释、字符串、数字、关键字与函数调用
+function highlight(code, lang) {
+  const pre = el("pre");
+  const words = new Set((KEYWORDS[lang] || "").split(" "));
+  const comment = lang === "python" ? "#[^\\n]*" : "\\/\\/[^\\n]*|\\/\\*[\\s\\S]*?\\*\\/";
+  const strings = lang === "python"
+    ? "\"\"\"[\\s\\S]*?\"\"\"|'''[\\s\\S]*?'''|\"(?:\\\\.|[^\"\\\\\\n])*\"|'(?:\\\\.|[^'\\\\\\n])*'"
+    : "`[^`]*`|\"(?:\\\\.|[^\"\\\\\\n])*\"|'(?:\\\\.|[^'\\\\\\n])*'";
+  const re = new RegExp(`(${comment})|(${strings})|(\\b\\d[\\d_.xXa-fA-F]*\\b)|([A-Za-z_]\\w*)(?=\\s*\\()|([A-Za-z_]\\w*)`, "g");
+  let last = 0;
+  for (const m of code.matchAll(re)) {
+    if (m.index > last) pre.append(code.slice(last, m.index));
+    let cls = "";
+    if (m[1]) cls = "tok-com";
+    else if (m[2]) cls = "tok-str";
+    else if (m[3]) cls = "tok-num";
+    else if (m[4]) cls = words.has(m[4]) ? "tok-kw" : "tok-fn";
+    else if (m[5] && words.has(m[5])) cls = "tok-kw";
+    pre.append(cls ? el("span", { class: cls, text: m[0] }) : m[0]);
+    last = m.index + m[0].length;
+  }
+  pre.append(code.slice(last));
+  return pre;
+}
+
+function renderDiff(text) {
+  const pre = el("pre", { class: "diff" });
+  if (!text) {
+    pre.textContent = "（无变化）";
+    return pre;
+  }
+  for (const line of text.split("\n")) {
+    let cls = "";
+    if (line.startsWith("+") && !line.startsWith("+++")) cls = "add";
+    else if (line.startsWith("-") && !line.startsWith("---")) cls = "del";
+    else if (line.startsWith("@@")) cls = "hunk";
+    pre.append(el("span", { class: cls, text: line + "\n" }));
+  }
+  return pre;
+}
+
+// fileViewer 左侧为按目录缩进的文件树，右侧为选中文件的高亮内容
+function fileViewer(files) {
+  if (!files || files.length === 0) return el("p", { class: "empty", text: "没有文件" });
+  const tree = el("div", { class: "tree" });
+  const code = el("div", { class: "code" });
+  const sorted = [...files].sort((a, b) => a.path.localeCompare(b.path));
+  const seen = new Set();
+  const show = (f, row) => {
+    tree.querySelectorAll(".selected").forEach((n) => n.classList.remove("selected"));
+    row.classList.add("selected");
+    code.replaceChildren(highlight(f.content, languageOf(f.path)));
+  };
+  let first = null;
+  for (const f of sorted) {
+    const parts = f.path.split("/");
+    for (let i = 1; i < parts.length; i++) {
+      const dir = parts.slice(0, i).join("/");
+      if (seen.has(dir)) continue;
+      seen.add(dir);
+      tree.append(el("div", { class: "dir", style: `padding-left:${8 + (i - 1) * 12}px`, text: parts[i - 1] + "/" }));
+    }
+    const row = el("div", { style: `padding-left:${8 + (parts.length - 1) * 12}px`, text: parts[parts.length - 1] + (f.main ? " ★" : "") });
+    row.addEventListener("click", () => show(f, row));
+    tree.append(row);
+    if (!first) first = () => show(f, row);
+  }
+  first();
+  return el("div", { class: "files" }, tree, code);
+}
+
+// ---------- 列表 ----------
+
+async function renderList() {
+  const list = $("#list");
+  try {
+    if (state.tab === "jobs") {
+      const jobs = (await api("/jobs")).reverse();
+      list.replaceChildren(...jobs.map((j) => listItem("job", j.id, j.request.prompt, j.status, `${j.request.mode || "gen"} · ${fmtTime(j.created_at)}`)));
+      if (jobs.length === 0) list.replaceChildren(el("p", { class: "empty item", text: "还没有任务" }));
+    } else {
+      const sessions = await api("/sessions");
+      list.replaceChildren(...sessions.map((s) => listItem("session", s.id, s.prompt || "(chat)", s.status, `${s.mode} · ${s.language} · ${fmtTime(s.created_at)}`)));
+      if (sessions.length === 0) list.replaceChildren(el("p", { class: "empty item", text: "还没有会话" }));
+    }
+  } catch (e) {
+    list.replaceChildren(el("p", { class: "error item", text: e.message }));
+  }
+}
+
+function listItem(kind, id, prompt, status, meta) {
+  const item = el("div", { class: "item" + (state.selected === kind + ":" + id ? " selected" : "") },
+    el("div", { class: "prompt", text: prompt }),
+    el("div", { class: "meta" }, badge(status), " ", meta));
+  item.addEventListener("click", () => (kind === "job" ? showJob(id) : showSession(id)));
+  return item;
+}
+
+function selectTab(tab) {
+  state.tab = tab;
+  document.querySelectorAll("nav button").forEach((b) => b.classList.toggle("active", b.dataset.tab === tab));
+  clearInterval(state.timer);
+  if (tab === "submit") {
+    showSubmit();
+    return;
+  }
+  renderList();
+  state.timer = setInterval(renderList, 3000);
+}
+
+function closeEvents() {
+  if (state.events) state.events.close();
+  state.events = null;
+}
+
+// ---------- 任务 ----------
+
+async function showJob(id) {
+  closeEvents();
+  state.selected = "job:" + id;
+  renderList();
+  const detail = $("#detail");
+  let job;
+  try {
+    job = await api("/jobs/" + id);
+  } catch (e) {
+    detail.replaceChildren(el("p", { class: "error", text: e.message }));
+    return;
+  }
+  const title = el("h2", {}, "任务 " + job.id, badge(job.status));
+  const actions = el("div", { class: "actions" });
+  const info = el("div");
+  const llm = el("pre", { class: "log" });
+  const logs = el("pre", { class: "log" });
+  const timeline = el("pre", { class: "log" });
+  const files = el("div", {}, el("p", { class: "empty", text: "等待生成文件" }));
+  detail.replaceChildren(title, actions, el("div", { class: "prompt-box", text: job.request.prompt }), info,
+    el("h3", { text: "模型输出" }), llm,
+    el("h3", { text: "容器日志" }), logs,
+    el("h3", { text: "事件" }), timeline,
+    el("h3", { text: "文件" }), files);
+
+  const update = (j) => {
+    title.replaceChildren("任务 " + j.id, badge(j.status));
+    actions.replaceChildren();
+    if (!FINISHED.includes(j.status)) {
+      actions.append(el("button", { class: "danger", text: "取消", onclick: () => api("/jobs/" + j.id, { method: "DELETE" }).catch((e) => alert(e.message)) }));
+    }
+    info.replaceChildren();
+    if (j.error) info.append(el("p", { class: "error", text: `${j.error_kind || ""} ${j.error}` }));
+    if (j.session_id) info.append(el("p", {}, "会话 ", el("a", { href: "#", text: j.session_id, onclick: (e) => { e.preventDefault(); showSession(j.session_id); } })));
+  };
+  update(job);
+  const log = (pre, text, cls) => {
+    const stick = pre.scrollTop + pre.clientHeight >= pre.scrollHeight - 4;
+    pre.append(cls ? el("span", { class: cls, text }) : text);
+    if (stick) pre.scrollTop = pre.scrollHeight;
+  };
+  const prefix = (e) => (e.attempt ? `[#${e.attempt}] ` : "");
+
+  const es = new EventSource(withToken("/jobs/" + id + "/events"));
+  state.events = es;
+  const on = (type, fn) => es.addEventListener(type, (m) => fn(JSON.parse(m.data)));
+  on("token", (e) => log(llm, e.data.text));
+  on("tool_call", (e) => log(timeline, `${prefix(e)}工具调用 ${e.data.name} ${e.data.arguments}\n`));
+  on("files", (e) => {
+    files.replaceChildren(fileViewer(e.data.files));
+    log(timeline, `${prefix(e)}写入 ${e.data.files.length} 个文件\n`);
+  });
+  on("container", (e) => log(logs, `${prefix(e)}$ 容器 ${e.data.id.slice(0, 12)} (${e.data.image}) 已启动\n`, "info"));
+  on("output", (e) => log(logs, e.data.line + "\n", e.data.stream === "stderr" ? "stderr" : ""));
+  on("test_result", (e) => log(timeline, `${prefix(e)}测试${e.data.passed ? "通过" : "未通过: " + e.data.error}\n`));
+  on("verdict", (e) => {
+    log(timeline, `${prefix(e)}判定: ${e.data.passed ? "通过" : e.data.reason}\n`, e.data.passed ? "info" : "stderr");
+    log(llm, "\n");
+  });
+  on("retry", (e) => log(timeline, `${prefix(e)}反馈错误，开始第 ${e.data.next} 次尝试\n`));
+  on("done", (e) => log(timeline, `会话结束: ${e.data.status}\n`));
+  on("job", (e) => {
+    update(e.data);
+    if (FINISHED.includes(e.data.status)) {
+      // 服务端在任务结束后关闭连接，避免 EventSource 自动重连
+      es.close();
+      renderList();
+    }
+  });
+}
+
+// ---------- 会话 ----------
+
+async function showSession(id) {
+  closeEvents();
+  state.selected = "session:" + id;
+  if (state.tab === "sessions") renderList();
+  const detail = $("#detail");
+  let sess;
+  try {
+    sess = await api("/sessions/" + id);
+  } catch (e) {
+    detail.replaceChildren(el("p", { class: "error", text: e.message }));
+    return;
+  }
+  const conversation = el("div");
+  for (const m of (sess.conversation && sess.conversation.messages) || []) {
+    let text = m.content;
+    if (m.tool_calls) text += m.tool_calls.map((c) => `\n→ ${c.name} ${c.arguments}`).join("");
+    conversation.append(el("div", { class: "message " + m.role }, el("div", { class: "role", text: m.role }), el("div", { class: "prompt-box", text })));
+  }
+  const attempt = el("div");
+  const rows = (sess.attempts || []).map((a, i) => {
+    const row = el("tr", {},
+      el("td", { text: "#" + a.number }),
+      el("td", {}, badge(a.verdict.passed ? "passed" : "failed")),
+      el("td", { text: a.verdict.reason || "" }),
+      el("td", { text: String(sess.attempt_files[i].length) }),
+      el("td", { text: fmtDuration(a.duration) }));
+    row.addEventListener("click", () => {
+      table.querySelectorAll(".selected").forEach((n) => n.classList.remove("selected"));
+      row.classList.add("selected");
+      showAttempt(sess, i, attempt);
+    });
+    return row;
+  });
+  const table = el("table", { class: "attempts" },
+    el("tr", {}, ...["尝试", "判定", "原因", "文件", "耗时"].map((h) => el("th", { text: h }))), ...rows);
+  detail.replaceChildren(
+    el("h2", {}, "会话 " + sess.id, badge(sess.status)),
+    el("p", { class: "empty", text: `${sess.mode} · ${sess.language} · ${sess.model || "默认模型"} · ${sess.workdir}` }),
+    el("div", { class: "prompt-box", text: sess.prompt || "(chat)" }),
+    sess.error ? el("p", { class: "error", text: sess.error }) : null,
+    el("h3", { text: "尝试" }), table, attempt,
+    el("h3", { text: "对话" }), conversation);
+  if (rows.length > 0) rows[rows.length - 1].click();
+}
+
+async function showAttempt(sess, i, root) {
+  const a = sess.attempts[i];
+  const diff = el("div", {}, el("p", { class: "empty", text: "加载中" }));
+  root.replaceChildren(
+    el("h3", { text: `第 ${a.number} 次尝试的文件` }), fileViewer(sess.attempt_files[i]),
+    el("h3", { text: i > 0 ? `相对第 ${sess.attempts[i - 1].number} 次尝试的修改` : "相对空目录的修改" }), diff,
+    a.output ? el("h3", { text: "运行输出" }) : null, a.output ? el("pre", { class: "log", text: a.output }) : null,
+    a.review ? el("h3", { text: "审查意见" }) : null, a.review ? el("pre", { text: JSON.stringify(a.review, null, 2) }) : null);
+  try {
+    const from = i > 0 ? sess.attempts[i - 1].number : 0;
+    diff.replaceChildren(renderDiff(await api(`/sessions/${sess.id}/diff?from=${from}&to=${a.number}`)));
+  } catch (e) {
+    diff.replaceChildren(el("p", { class: "error", text: e.message }));
+  }
+}
+
+// ---------- 提交 ----------
+
+function showSubmit() {
+  closeEvents();
+  const form = $("#submit-form").content.firstElementChild.cloneNode(true);
+  form.addEventListener("submit", async (ev) => {
+    ev.preventDefault();
+    const data = new FormData(form);
+    const req = { mode: data.get("mode"), prompt: data.get("prompt"), options: {} };
+    if (data.get("language")) req.language = data.get("language");
+    if (data.get("model")) req.model = data.get("model");
+    if (data.get("max_attempts")) req.options.max_attempts = Number(data.get("max_attempts"));
+    if (data.get("review")) req.options.review = true;
+    if (data.get("lint")) req.options.lint = true;
+    try {
+      const job = await api("/jobs", { method: "POST", headers: { "Content-Type": "application/json" }, body: JSON.stringify(req) });
+      selectTab("jobs");
+      showJob(job.id);
+    } catch (e) {
+      const err = $(".error", form);
+      err.textContent = e.message;
+      err.hidden = false;
+    }
+  });
+  $("#list").replaceChildren();
+  $("#detail").replaceChildren(el("h2", { text: "提交新任务" }), form);
+}
+
+document.querySelectorAll("nav button").forEach((b) => b.addEventListener("click", () => selectTab(b.dataset.tab)));
+selectTab("jobs");
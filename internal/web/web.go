@@ -0,0 +1,20 @@
+// Package web 内嵌 aca serve 的监控面板：单页应用，通过 HTTP API 查看任务与会话、订阅实时事件、提交与取消任务
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler 返回提供面板静态文件的处理器，根路径为 index.html
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,96 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/diff"
+)
+
+// File 工作目录中的一个文件
+type File struct {
+	Path    string `json:"path"` // 相对工作目录的路径
+	Content string `json:"content"`
+	Main    bool   `json:"main,omitempty"` // 是否为可运行的主文件
+}
+
+// DiffFiles 生成两组文件之间的 unified diff，新增/删除的文件以 /dev/null 表示
+func DiffFiles(before, after []File) string {
+	oldByPath := make(map[string]string, len(before))
+	newByPath := make(map[string]string, len(after))
+	var paths []string
+	for _, f := range before {
+		oldByPath[f.Path] = f.Content
+		paths = append(paths, f.Path)
+	}
+	for _, f := range after {
+		if _, ok := oldByPath[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+		newByPath[f.Path] = f.Content
+	}
+	sort.Strings(paths)
+	var sb strings.Builder
+	for _, path := range paths {
+		oldName, newName := "a/"+path, "b/"+path
+		if _, ok := oldByPath[path]; !ok {
+			oldName = "/dev/null"
+		}
+		if _, ok := newByPath[path]; !ok {
+			newName = "/dev/null"
+		}
+		sb.WriteString(diff.Unified(oldName, newName, oldByPath[path], newByPath[path], 3))
+	}
+	return sb.String()
+}
+
+// WriteFiles 将文件写入 dir，按需创建子目录；路径不安全（见 SafePath）时返回错误
+func WriteFiles(dir string, files []File) error {
+	for _, f := range files {
+		path, err := SafePath(dir, f.Path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveFiles 删除 dir 中的文件，并清理因此变空的子目录；跳过不安全的路径
+func RemoveFiles(dir string, files []File) error {
+	for _, f := range files {
+		path, err := SafePath(dir, f.Path)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if sub := filepath.Dir(path); sub != filepath.Clean(dir) {
+			os.Remove(sub) // 非空目录会删除失败，忽略
+		}
+	}
+	return nil
+}
+
+// ReadFiles 读取 paths 指向的文件，路径转换为相对 dir
+func ReadFiles(dir string, paths []string, main bool) []File {
+	files := make([]File, 0, len(paths))
+	for _, path := range paths {
+		data, _ := os.ReadFile(path)
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		files = append(files, File{Path: rel, Content: string(data), Main: main})
+	}
+	return files
+}
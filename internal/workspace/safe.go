@@ -0,0 +1,130 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Limits 写入模型生成文件时的数量与大小限制，字段为 0 表示不限制
+type Limits struct {
+	MaxFiles     int
+	MaxFileSize  int // 单个文件的最大字节数
+	MaxTotalSize int // 全部文件的最大字节数
+}
+
+// DefaultLimits 默认限制：最多 50 个文件，单个文件 1 MiB，总计 4 MiB
+var DefaultLimits = Limits{MaxFiles: 50, MaxFileSize: 1 << 20, MaxTotalSize: 4 << 20}
+
+// Rejected 一个被拒绝写入的文件
+type Rejected struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (r Rejected) String() string {
+	return r.Path + ": " + r.Reason
+}
+
+// RejectedError 模型给出的文件未通过校验，没有任何文件被写入
+type RejectedError struct {
+	Files []Rejected
+}
+
+func (e *RejectedError) Error() string {
+	parts := make([]string, len(e.Files))
+	for i, r := range e.Files {
+		parts[i] = r.String()
+	}
+	return "rejected files: " + strings.Join(parts, "; ")
+}
+
+// SafePath 将相对 dir 的文件名规范化为 dir 内的绝对路径：拒绝绝对路径、跳出 dir 的 ..、
+// 以及经由符号链接指向 dir 之外的路径；目标本身是符号链接时同样拒绝
+func SafePath(dir, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("absolute path")
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("path escapes workdir")
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(root, clean)
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("target is a symlink")
+	}
+	// 检查已存在的最深一级父目录解析符号链接后仍在 dir 内
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(parent); err == nil {
+			break
+		}
+		parent = filepath.Dir(parent)
+	}
+	realParent, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, realParent); err != nil || !(rel == "." || filepath.IsLocal(rel)) {
+		return "", fmt.Errorf("path escapes workdir through a symlink")
+	}
+	return path, nil
+}
+
+// Validate 校验将要写入 dir 的文件（相对 dir 的路径到内容），返回规范化后的绝对路径到内容的映射；
+// 存在被拒绝的文件时返回 *RejectedError
+func Validate(dir string, files map[string]string, limits Limits) (map[string]string, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var rejected []Rejected
+	out := make(map[string]string, len(files))
+	total := 0
+	for i, name := range names {
+		content := files[name]
+		if limits.MaxFiles > 0 && i >= limits.MaxFiles {
+			rejected = append(rejected, Rejected{name, fmt.Sprintf("more than %d files", limits.MaxFiles)})
+			continue
+		}
+		if limits.MaxFileSize > 0 && len(content) > limits.MaxFileSize {
+			rejected = append(rejected, Rejected{name, fmt.Sprintf("%d bytes exceeds the %d byte limit", len(content), limits.MaxFileSize)})
+			continue
+		}
+		if total += len(content); limits.MaxTotalSize > 0 && total > limits.MaxTotalSize {
+			rejected = append(rejected, Rejected{name, fmt.Sprintf("total size exceeds the %d byte limit", limits.MaxTotalSize)})
+			continue
+		}
+		path, err := SafePath(dir, name)
+		if err != nil {
+			rejected = append(rejected, Rejected{name, err.Error()})
+			continue
+		}
+		if _, dup := out[path]; dup {
+			rejected = append(rejected, Rejected{name, "duplicate path"})
+			continue
+		}
+		out[path] = content
+	}
+	if len(rejected) > 0 {
+		return out, &RejectedError{Files: rejected}
+	}
+	return out, nil
+}
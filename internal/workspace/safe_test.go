@@ -0,0 +1,70 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafePath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.Symlink(filepath.Join(outside, "x"), filepath.Join(dir, "file-link"))
+	cases := []struct {
+		name string
+		ok   bool
+	}{
+		{"main.go", true},
+		{"./pkg//util.go", true},
+		{"pkg/../main.go", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../../.bashrc", false},
+		{"pkg/../../x.go", false},
+		{"link/x.go", false},
+		{"link/sub/x.go", false},
+		{"file-link", false},
+	}
+	for _, c := range cases {
+		path, err := SafePath(dir, c.name)
+		if (err == nil) != c.ok {
+			t.Errorf("SafePath(%q) = %q, %v; want ok=%v", c.name, path, err, c.ok)
+		}
+		if err == nil && !strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+			t.Errorf("SafePath(%q) = %q is outside %s", c.name, path, dir)
+		}
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":      "package main\n",
+		"b.go":      strings.Repeat("x", 100),
+		"../c.go":   "package main\n",
+		"d/../a.go": "package main\n",
+	}
+	out, err := Validate(dir, files, Limits{MaxFiles: 10, MaxFileSize: 50})
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("expect RejectedError, got %v", err)
+	}
+	got := make(map[string]bool)
+	for _, r := range rejected.Files {
+		got[r.Path] = true
+	}
+	if len(rejected.Files) != 3 || !got["b.go"] || !got["../c.go"] || !got["d/../a.go"] {
+		t.Errorf("unexpected rejected files %v", rejected.Files)
+	}
+	if len(out) != 1 {
+		t.Errorf("expect 1 accepted file, got %v", out)
+	}
+	if _, err := Validate(dir, map[string]string{"a.go": "", "b.go": ""}, Limits{MaxFiles: 1}); err == nil {
+		t.Errorf("expect file count limit to reject files")
+	}
+}
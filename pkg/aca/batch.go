@@ -0,0 +1,132 @@
+package aca
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+)
+
+// maxBatchLine 任务文件中单行的最大字节数
+const maxBatchLine = 1 << 20
+
+// BatchTask 任务文件中的一行
+type BatchTask struct {
+	Line int // 在文件中的行号
+	JobRequest
+}
+
+// BatchResult 一个批量任务的结果
+type BatchResult struct {
+	Line int `json:"line"`
+	Job
+	Duration time.Duration `json:"duration"`
+}
+
+// BatchSummary 批量运行的汇总
+type BatchSummary struct {
+	Total    int           `json:"total"`
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+	Canceled int           `json:"canceled"`
+	Tokens   int           `json:"tokens"`
+	Cost     float64       `json:"cost,omitempty"`
+	Duration time.Duration `json:"duration"`  // 整个批次的耗时
+	TaskTime time.Duration `json:"task_time"` // 各任务耗时之和
+}
+
+// ReadBatch 读取 JSONL 任务文件，每行一个 JobRequest（mode、prompt、language、model、options）；
+// 空行与 # 开头的行被忽略，格式错误时返回带行号的 Usage 错误
+func ReadBatch(r io.Reader) ([]BatchTask, error) {
+	var tasks []BatchTask
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxBatchLine)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var req JobRequest
+		dec := json.NewDecoder(bytes.NewReader([]byte(line)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return nil, errs.Errorf(errs.Usage, "read batch", "line %d: %v", n, err)
+		}
+		tasks = append(tasks, BatchTask{Line: n, JobRequest: req})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errs.E(errs.Usage, "read batch", err)
+	}
+	return tasks, nil
+}
+
+// RunBatch 最多以 parallel 个并发运行 tasks，每个任务使用独立的运行目录与容器；
+// 每个任务结束时按完成顺序调用 onResult（不会并发调用）。ctx 取消后未开始的任务记为已取消，
+// 运行中的任务被中断，其容器随之删除
+func (p *Pipeline) RunBatch(ctx context.Context, defaults Task, tasks []BatchTask, parallel int, onResult func(BatchResult)) BatchSummary {
+	start := time.Now()
+	q := NewJobQueue(p.With(WithRunDirs(true)), defaults, parallel, len(tasks)+1)
+	defer q.Close()
+	stop := context.AfterFunc(ctx, q.Close)
+	defer stop()
+
+	results := make(chan BatchResult)
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		job, err := q.Submit(t.JobRequest)
+		if err != nil {
+			// 校验失败的任务不进入队列，直接记为失败
+			now := time.Now()
+			job = Job{Request: t.JobRequest, Status: JobFailed, Error: err.Error(), ErrorKind: KindOf(err).String(), CreatedAt: now, FinishedAt: &now}
+			if ctx.Err() != nil {
+				job.Status = JobCanceled
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results <- BatchResult{Line: t.Line, Job: job}
+			}()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, _ := q.Wait(context.Background(), job.ID)
+			res := BatchResult{Line: t.Line, Job: job}
+			if job.StartedAt != nil && job.FinishedAt != nil {
+				res.Duration = job.FinishedAt.Sub(*job.StartedAt)
+			}
+			results <- res
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	sum := BatchSummary{Total: len(tasks)}
+	for res := range results {
+		switch res.Status {
+		case JobPassed:
+			sum.Passed++
+		case JobCanceled:
+			sum.Canceled++
+		default:
+			sum.Failed++
+		}
+		sum.Tokens += res.Tokens
+		sum.Cost += res.Cost
+		sum.TaskTime += res.Duration
+		if onResult != nil {
+			onResult(res)
+		}
+	}
+	sum.Duration = time.Since(start)
+	return sum
+}
@@ -0,0 +1,71 @@
+package aca
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	tasks, err := ReadBatch(strings.NewReader(`# tasks
+{"prompt":"a","language":"python"}
+
+{"mode":"test","prompt":"b","options":{"max_attempts":2}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Line != 2 || tasks[1].Line != 4 || tasks[1].Mode != ModeTest || tasks[1].Options.MaxAttempts != 2 {
+		t.Errorf("unexpected tasks %+v", tasks)
+	}
+	if _, err := ReadBatch(strings.NewReader("{\"prompt\":\"a\"}\n{\"promt\":\"b\"}\n")); KindOf(err) != ErrUsage || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expect usage error for line 2, got %v", err)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	p, _ := New(WithProvider(&fakeProvider{content: "```python\nprint('hi')\n```"}), WithRuntime(&fakeRuntime{output: "hi\n"}), WithLogger(DiscardLogger))
+	tasks := []BatchTask{
+		{Line: 1, JobRequest: JobRequest{Prompt: "say hi", Language: "python"}},
+		{Line: 2, JobRequest: JobRequest{Mode: ModePatch, Prompt: "x"}},
+		{Line: 3, JobRequest: JobRequest{Prompt: "say hi again", Language: "python"}},
+	}
+	var results []BatchResult
+	sum := p.RunBatch(context.Background(), Task{WorkDir: t.TempDir()}, tasks, 1, func(res BatchResult) {
+		results = append(results, res)
+	})
+	if sum.Total != 3 || sum.Passed != 2 || sum.Failed != 1 || sum.Tokens == 0 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	dirs := map[string]bool{}
+	for _, res := range results {
+		if res.Line == 2 {
+			if res.Status != JobFailed || res.ErrorKind != "usage" {
+				t.Errorf("expect usage failure for patch task, got %+v", res.Job)
+			}
+			continue
+		}
+		if res.Status != JobPassed || res.Tokens == 0 {
+			t.Errorf("unexpected result %+v", res)
+		}
+		dirs[res.WorkDir] = true
+	}
+	if len(dirs) != 2 {
+		t.Errorf("tasks should run in separate directories, got %v", dirs)
+	}
+}
+
+func TestRunBatchCancel(t *testing.T) {
+	prov := &blockingProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	p, _ := New(WithProvider(prov), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-prov.started
+		cancel()
+	}()
+	tasks := []BatchTask{{Line: 1, JobRequest: JobRequest{Prompt: "a"}}, {Line: 2, JobRequest: JobRequest{Prompt: "b"}}}
+	sum := p.RunBatch(ctx, Task{WorkDir: t.TempDir()}, tasks, 1, nil)
+	if sum.Canceled != 2 || sum.Passed != 0 {
+		t.Errorf("expect both tasks canceled, got %+v", sum)
+	}
+}
@@ -0,0 +1,250 @@
+package aca
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// Chat 多轮会话，保留与模型的对话历史，每轮在同一 WorkDir 中重新运行代码。
+// 每一轮记录为会话的一次 Attempt，其 Files 为本轮结束后工作目录中的文件。
+// Chat 不可并发使用。
+type Chat struct {
+	p    *Pipeline
+	task Task
+	conv *Conversation
+	sess *Session
+	// orig 会话覆盖的已有文件的原始内容，切换或撤销轮次时据此恢复
+	orig *originals
+}
+
+// NewChat 创建会话，task.Prompt 被忽略
+func (p *Pipeline) NewChat(task Task) (*Chat, error) {
+	task, err := p.normalize(task, false)
+	if err != nil {
+		return nil, err
+	}
+	task.Prompt = ""
+	system, err := p.render(ModeGen, prompt.RoleSystem, p.PromptData(task.Language, ""))
+	if err != nil {
+		return nil, err
+	}
+	sess := p.newSession(ModeChat, task)
+	if task, err = p.runDir(task, sess); err != nil {
+		return nil, err
+	}
+	if err := p.gitBegin(task, sess); err != nil {
+		return nil, err
+	}
+	return &Chat{
+		p:    p,
+		task: task,
+		conv: llm.NewConversation(system),
+		sess: sess,
+		orig: newOriginals(),
+	}, nil
+}
+
+// ResumeChat 恢复已保存的 chat 会话，并将最近一轮的文件恢复到工作目录
+func (p *Pipeline) ResumeChat(id string) (*Chat, error) {
+	sess, conv, task, err := p.loadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Mode != ModeChat {
+		return nil, errs.Errorf(errs.Usage, "resume chat", "session %s is a %s session", sess.ID, sess.Mode)
+	}
+	if err := p.gitBegin(task, sess); err != nil {
+		return nil, err
+	}
+	sess.Status = session.StatusRunning
+	return &Chat{p: p, task: task, conv: conv, sess: sess, orig: newOriginals()}, nil
+}
+
+// Task 返回会话使用的任务参数（已填充默认值）
+func (c *Chat) Task() Task {
+	return c.task
+}
+
+// SessionID 返回会话 ID，未配置 SessionStore 时为空
+func (c *Chat) SessionID() string {
+	return c.sess.ID
+}
+
+// Messages 返回当前对话历史
+func (c *Chat) Messages() []Message {
+	return append([]Message(nil), c.conv.Messages...)
+}
+
+// Conversation 返回当前对话的副本
+func (c *Chat) Conversation() *Conversation {
+	return c.conv.Clone()
+}
+
+// Turns 返回已完成的轮次
+func (c *Chat) Turns() []Attempt {
+	return append([]Attempt(nil), c.sess.Attempts...)
+}
+
+// Usage 返回会话累计的模型用量
+func (c *Chat) Usage() Usage {
+	return c.sess.Usage
+}
+
+// Files 返回当前工作目录中由会话生成的文件
+func (c *Chat) Files() []File {
+	if last := c.sess.LastAttempt(); last != nil {
+		return last.Files
+	}
+	return nil
+}
+
+// Send 发送一轮用户输入，用回复中的代码替换上一轮的文件并重新运行。
+// 模型调用失败时对话历史不变；提取或运行失败时本轮仍被记录，可用 Undo 撤销。
+func (c *Chat) Send(ctx context.Context, prompt string) (*GenerateResult, error) {
+	task := c.task
+	task.Prompt = prompt
+	res := &GenerateResult{Task: task, SessionID: c.sess.ID}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+	ctx = c.p.eventContext(ctx, c.sess.ID, len(c.sess.Attempts)+1)
+	if err := c.p.budget.Check(c.sess.Usage); err != nil {
+		return res, err
+	}
+	m := newMeter(c.p.budget, c.sess.Usage)
+	ctx = withMeter(ctx, m)
+	defer func() {
+		// 模型调用失败时本轮不被记录，其用量仍计入会话
+		_, u := m.settle()
+		c.sess.Usage.Merge(u)
+		res.Usage = c.sess.Usage
+	}()
+
+	conv := c.conv.Clone()
+	conv.AddUser(prompt)
+	if err := conv.Summarize(ctx, c.p.client(), task.Model, c.p.contextLimit); err != nil {
+		return res, budgetErr(errs.E(errs.LLM, "summarize conversation", err))
+	}
+	content, calls, err := c.p.complete(ctx, task, conv.Messages, c.p.schema(ModeChat))
+	if err != nil {
+		return res, budgetErr(err)
+	}
+	res.Response = content
+	conv.AddAssistant(content)
+	c.conv = conv
+
+	previous := c.Files()
+	turn := Attempt{Number: len(c.sess.Attempts) + 1, Prompt: prompt, Response: content, Files: previous, ToolCalls: calls, StartedAt: start}
+	defer func() {
+		turn.Duration = time.Since(start)
+		turn.Calls, turn.Usage = m.settle()
+		c.sess.Usage.Merge(turn.Usage)
+		c.p.emit(ctx, EventVerdict, turn.Verdict)
+		c.p.gitCommit(task, c.sess, &turn)
+		c.sess.Attempts = append(c.sess.Attempts, turn)
+		c.p.saveSession(c.sess, c.conv)
+		res.Attempts = c.Turns()
+	}()
+	if err := c.orig.remove(task.WorkDir, previous); err != nil {
+		return res, errs.E(errs.Other, "remove files", err)
+	}
+	files, err := c.p.writeFiles(ctx, task, c.sess, c.orig, content)
+	if err != nil {
+		// 恢复上一轮的文件
+		workspace.WriteFiles(task.WorkDir, previous)
+		turn.Verdict = c.p.watcher.Judge(ctx, "", err)
+		return res, err
+	}
+	res.Files, turn.Files = files, files
+	if err := c.p.scan(files); err != nil {
+		turn.Verdict = c.p.watcher.Judge(ctx, "", err)
+		return res, err
+	}
+	// 质量门禁可能自动格式化文件
+	files, err = c.p.lint(ctx, task, files)
+	res.Files, turn.Files = files, files
+	if err != nil {
+		turn.Verdict = c.p.watcher.Judge(ctx, "", err)
+		return res, err
+	}
+	err = c.p.reviewFiles(ctx, task, &turn, prompt, files)
+	res.Review = turn.Review
+	if err != nil {
+		turn.Verdict = c.p.watcher.Judge(ctx, "", err)
+		return res, err
+	}
+	res.Output, err = c.p.runFiles(ctx, task, files)
+	turn.Output = res.Output
+	turn.Verdict = c.p.watcher.Judge(ctx, res.Output, err)
+	return res, err
+}
+
+// Run 重新运行当前文件
+func (c *Chat) Run(ctx context.Context) (string, error) {
+	last := c.sess.LastAttempt()
+	if last == nil || len(last.Files) == 0 {
+		return "", errs.Errorf(errs.Usage, "run chat", "no files generated yet")
+	}
+	out, err := c.p.runFiles(ctx, c.task, last.Files)
+	last.Output = out
+	last.Verdict = c.p.watcher.Judge(ctx, out, err)
+	c.p.saveSession(c.sess, c.conv)
+	return out, err
+}
+
+// Diff 返回最近一轮相对上一轮的文件差异
+func (c *Chat) Diff() string {
+	n := len(c.sess.Attempts)
+	if n == 0 {
+		return ""
+	}
+	var before []File
+	if n > 1 {
+		before = c.sess.Attempts[n-2].Files
+	}
+	return DiffFiles(before, c.Files())
+}
+
+// Undo 撤销最近一轮：从对话历史中移除并恢复上一轮的文件，启用 git 时记录为会话分支上的新提交
+func (c *Chat) Undo() error {
+	if len(c.sess.Attempts) == 0 {
+		return errs.Errorf(errs.Usage, "undo chat", "nothing to undo")
+	}
+	current := c.Files()
+	n := len(c.sess.Attempts)
+	c.sess.Attempts = c.sess.Attempts[:n-1]
+	c.conv.Pop(2)
+	c.p.saveSession(c.sess, c.conv)
+	if err := c.orig.remove(c.task.WorkDir, current); err != nil {
+		return errs.E(errs.Other, "undo chat", err)
+	}
+	if err := workspace.WriteFiles(c.task.WorkDir, c.Files()); err != nil {
+		return errs.E(errs.Other, "undo chat", err)
+	}
+	c.p.gitCommitMessage(c.task, c.sess, fmt.Sprintf("aca %s: undo turn %d\n\nSession: %s\n", ModeChat, n, c.sess.ID), filePaths(c.Files())...)
+	return nil
+}
+
+// Save 将当前文件复制到 dir
+func (c *Chat) Save(dir string) error {
+	if len(c.Files()) == 0 {
+		return errs.Errorf(errs.Usage, "save chat", "no files generated yet")
+	}
+	return errs.E(errs.Other, "save chat", workspace.WriteFiles(dir, c.Files()))
+}
+
+// Close 结束会话并保存最终状态，启用 git 集成时切换回原来的分支
+func (c *Chat) Close() {
+	c.p.gitEnd(c.task, c.sess)
+	c.sess.Status = session.StatusPassed
+	if last := c.sess.LastAttempt(); last != nil && !last.Verdict.Passed {
+		c.sess.Status = session.StatusFailed
+	}
+	c.p.saveSession(c.sess, c.conv)
+}
@@ -0,0 +1,69 @@
+package aca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedProvider 依次返回预设回复，并记录每次请求的消息数
+type scriptedProvider struct {
+	replies    []string
+	msgCount   []int
+	lastPrompt string
+}
+
+func (s *scriptedProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	s.msgCount = append(s.msgCount, len(req.Messages))
+	s.lastPrompt = req.Messages[len(req.Messages)-1].Content
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return &Completion{Content: reply}, nil
+}
+
+func TestChatTurnsDiffAndUndo(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{
+		"```python\n# main.py\nprint(1)\n```",
+		"```python\n# main.py\nprint(2)\n```",
+	}}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+	workDir := t.TempDir()
+	chat, err := p.NewChat(Task{Language: "python", WorkDir: workDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := chat.Send(ctx, "print 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chat.Send(ctx, "now print 2"); err != nil {
+		t.Fatal(err)
+	}
+	// 第二轮应携带 system + user + assistant + user
+	if provider.msgCount[1] != 4 {
+		t.Errorf("expect 4 messages in second request, got %d", provider.msgCount[1])
+	}
+	d := chat.Diff()
+	if !strings.Contains(d, "-print(1)") || !strings.Contains(d, "+print(2)") {
+		t.Errorf("unexpected diff:\n%s", d)
+	}
+	if err := chat.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "main.py"))
+	if !strings.Contains(string(data), "print(1)") {
+		t.Errorf("undo did not restore previous file: %q", data)
+	}
+	if len(chat.Messages()) != 3 {
+		t.Errorf("expect 3 messages after undo, got %d", len(chat.Messages()))
+	}
+	saveDir := t.TempDir()
+	if err := chat.Save(saveDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(saveDir, "main.py")); err != nil {
+		t.Errorf("saved file missing: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package aca
+
+import (
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+)
+
+// Error 流水线返回的带类别错误
+type Error = errs.Error
+
+// ErrorKind 错误类别
+type ErrorKind = errs.Kind
+
+const (
+	ErrOther      = errs.Other
+	ErrUsage      = errs.Usage
+	ErrConfig     = errs.Config
+	ErrLLM        = errs.LLM
+	ErrExtract    = errs.Extract
+	ErrBuild      = errs.Build
+	ErrRuntime    = errs.Runtime
+	ErrTestFailed = errs.TestFailed
+	ErrPatch      = errs.Patch
+	ErrSecurity   = errs.Security
+	ErrLint       = errs.Lint
+	ErrReview     = errs.Review
+	ErrBudget     = errs.Budget
+)
+
+// 补全不可用的原因，见 CompletionError
+const (
+	NoChoices       = llm.NoChoices
+	Refused         = llm.Refused
+	Truncated       = llm.Truncated
+	ContentFiltered = llm.ContentFiltered
+)
+
+// KindOf 返回错误的类别，非流水线错误返回 ErrOther
+func KindOf(err error) ErrorKind {
+	return errs.KindOf(err)
+}
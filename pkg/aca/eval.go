@@ -0,0 +1,387 @@
+package aca
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+	"gopkg.in/yaml.v3"
+)
+
+// EvalSuite 评测套件：每个变体（模型 + 系统提示词）对每个题目独立生成 Samples 次，
+// 生成的代码用模型看不到的参考测试判定是否通过
+type EvalSuite struct {
+	Name        string        `yaml:"name" json:"name"`
+	Samples     int           `yaml:"samples" json:"samples"`           // 每个变体每题的样本数，默认 1
+	K           []int         `yaml:"k" json:"k"`                       // 计算 pass@k 的 k，默认 [1]，大于 Samples 的被忽略
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts"` // 每个样本的最大尝试次数，0 使用 Pipeline 的设置
+	Variants    []EvalVariant `yaml:"variants" json:"variants"`         // 为空时使用 Pipeline 的默认模型与提示词
+	Problems    []EvalProblem `yaml:"problems" json:"problems"`
+}
+
+// EvalVariant 参与对比的模型与提示词组合
+type EvalVariant struct {
+	Name   string `yaml:"name" json:"name"`
+	Model  string `yaml:"model" json:"model,omitempty"`   // 为空时使用 Pipeline 的默认模型
+	System string `yaml:"system" json:"system,omitempty"` // 非空时替换系统提示词
+}
+
+// EvalProblem 评测题目
+type EvalProblem struct {
+	Name     string `yaml:"name" json:"name"`
+	Language string `yaml:"language" json:"language,omitempty"`
+	Prompt   string `yaml:"prompt" json:"prompt"`
+	// Tests 隐藏的参考测试代码，生成结束后写入运行目录并在容器中执行
+	Tests string `yaml:"tests" json:"-"`
+	// TestsFile 从文件读取 Tests，相对套件文件所在目录
+	TestsFile string `yaml:"tests_file" json:"-"`
+	// TestFile 参考测试的文件名，默认为语言的测试文件名（如 main_test.go）
+	TestFile string `yaml:"test_file" json:"test_file,omitempty"`
+}
+
+// EvalSample 一次独立生成并运行参考测试的结果
+type EvalSample struct {
+	Passed    bool          `json:"passed"`
+	Attempts  int           `json:"attempts"`
+	Tokens    int           `json:"tokens"`
+	Cost      float64       `json:"cost,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	SessionID string        `json:"session_id,omitempty"`
+	WorkDir   string        `json:"workdir,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Output    string        `json:"output,omitempty"` // 参考测试未通过时的输出
+}
+
+// EvalProblemResult 一个变体在一个题目上的结果
+type EvalProblemResult struct {
+	Name     string          `json:"name"`
+	Language string          `json:"language"`
+	Passed   int             `json:"passed"`
+	PassAtK  map[int]float64 `json:"pass_at_k"`
+	Samples  []EvalSample    `json:"samples"`
+}
+
+// EvalVariantReport 一个变体在整个套件上的汇总
+type EvalVariantReport struct {
+	EvalVariant
+	PassAtK     map[int]float64     `json:"pass_at_k"` // 各题 pass@k 的平均值
+	Solved      int                 `json:"solved"`    // 至少一个样本通过的题目数
+	Samples     int                 `json:"samples"`
+	Passed      int                 `json:"passed"`
+	AvgAttempts float64             `json:"avg_attempts"` // 通过的样本平均需要的尝试次数
+	Tokens      int                 `json:"tokens"`
+	AvgTokens   float64             `json:"avg_tokens"`
+	Cost        float64             `json:"cost,omitempty"` // 按价格表估算的总费用（美元）
+	Duration    time.Duration       `json:"duration"`       // 各样本耗时之和
+	AvgDuration time.Duration       `json:"avg_duration"`
+	Problems    []EvalProblemResult `json:"problems"`
+}
+
+// EvalReport 评测报告
+type EvalReport struct {
+	Suite     string              `json:"suite"`
+	Samples   int                 `json:"samples"`
+	K         []int               `json:"k"`
+	Problems  int                 `json:"problems"`
+	Variants  []EvalVariantReport `json:"variants"`
+	StartedAt time.Time           `json:"started_at"`
+	Duration  time.Duration       `json:"duration"`
+}
+
+// LoadEvalSuite 读取 YAML 评测套件并校验
+func LoadEvalSuite(path string) (*EvalSuite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errs.E(errs.Usage, "load eval suite", err)
+	}
+	defer f.Close()
+	var suite EvalSuite
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&suite); err != nil {
+		return nil, errs.E(errs.Usage, "load eval suite", err)
+	}
+	for i := range suite.Problems {
+		prob := &suite.Problems[i]
+		if prob.TestsFile == "" {
+			continue
+		}
+		file := prob.TestsFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errs.E(errs.Usage, "load eval suite", err)
+		}
+		prob.Tests = string(data)
+	}
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &suite, suite.Validate()
+}
+
+// Validate 检查题目与变体的必填字段
+func (s *EvalSuite) Validate() error {
+	if len(s.Problems) == 0 {
+		return errs.Errorf(errs.Usage, "validate eval suite", "no problems")
+	}
+	names := make(map[string]bool)
+	for i, prob := range s.Problems {
+		switch {
+		case prob.Name == "":
+			return errs.Errorf(errs.Usage, "validate eval suite", "problem %d: name is required", i+1)
+		case names[prob.Name]:
+			return errs.Errorf(errs.Usage, "validate eval suite", "duplicate problem %q", prob.Name)
+		case strings.TrimSpace(prob.Prompt) == "":
+			return errs.Errorf(errs.Usage, "validate eval suite", "problem %q: prompt is required", prob.Name)
+		case strings.TrimSpace(prob.Tests) == "":
+			return errs.Errorf(errs.Usage, "validate eval suite", "problem %q: tests are required", prob.Name)
+		}
+		names[prob.Name] = true
+	}
+	names = make(map[string]bool)
+	for i, v := range s.Variants {
+		if v.Name == "" {
+			return errs.Errorf(errs.Usage, "validate eval suite", "variant %d: name is required", i+1)
+		}
+		if names[v.Name] {
+			return errs.Errorf(errs.Usage, "validate eval suite", "duplicate variant %q", v.Name)
+		}
+		names[v.Name] = true
+	}
+	for _, k := range s.K {
+		if k < 1 {
+			return errs.Errorf(errs.Usage, "validate eval suite", "invalid k %d", k)
+		}
+	}
+	return nil
+}
+
+// RunEval 以最多 parallel 个并发运行套件中的全部样本，每个样本使用 defaults 下独立的运行目录；
+// 每个样本结束时调用 onSample（不会并发调用）。ctx 取消后未开始的样本记为失败
+func (p *Pipeline) RunEval(ctx context.Context, defaults Task, suite *EvalSuite, parallel int, onSample func(variant, problem string, s EvalSample)) (*EvalReport, error) {
+	if err := suite.Validate(); err != nil {
+		return nil, err
+	}
+	n := max(suite.Samples, 1)
+	ks := []int{1}
+	if len(suite.K) > 0 {
+		ks = ks[:0]
+		for _, k := range suite.K {
+			if k <= n {
+				ks = append(ks, k)
+			}
+		}
+	}
+	variants := append([]EvalVariant(nil), suite.Variants...)
+	if len(variants) == 0 {
+		variants = []EvalVariant{{Name: "default"}}
+	}
+	report := &EvalReport{Suite: suite.Name, Samples: n, K: ks, Problems: len(suite.Problems), StartedAt: time.Now()}
+	results := make([][][]EvalSample, len(variants))
+	for i := range results {
+		results[i] = make([][]EvalSample, len(suite.Problems))
+		for j := range results[i] {
+			results[i][j] = make([]EvalSample, n)
+		}
+	}
+
+	sem := make(chan struct{}, max(parallel, 1))
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for i, v := range variants {
+		if v.Model == "" {
+			v.Model = p.model
+			variants[i].Model = p.model
+		}
+		for j, prob := range suite.Problems {
+			for s := range n {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var sample EvalSample
+					select {
+					case sem <- struct{}{}:
+						sample = p.evalSample(ctx, defaults, suite, v, prob)
+						<-sem
+					case <-ctx.Done():
+						sample = EvalSample{Error: ctx.Err().Error()}
+					}
+					results[i][j][s] = sample
+					if onSample != nil {
+						mu.Lock()
+						onSample(v.Name, prob.Name, sample)
+						mu.Unlock()
+					}
+				}()
+			}
+		}
+	}
+	wg.Wait()
+
+	for i, v := range variants {
+		vr := EvalVariantReport{EvalVariant: v, PassAtK: make(map[int]float64)}
+		attempts := 0
+		for j, prob := range suite.Problems {
+			pr := EvalProblemResult{Name: prob.Name, Language: prob.Language, PassAtK: make(map[int]float64), Samples: results[i][j]}
+			if pr.Language == "" {
+				pr.Language = DefaultLanguage
+			}
+			for _, s := range pr.Samples {
+				if s.Passed {
+					pr.Passed++
+					attempts += s.Attempts
+				}
+				vr.Tokens += s.Tokens
+				vr.Cost += s.Cost
+				vr.Duration += s.Duration
+			}
+			for _, k := range ks {
+				pr.PassAtK[k] = PassAtK(n, pr.Passed, k)
+				vr.PassAtK[k] += pr.PassAtK[k] / float64(len(suite.Problems))
+			}
+			if pr.Passed > 0 {
+				vr.Solved++
+			}
+			vr.Samples += n
+			vr.Passed += pr.Passed
+			vr.Problems = append(vr.Problems, pr)
+		}
+		if vr.Passed > 0 {
+			vr.AvgAttempts = float64(attempts) / float64(vr.Passed)
+		}
+		vr.AvgTokens = float64(vr.Tokens) / float64(vr.Samples)
+		vr.AvgDuration = vr.Duration / time.Duration(vr.Samples)
+		report.Variants = append(report.Variants, vr)
+	}
+	report.Duration = time.Since(report.StartedAt)
+	return report, ctx.Err()
+}
+
+// evalSample 在独立运行目录中生成代码，再写入参考测试并在容器中执行；
+// 只要生成阶段写出了文件就运行参考测试，以参考测试的结果判定是否通过
+func (p *Pipeline) evalSample(ctx context.Context, defaults Task, suite *EvalSuite, v EvalVariant, prob EvalProblem) EvalSample {
+	start := time.Now()
+	opts := []Option{WithRunDirs(true)}
+	if suite.MaxAttempts > 0 {
+		opts = append(opts, WithMaxAttempts(suite.MaxAttempts))
+	}
+	if v.System != "" {
+		opts = append(opts, WithSystemPrompt(v.System))
+	}
+	pl := p.With(opts...)
+	task := defaults
+	task.Prompt, task.Language, task.Model = prob.Prompt, prob.Language, v.Model
+	res, err := pl.Generate(ctx, task)
+	s := EvalSample{SessionID: res.SessionID, WorkDir: res.Task.WorkDir, Attempts: len(res.Attempts), Tokens: res.Usage.Total, Cost: res.Usage.Cost}
+	switch {
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	case len(res.Files) > 0:
+		var output string
+		output, err = pl.hiddenTest(ctx, res.Task, prob, res.Files)
+		s.Passed = err == nil
+		if !s.Passed {
+			s.Output = output
+		}
+	case err == nil:
+		err = errs.Errorf(errs.Other, "eval", "no files generated")
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.Duration = time.Since(start)
+	return s
+}
+
+// hiddenTest 将参考测试写入任务目录并在容器中执行；生成的文件中已有同名文件时不覆盖，直接判为失败
+func (p *Pipeline) hiddenTest(ctx context.Context, task Task, prob EvalProblem, files []File) (string, error) {
+	l, ok := p.languages.Get(task.Language)
+	if !ok {
+		return "", errs.Errorf(errs.Usage, "run hidden tests", "unsupported language: %s", task.Language)
+	}
+	name := prob.TestFile
+	if name == "" {
+		name = l.TestFile
+	}
+	for _, f := range files {
+		if filepath.Clean(filepath.FromSlash(f.Path)) == filepath.Clean(filepath.FromSlash(name)) {
+			return "", errs.Errorf(errs.Other, "run hidden tests", "generated file %s conflicts with the hidden tests", f.Path)
+		}
+	}
+	path, err := workspace.SafePath(task.WorkDir, name)
+	if err != nil {
+		return "", errs.Errorf(errs.Usage, "run hidden tests", "invalid test file %q: %v", name, err)
+	}
+	if err := os.WriteFile(path, []byte(prob.Tests), 0644); err != nil {
+		return "", errs.E(errs.Other, "write hidden tests", err)
+	}
+	p.logger.Info("用 Docker 执行参考测试 " + name + "...")
+	tester := &agent.Tester{Runtime: p.runtime, Languages: p.languages}
+	return tester.RunTest(ctx, task.Language, task.WorkDir, name, task.MountDir)
+}
+
+// PassAtK n 个样本中 c 个通过时 pass@k 的无偏估计 1 - C(n-c, k) / C(n, k)
+func PassAtK(n, c, k int) float64 {
+	if k > n || n <= 0 {
+		return 0
+	}
+	if n-c < k {
+		return 1
+	}
+	fail := 1.0
+	for i := n - c + 1; i <= n; i++ {
+		fail *= 1 - float64(k)/float64(i)
+	}
+	return 1 - fail
+}
+
+// Markdown 以 Markdown 表格输出各变体的对比与各题目的通过情况
+func (r *EvalReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 评测报告: %s\n\n", r.Suite)
+	fmt.Fprintf(&b, "%d 个题目，每个变体每题 %d 个样本，耗时 %.1fs\n\n", r.Problems, r.Samples, r.Duration.Seconds())
+	b.WriteString("| 变体 | 模型 |")
+	for _, k := range r.K {
+		fmt.Fprintf(&b, " pass@%d |", k)
+	}
+	b.WriteString(" 解决题目 | 平均尝试次数 | 平均 tokens | 总费用 | 平均耗时 |\n|---|---|")
+	b.WriteString(strings.Repeat("---:|", len(r.K)+5))
+	b.WriteString("\n")
+	for _, v := range r.Variants {
+		fmt.Fprintf(&b, "| %s | %s |", v.Name, v.Model)
+		for _, k := range r.K {
+			fmt.Fprintf(&b, " %.1f%% |", v.PassAtK[k]*100)
+		}
+		fmt.Fprintf(&b, " %d/%d | %.2f | %.0f | $%.4f | %.1fs |\n", v.Solved, r.Problems, v.AvgAttempts, v.AvgTokens, v.Cost, v.AvgDuration.Seconds())
+	}
+	if len(r.Variants) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n## 各题目通过的样本数\n\n| 题目 | 语言 |")
+	for _, v := range r.Variants {
+		fmt.Fprintf(&b, " %s |", v.Name)
+	}
+	b.WriteString("\n|---|---|")
+	b.WriteString(strings.Repeat("---:|", len(r.Variants)))
+	b.WriteString("\n")
+	for j, prob := range r.Variants[0].Problems {
+		fmt.Fprintf(&b, "| %s | %s |", prob.Name, prob.Language)
+		for _, v := range r.Variants {
+			fmt.Fprintf(&b, " %d/%d |", v.Problems[j].Passed, r.Samples)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
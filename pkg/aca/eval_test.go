@@ -0,0 +1,134 @@
+package aca
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// modelProvider 按请求的模型返回不同的代码
+type modelProvider struct {
+	replies map[string]string
+}
+
+func (f *modelProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	return &Completion{Content: f.replies[req.Model]}, nil
+}
+
+// evalRuntime 运行主文件总是成功，参考测试只有在实现正确时通过
+type evalRuntime struct{}
+
+func (evalRuntime) Run(ctx context.Context, spec RunSpec) (string, error) {
+	cmd := strings.Join(spec.Cmd, " ")
+	if !strings.Contains(cmd, "pytest test_main.py") {
+		return "", nil
+	}
+	if _, err := os.Stat(filepath.Join(spec.HostDir, "test_main.py")); err != nil {
+		return "no tests", &ExitError{Code: 4}
+	}
+	code, _ := os.ReadFile(filepath.Join(spec.HostDir, "main.py"))
+	if !strings.Contains(string(code), "a + b") {
+		return "1 failed", &ExitError{Code: 1}
+	}
+	return "1 passed", nil
+}
+
+func TestPassAtK(t *testing.T) {
+	for _, tc := range []struct {
+		n, c, k int
+		want    float64
+	}{
+		{5, 0, 1, 0},
+		{5, 5, 1, 1},
+		{5, 2, 1, 0.4},
+		{5, 2, 2, 0.7},
+		{5, 4, 2, 1},
+		{1, 1, 3, 0},
+	} {
+		if got := PassAtK(tc.n, tc.c, tc.k); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("PassAtK(%d, %d, %d) = %v, want %v", tc.n, tc.c, tc.k, got, tc.want)
+		}
+	}
+}
+
+func TestLoadEvalSuite(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "add_test.py"), []byte("def test_add(): pass\n"), 0644)
+	path := filepath.Join(dir, "basic.yaml")
+	os.WriteFile(path, []byte(`samples: 2
+problems:
+  - name: add
+    language: python
+    prompt: add two numbers
+    tests_file: add_test.py
+`), 0644)
+	suite, err := LoadEvalSuite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suite.Name != "basic" || suite.Problems[0].Tests != "def test_add(): pass\n" {
+		t.Errorf("unexpected suite %+v", suite)
+	}
+	os.WriteFile(path, []byte("problems:\n  - name: add\n    prompt: add\n"), 0644)
+	if _, err := LoadEvalSuite(path); KindOf(err) != ErrUsage {
+		t.Errorf("expect usage error for missing tests, got %v", err)
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	provider := &modelProvider{replies: map[string]string{
+		"good": "```python\ndef add(a, b):\n    return a + b\n```",
+		"bad":  "```python\ndef add(a, b):\n    return a - b\n```",
+	}}
+	p, _ := New(WithProvider(provider), WithRuntime(evalRuntime{}), WithLogger(DiscardLogger))
+	suite := &EvalSuite{
+		Name:     "basic",
+		Samples:  2,
+		K:        []int{1, 2, 5},
+		Variants: []EvalVariant{{Name: "good", Model: "good"}, {Name: "bad", Model: "bad"}},
+		Problems: []EvalProblem{{Name: "add", Language: "python", Prompt: "add two numbers", Tests: "from main import add\n"}},
+	}
+	samples := 0
+	report, err := p.RunEval(context.Background(), Task{WorkDir: t.TempDir()}, suite, 2, func(variant, problem string, s EvalSample) {
+		samples++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if samples != 4 || len(report.K) != 2 || len(report.Variants) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	good, bad := report.Variants[0], report.Variants[1]
+	if good.PassAtK[1] != 1 || good.Solved != 1 || good.AvgAttempts != 1 || good.Tokens == 0 {
+		t.Errorf("unexpected good variant %+v", good)
+	}
+	if bad.PassAtK[1] != 0 || bad.Passed != 0 || bad.Problems[0].Samples[0].Output != "1 failed" {
+		t.Errorf("unexpected bad variant %+v", bad)
+	}
+	md := report.Markdown()
+	for _, want := range []string{"| good | good | 100.0% | 100.0% | 1/1 |", "| add | python | 2/2 | 0/2 |"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestRunEvalHiddenTestFile(t *testing.T) {
+	provider := &modelProvider{replies: map[string]string{"": "```python\ndef add(a, b):\n    return a + b\n```"}}
+	p, _ := New(WithProvider(provider), WithRuntime(evalRuntime{}), WithLogger(DiscardLogger))
+	for name, want := range map[string]string{
+		"main.py":           "conflicts with the hidden tests",
+		"../test_main.py":   "invalid test file",
+		"/tmp/test_main.py": "invalid test file",
+	} {
+		suite := &EvalSuite{Problems: []EvalProblem{{Name: "add", Language: "python", Prompt: "add", Tests: "from main import add\n", TestFile: name}}}
+		report, _ := p.RunEval(context.Background(), Task{WorkDir: t.TempDir()}, suite, 1, nil)
+		s := report.Variants[0].Problems[0].Samples[0]
+		if s.Passed || !strings.Contains(s.Error, want) {
+			t.Errorf("test file %q: unexpected sample %+v", name, s)
+		}
+	}
+}
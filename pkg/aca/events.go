@@ -0,0 +1,232 @@
+package aca
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+)
+
+// 事件类型，括号中为 Event.Data 的类型
+const (
+	EventToken      = "token"       // 模型输出的增量文本（TokenEvent）
+	EventToolCall   = "tool_call"   // 模型调用了一个工具（ToolCallRecord）
+	EventFiles      = "files"       // 代码已写入工作目录（FilesEvent）
+	EventContainer  = "container"   // 容器已启动（ContainerEvent）
+	EventOutput     = "output"      // 容器输出的一行（OutputEvent）
+	EventTestResult = "test_result" // 测试或编译校验结束（TestResultEvent）
+	EventVerdict    = "verdict"     // Watcher 对一次尝试的判定（Verdict）
+	EventRetry      = "retry"       // 即将反馈错误并重试（RetryEvent）
+	EventDone       = "done"        // 会话结束（DoneEvent）
+	EventJob        = "job"         // JobQueue 中任务的状态变化（Job）
+)
+
+// DefaultEventHistory EventBus 默认保留的历史事件数
+const DefaultEventHistory = 10000
+
+// eventBuffer 每个订阅者在历史事件之外的缓冲大小
+const eventBuffer = 256
+
+// Event 流水线运行过程中的一个事件
+type Event struct {
+	Seq       int64     `json:"seq"` // 总线内从 1 开始递增的序号
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"` // 所属尝试的序号，0 表示不属于某次尝试
+	Data      any       `json:"data,omitempty"`
+}
+
+// TokenEvent 模型输出的增量文本
+type TokenEvent struct {
+	Text string `json:"text"`
+}
+
+// FilesEvent 写入工作目录的文件
+type FilesEvent struct {
+	Files []File `json:"files"`
+}
+
+// ContainerEvent 已启动的容器
+type ContainerEvent struct {
+	ID    string `json:"id"`
+	Image string `json:"image"`
+}
+
+// OutputEvent 容器输出的一行，Stream 为 stdout 或 stderr
+type OutputEvent struct {
+	Stream string `json:"stream"`
+	Line   string `json:"line"`
+}
+
+// TestResultEvent 测试或编译校验的结果
+type TestResultEvent struct {
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RetryEvent 尝试失败后即将开始的下一次尝试
+type RetryEvent struct {
+	Next   int    `json:"next"`
+	Reason string `json:"reason"`
+}
+
+// DoneEvent 会话的最终状态
+type DoneEvent struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Usage  Usage  `json:"usage,omitzero"` // 会话累计的模型用量
+}
+
+// EventBus 进程内事件总线，可并发使用。发布不会阻塞：订阅者的缓冲已满时丢弃发给它的事件，
+// 订阅者可通过 Seq 的间断发现丢失并重新订阅
+type EventBus struct {
+	mu      sync.Mutex
+	seq     int64
+	limit   int
+	history []Event
+	subs    map[chan Event]struct{}
+	closed  bool
+}
+
+// NewEventBus 创建最多保留 history 个历史事件的总线，history <= 0 时使用 DefaultEventHistory
+func NewEventBus(history int) *EventBus {
+	if history <= 0 {
+		history = DefaultEventHistory
+	}
+	return &EventBus{limit: history, subs: make(map[chan Event]struct{})}
+}
+
+// Publish 填充 Seq 与 Time 后发布事件，总线关闭后忽略
+func (b *EventBus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.seq++
+	e.Seq = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.history = append(b.history, e)
+	if len(b.history) > b.limit {
+		b.history = append(b.history[:0], b.history[len(b.history)-b.limit:]...)
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅 Seq 大于 after 的事件：先收到仍保留的历史事件，再收到新发布的事件。
+// 总线关闭后通道在送完已缓冲的事件后关闭；cancel 取消订阅并关闭通道
+func (b *EventBus) Subscribe(after int64) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var replay []Event
+	for _, e := range b.history {
+		if e.Seq > after {
+			replay = append(replay, e)
+		}
+	}
+	ch := make(chan Event, len(replay)+eventBuffer)
+	for _, e := range replay {
+		ch <- e
+	}
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close 关闭总线，结束全部订阅；历史事件仍可通过 Subscribe 读取
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+	}
+	b.subs = nil
+}
+
+// ContextRunTrace 返回 ctx 中的容器回调，没有时返回 nil；自定义 Runtime 调用它以发布容器启动与输出事件
+func ContextRunTrace(ctx context.Context) *RunTrace {
+	return container.ContextTrace(ctx)
+}
+
+// WithEvents 将运行过程中的事件发布到 bus：模型输出、写入的文件、容器启动与输出、测试结果、判定与重试
+func WithEvents(bus *EventBus) Option {
+	return func(pl *Pipeline) { pl.events = bus }
+}
+
+type eventScope struct {
+	sessionID string
+	attempt   int
+}
+
+type eventScopeKey struct{}
+
+// eventContext 返回标记了会话与尝试序号的 ctx，其中的容器启动与输出会作为事件发布；未配置 EventBus 时原样返回
+func (p *Pipeline) eventContext(ctx context.Context, sessionID string, attempt int) context.Context {
+	if p.events == nil {
+		return ctx
+	}
+	scoped := context.WithValue(ctx, eventScopeKey{}, eventScope{sessionID: sessionID, attempt: attempt})
+	return container.WithTrace(scoped, &container.Trace{
+		Started: func(id, image string) { p.emit(scoped, EventContainer, ContainerEvent{ID: id, Image: image}) },
+		Line:    func(stream, line string) { p.emit(scoped, EventOutput, OutputEvent{Stream: stream, Line: line}) },
+	})
+}
+
+// emit 发布事件，会话与尝试序号取自 eventContext 标记的 ctx
+func (p *Pipeline) emit(ctx context.Context, typ string, data any) {
+	if p.events == nil {
+		return
+	}
+	e := Event{Type: typ, Data: data}
+	if s, ok := ctx.Value(eventScopeKey{}).(eventScope); ok {
+		e.SessionID, e.Attempt = s.sessionID, s.attempt
+	}
+	p.events.Publish(e)
+}
+
+// onDelta 返回发布模型增量输出的回调，未配置 EventBus 时返回 nil 以使用非流式请求
+func (p *Pipeline) onDelta(ctx context.Context) func(string) {
+	if p.events == nil {
+		return nil
+	}
+	return func(delta string) { p.emit(ctx, EventToken, TokenEvent{Text: delta}) }
+}
+
+// emitCalls 发布工具调用事件
+func (p *Pipeline) emitCalls(ctx context.Context, calls []ToolCallRecord) {
+	for _, call := range calls {
+		p.emit(ctx, EventToolCall, call)
+	}
+}
+
+// emitResult 发布测试或编译校验的结果
+func (p *Pipeline) emitResult(ctx context.Context, err error) {
+	ev := TestResultEvent{Passed: err == nil}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	p.emit(ctx, EventTestResult, ev)
+}
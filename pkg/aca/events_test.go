@@ -0,0 +1,105 @@
+package aca
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// streamProvider 依次返回 replies，并按行回调增量输出
+type streamProvider struct {
+	replies []string
+}
+
+func (f *streamProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	content := f.replies[0]
+	f.replies = f.replies[1:]
+	if req.OnDelta != nil {
+		for _, line := range strings.SplitAfter(content, "\n") {
+			req.OnDelta(line)
+		}
+	}
+	return &Completion{Content: content}, nil
+}
+
+// traceRuntime 通过 RunTrace 报告容器启动与输出，依次返回 errs 中的错误
+type traceRuntime struct {
+	errs []error
+}
+
+func (f *traceRuntime) Run(ctx context.Context, spec RunSpec) (string, error) {
+	if t := ContextRunTrace(ctx); t != nil {
+		t.Started("c1", spec.Image)
+		t.Line("stdout", "hi")
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return "hi\n", err
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus(2)
+	for _, typ := range []string{"a", "b", "c"} {
+		bus.Publish(Event{Type: typ})
+	}
+	ch, cancel := bus.Subscribe(0)
+	bus.Publish(Event{Type: "d"})
+	var got []string
+	for _, want := range []int64{2, 3, 4} {
+		e := <-ch
+		if e.Seq != want {
+			t.Errorf("expect seq %d, got %d", want, e.Seq)
+		}
+		got = append(got, e.Type)
+	}
+	if strings.Join(got, "") != "bcd" {
+		t.Errorf("unexpected events %v", got)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after cancel")
+	}
+
+	ch, _ = bus.Subscribe(3)
+	bus.Close()
+	bus.Publish(Event{Type: "e"})
+	var rest []string
+	for e := range ch {
+		rest = append(rest, e.Type)
+	}
+	if strings.Join(rest, "") != "d" {
+		t.Errorf("unexpected events after close %v", rest)
+	}
+}
+
+func TestGenerateEvents(t *testing.T) {
+	bus := NewEventBus(0)
+	ch, _ := bus.Subscribe(0)
+	p, _ := New(
+		WithProvider(&streamProvider{replies: []string{"```python\nprint('hi')\n```", "```python\nprint('hi')\n```"}}),
+		WithRuntime(&traceRuntime{errs: []error{errors.New("boom"), nil}}),
+		WithLogger(DiscardLogger),
+		WithMaxAttempts(2),
+		WithEvents(bus),
+	)
+	if _, err := p.Generate(context.Background(), Task{Prompt: "say hi", Language: "python", WorkDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	var types []string
+	var last Event
+	for e := range ch {
+		if len(types) == 0 || types[len(types)-1] != e.Type {
+			types = append(types, e.Type)
+		}
+		last = e
+	}
+	want := "token files container output verdict retry token files container output verdict done"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("unexpected events:\n got %s\nwant %s", got, want)
+	}
+	if done, ok := last.Data.(DoneEvent); !ok || done.Status != "passed" {
+		t.Errorf("unexpected done event %+v", last)
+	}
+}
@@ -0,0 +1,209 @@
+package aca
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/vcs"
+)
+
+// GitBranchPrefix 会话分支名前缀
+const GitBranchPrefix = "aca/"
+
+// WithGit 启用 git 集成：在 WorkDir 所在的工作区中为每个会话创建 aca/<id> 分支，
+// 每次尝试提交到该分支，会话结束后切换回原来的分支；WorkDir 不在工作区中时初始化仓库。只使用本地 git。
+func WithGit(enabled bool) Option {
+	return func(pl *Pipeline) { pl.git = enabled }
+}
+
+// gitBegin 为会话准备分支：已有分支的会话（恢复）切换回该分支，否则在启用 git 时创建新分支。
+// 切换分支会带走未提交的修改，会话结束切换回原分支后这些修改将丢失，因此工作区不干净时拒绝开始
+func (p *Pipeline) gitBegin(task Task, sess *Session) error {
+	if sess.Git != nil {
+		repo, err := vcs.Open(task.WorkDir)
+		if err != nil {
+			return errs.E(errs.Other, "open git repo", err)
+		}
+		if err := checkClean(repo); err != nil {
+			return err
+		}
+		return errs.E(errs.Other, "checkout session branch", repo.Checkout(sess.Git.Branch))
+	}
+	if !p.git {
+		return nil
+	}
+	repo, err := vcs.Init(task.WorkDir)
+	if err != nil {
+		return errs.E(errs.Other, "init git repo", err)
+	}
+	base, err := repo.EnsureHead()
+	if err != nil {
+		return errs.E(errs.Other, "init git repo", err)
+	}
+	if err := checkClean(repo); err != nil {
+		return err
+	}
+	id := sess.ID
+	if id == "" {
+		id = session.NewID()
+	}
+	g := &session.Git{Branch: GitBranchPrefix + id, Base: base, Origin: repo.Branch()}
+	if err := repo.CreateBranch(g.Branch); err != nil {
+		return errs.E(errs.Other, "create session branch", err)
+	}
+	sess.Git = g
+	p.logger.Info("git 分支:", g.Branch)
+	p.saveSession(sess, nil)
+	return nil
+}
+
+// checkClean 工作目录中有未提交的修改时返回 Usage 错误
+func checkClean(repo *vcs.Repo) error {
+	dirty, err := repo.Dirty()
+	if err != nil {
+		return errs.E(errs.Other, "check git status", err)
+	}
+	if len(dirty) > 0 {
+		return errs.Errorf(errs.Usage, "check git status", "work tree has uncommitted changes (%s), commit or stash them first", strings.Join(dirty, ", "))
+	}
+	return nil
+}
+
+// gitEnd 会话结束后切换回创建会话分支前所在的分支，生成的文件保留在会话分支上；
+// 原来处于分离 HEAD 时留在会话分支，切换失败只记录警告
+func (p *Pipeline) gitEnd(task Task, sess *Session) {
+	if sess.Git == nil || sess.Git.Origin == "" {
+		return
+	}
+	repo, err := vcs.Open(task.WorkDir)
+	if err == nil {
+		err = repo.Checkout(sess.Git.Origin)
+	}
+	if err != nil {
+		p.logger.Warning("切换回分支", sess.Git.Origin, "失败:", err)
+	}
+}
+
+// gitCommit 将一次尝试写入的文件提交到会话分支；提交失败只记录警告
+func (p *Pipeline) gitCommit(task Task, sess *Session, a *Attempt) {
+	a.Commit = p.gitCommitMessage(task, sess, commitMessage(sess, a), filePaths(a.Files)...)
+}
+
+func filePaths(files []File) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+// gitCommitMessage 以 message 提交 WorkDir 中已跟踪文件的修改与 paths 中的新文件，
+// 返回提交哈希，未启用 git 或失败时返回空串
+func (p *Pipeline) gitCommitMessage(task Task, sess *Session, message string, paths ...string) string {
+	if sess.Git == nil {
+		return ""
+	}
+	repo, err := vcs.Open(task.WorkDir)
+	if err == nil {
+		var commit string
+		if commit, err = repo.Commit(message, paths...); err == nil {
+			return commit
+		}
+	}
+	p.logger.Warning("git 提交失败:", err)
+	return ""
+}
+
+// commitMessage 生成尝试的提交信息：标题为模式、序号与提示词，正文包含完整提示词与 Watcher 判定
+func commitMessage(sess *Session, a *Attempt) string {
+	prompt := a.Prompt
+	if sess.Mode != ModeChat {
+		prompt = sess.Prompt
+	}
+	title := strings.Join(strings.Fields(prompt), " ")
+	if r := []rune(title); len(r) > 60 {
+		title = string(r[:59]) + "…"
+	}
+	verdict := "passed"
+	if !a.Verdict.Passed {
+		verdict = fmt.Sprintf("failed (%s): %s", a.Verdict.Kind, a.Verdict.Reason)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "aca %s #%d: %s\n\n", sess.Mode, a.Number, title)
+	fmt.Fprintf(&sb, "Prompt: %s\nVerdict: %s\n", prompt, verdict)
+	if sess.ID != "" {
+		fmt.Fprintf(&sb, "Session: %s\n", sess.ID)
+	}
+	return sb.String()
+}
+
+// loadGitSession 读取启用了 git 集成的会话并打开其工作区
+func loadGitSession(store *SessionStore, id, op string) (*Session, *vcs.Repo, error) {
+	sess, err := store.Load(id)
+	if err != nil {
+		return nil, nil, errs.E(errs.Usage, op, err)
+	}
+	if sess.Git == nil {
+		return nil, nil, errs.Errorf(errs.Usage, op, "session %s was not run with git enabled", sess.ID)
+	}
+	repo, err := vcs.Open(sess.WorkDir)
+	if err != nil {
+		return nil, nil, errs.E(errs.Other, op, err)
+	}
+	return sess, repo, nil
+}
+
+// UndoSession 将会话工作目录恢复为第 n 次尝试后的内容（n 为 0 时恢复到会话开始前），
+// 并作为新提交记录在会话分支上，返回提交哈希
+func UndoSession(store *SessionStore, id string, n int) (string, error) {
+	sess, repo, err := loadGitSession(store, id, "undo session")
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > len(sess.Attempts) {
+		return "", errs.Errorf(errs.Usage, "undo session", "session %s has %d attempts", sess.ID, len(sess.Attempts))
+	}
+	rev := sess.Git.Base
+	if n > 0 {
+		rev = sess.Attempts[n-1].Commit
+	}
+	if rev == "" {
+		return "", errs.Errorf(errs.Usage, "undo session", "attempt %d has no commit", n)
+	}
+	if err := repo.Checkout(sess.Git.Branch); err != nil {
+		return "", errs.E(errs.Other, "undo session", err)
+	}
+	if err := repo.Restore(rev); err != nil {
+		return "", errs.E(errs.Other, "undo session", err)
+	}
+	commit, err := repo.Commit(fmt.Sprintf("aca %s: undo to attempt %d\n\nSession: %s\n", sess.Mode, n, sess.ID))
+	return commit, errs.E(errs.Other, "undo session", err)
+}
+
+// SquashSession 将会话分支上的全部提交合并为一个提交，message 为空时使用会话提示词，返回提交哈希
+func SquashSession(store *SessionStore, id, message string) (string, error) {
+	sess, repo, err := loadGitSession(store, id, "squash session")
+	if err != nil {
+		return "", err
+	}
+	if message == "" {
+		message = fmt.Sprintf("%s\n\nSession: %s\n", sess.Prompt, sess.ID)
+		if strings.TrimSpace(sess.Prompt) == "" {
+			message = fmt.Sprintf("aca %s session\n\nSession: %s\n", sess.Mode, sess.ID)
+		}
+	}
+	commit, err := repo.Squash(sess.Git.Branch, sess.Git.Base, message)
+	return commit, errs.E(errs.Other, "squash session", err)
+}
+
+// FormatSessionPatch 将会话分支上的提交导出为 outDir 中的 git format-patch 补丁，返回文件路径
+func FormatSessionPatch(store *SessionStore, id, outDir string) ([]string, error) {
+	sess, repo, err := loadGitSession(store, id, "format patch")
+	if err != nil {
+		return nil, err
+	}
+	files, err := repo.FormatPatch(sess.Git.Branch, sess.Git.Base, outDir)
+	return files, errs.E(errs.Other, "format patch", err)
+}
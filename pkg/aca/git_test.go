@@ -0,0 +1,88 @@
+package aca
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCommitsEachAttempt(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workDir := t.TempDir()
+	store := NewSessionStore(t.TempDir())
+	provider := &scriptedProvider{replies: []string{"```python\nprint(\n```", "```python\nprint(1)\n```"}}
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 1}), WithLogger(DiscardLogger),
+		WithMaxAttempts(2), WithSessionStore(store), WithGit(true))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: workDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := store.Load(res.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Git == nil || sess.Git.Branch != GitBranchPrefix+sess.ID || sess.Attempts[0].Commit == "" || sess.Attempts[1].Commit == "" {
+		t.Fatalf("expect a commit per attempt, got %+v %+v", sess.Git, sess.Attempts)
+	}
+	msg, _ := exec.Command("git", "-C", workDir, "log", "-1", "--format=%B", sess.Attempts[0].Commit).Output()
+	if !strings.Contains(string(msg), "print 1") || !strings.Contains(string(msg), "failed (build)") {
+		t.Errorf("unexpected commit message %q", msg)
+	}
+
+	// 会话结束后回到原来的分支，生成的文件只在会话分支上
+	branch, _ := exec.Command("git", "-C", workDir, "symbolic-ref", "--short", "HEAD").Output()
+	if sess.Git.Origin == "" || strings.TrimSpace(string(branch)) != sess.Git.Origin {
+		t.Errorf("expect to be back on %q, got %q", sess.Git.Origin, branch)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "main.py")); !os.IsNotExist(err) {
+		t.Errorf("generated file should stay on the session branch, stat: %v", err)
+	}
+
+	if _, err := UndoSession(store, sess.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "main.py"))
+	if strings.TrimSpace(string(data)) != "print(" {
+		t.Errorf("undo should restore the first attempt, got %q", data)
+	}
+	if _, err := SquashSession(store, sess.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	files, err := FormatSessionPatch(store, sess.ID, t.TempDir())
+	if err != nil || len(files) != 1 {
+		t.Errorf("expect one patch after squash, got %v %v", files, err)
+	}
+}
+
+func TestGitRefusesDirtyWorkTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workDir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", workDir, "-c", "user.name=t", "-c", "user.email=t@localhost"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(workDir, "main.py"), []byte("print(1)\n"), 0644)
+	git("add", "main.py")
+	git("commit", "-q", "-m", "init")
+	os.WriteFile(filepath.Join(workDir, "main.py"), []byte("print(1)\n# wip\n"), 0644)
+
+	provider := &scriptedProvider{replies: []string{"```python\nprint(2)\n```"}}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithGit(true))
+	_, err := p.Generate(context.Background(), Task{Prompt: "print 2", Language: "python", WorkDir: workDir})
+	if KindOf(err) != ErrUsage || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("expect usage error for dirty work tree, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "main.py")); string(data) != "print(1)\n# wip\n" {
+		t.Errorf("uncommitted changes were lost: %q", data)
+	}
+}
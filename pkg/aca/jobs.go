@@ -0,0 +1,382 @@
+package aca
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+)
+
+// 任务状态
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobPassed   = "passed"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// ErrQueueFull 任务队列已满
+var ErrQueueFull = errors.New("job queue is full")
+
+// maxFinishedJobs JobQueue 保留的已结束任务数，超出时丢弃最早的
+const maxFinishedJobs = 1000
+
+// JobRequest 提交到 JobQueue 的任务
+type JobRequest struct {
+	Mode     string     `json:"mode,omitempty"` // gen/test/agent，默认 gen
+	Prompt   string     `json:"prompt"`
+	Language string     `json:"language,omitempty"`
+	Model    string     `json:"model,omitempty"`
+	Options  JobOptions `json:"options,omitempty"`
+}
+
+// JobOptions 覆盖 Pipeline 设置的单任务选项，未设置的字段沿用 Pipeline 的配置
+type JobOptions struct {
+	MaxAttempts int   `json:"max_attempts,omitempty"`
+	Review      *bool `json:"review,omitempty"`
+	Lint        *bool `json:"lint,omitempty"`
+	Structured  *bool `json:"structured,omitempty"`
+}
+
+// Job 任务的状态与结果
+type Job struct {
+	ID         string     `json:"id"`
+	Request    JobRequest `json:"request"`
+	Status     string     `json:"status"`
+	SessionID  string     `json:"session_id,omitempty"`
+	WorkDir    string     `json:"workdir,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	ErrorKind  string     `json:"error_kind,omitempty"`
+	Files      []string   `json:"files,omitempty"` // 最后一次尝试的文件，内容通过 JobQueue.Files 获取
+	Output     string     `json:"output,omitempty"`
+	Attempts   int        `json:"attempts"`
+	Tokens     int        `json:"tokens,omitempty"` // 全部模型请求消耗的 token 数，服务未返回用量时为估算值
+	Cost       float64    `json:"cost,omitempty"`   // 按价格表估算的费用（美元）
+	Review     *Review    `json:"review,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished 任务是否已结束
+func (j Job) Finished() bool {
+	return j.Status == JobPassed || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+type jobEntry struct {
+	job    Job
+	files  []File
+	events *EventBus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// publish 发布任务的当前状态，任务结束时关闭事件总线；调用方需持有 JobQueue.mu
+func (e *jobEntry) publish() {
+	e.events.Publish(Event{Type: EventJob, SessionID: e.job.SessionID, Data: e.job})
+	if e.job.Finished() {
+		e.events.Close()
+	}
+}
+
+// JobQueue 有界的进程内任务队列，由固定数量的 worker 通过 Pipeline 执行任务，可并发使用
+type JobQueue struct {
+	p        *Pipeline
+	defaults Task
+	queue    chan *jobEntry
+	ctx      context.Context
+	stop     context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	jobs  map[string]*jobEntry
+	order []string // 提交顺序
+}
+
+// NewJobQueue 创建队列并启动 workers 个 worker，最多排队 size 个任务；
+// defaults 提供任务的 WorkDir、MountDir 等未在 JobRequest 中指定的设置。
+// 未启用独立运行目录或启用了 git 集成时任务会互相覆盖文件、切换分支，因此逐个执行
+func NewJobQueue(p *Pipeline, defaults Task, workers, size int) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 1 {
+		size = 1
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	q := &JobQueue{p: p, defaults: defaults, queue: make(chan *jobEntry, size), ctx: ctx, stop: stop, jobs: make(map[string]*jobEntry)}
+	for range workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Submit 校验并排队任务，队列已满时返回 ErrQueueFull
+func (q *JobQueue) Submit(req JobRequest) (Job, error) {
+	if req.Mode == "" {
+		req.Mode = ModeGen
+	}
+	if req.Mode != ModeGen && req.Mode != ModeTest && req.Mode != ModeAgent {
+		return Job{}, errs.Errorf(errs.Usage, "submit job", "unsupported mode %q, want gen, test or agent", req.Mode)
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return Job{}, errs.Errorf(errs.Usage, "submit job", "prompt is required")
+	}
+	if req.Language != "" {
+		if _, ok := q.p.languages.Get(req.Language); !ok {
+			return Job{}, errs.Errorf(errs.Usage, "submit job", "unsupported language: %s", req.Language)
+		}
+	}
+	e := &jobEntry{
+		job:    Job{ID: session.NewID(), Request: req, Status: JobQueued, CreatedAt: time.Now()},
+		events: NewEventBus(0),
+		done:   make(chan struct{}),
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ctx.Err() != nil {
+		return Job{}, errs.Errorf(errs.Usage, "submit job", "job queue is closed")
+	}
+	select {
+	case q.queue <- e:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[e.job.ID] = e
+	q.order = append(q.order, e.job.ID)
+	q.trim()
+	e.publish()
+	return e.job, nil
+}
+
+// trim 丢弃最早的已结束任务，调用方需持有 mu
+func (q *JobQueue) trim() {
+	finished := 0
+	for _, id := range q.order {
+		if q.jobs[id].job.Finished() {
+			finished++
+		}
+	}
+	for i := 0; finished > maxFinishedJobs && i < len(q.order); {
+		id := q.order[i]
+		if !q.jobs[id].job.Finished() {
+			i++
+			continue
+		}
+		delete(q.jobs, id)
+		q.order = append(q.order[:i], q.order[i+1:]...)
+		finished--
+	}
+}
+
+// Get 返回任务的当前状态
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return e.job, true
+}
+
+// List 按提交顺序返回全部任务
+func (q *JobQueue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.order))
+	for _, id := range q.order {
+		jobs = append(jobs, q.jobs[id].job)
+	}
+	return jobs
+}
+
+// Files 返回任务最后一次尝试生成的文件
+func (q *JobQueue) Files(id string) ([]File, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return e.files, true
+}
+
+// Events 返回任务的事件总线，包含运行过程中的事件与状态变化，任务结束后关闭但仍可重放历史事件
+func (q *JobQueue) Events(id string) (*EventBus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return e.events, true
+}
+
+// Cancel 取消任务：排队中的任务直接标记为已取消，运行中的任务中断后由 worker 标记
+func (q *JobQueue) Cancel(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	switch e.job.Status {
+	case JobQueued:
+		now := time.Now()
+		e.job.Status, e.job.FinishedAt = JobCanceled, &now
+		e.publish()
+		close(e.done)
+	case JobRunning:
+		e.cancel()
+	}
+	return e.job, true
+}
+
+// Wait 等待任务结束并返回其最终状态
+func (q *JobQueue) Wait(ctx context.Context, id string) (Job, error) {
+	q.mu.Lock()
+	e, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return Job{}, errs.Errorf(errs.Usage, "wait job", "job %s not found", id)
+	}
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+	// 任务可能已被 trim 移出队列，直接读取条目
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return e.job, nil
+}
+
+// Close 取消全部任务并等待 worker 退出
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	q.stop()
+	q.mu.Unlock()
+	q.wg.Wait()
+	for _, job := range q.List() {
+		q.Cancel(job.ID)
+	}
+}
+
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case e := <-q.queue:
+			q.run(e)
+		}
+	}
+}
+
+// run 执行一个任务，已在排队时被取消的任务直接跳过
+func (q *JobQueue) run(e *jobEntry) {
+	release, aerr := q.p.acquire(q.ctx)
+	if aerr != nil {
+		// 队列已关闭，由 Close 取消仍在排队的任务
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(q.ctx)
+	defer cancel()
+	q.mu.Lock()
+	if e.job.Status != JobQueued {
+		q.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	e.job.Status, e.job.StartedAt, e.cancel = JobRunning, &now, cancel
+	e.publish()
+	q.mu.Unlock()
+
+	req := e.job.Request
+	task := q.defaults
+	task.Prompt = req.Prompt
+	if req.Language != "" {
+		task.Language = req.Language
+	}
+	if req.Model != "" {
+		task.Model = req.Model
+	}
+	p := q.p.With(append(q.jobOptions(req.Options), WithEvents(e.events))...)
+	var (
+		res   GenerateResult
+		files []File
+		err   error
+	)
+	switch req.Mode {
+	case ModeTest:
+		var tr *TestResult
+		tr, err = p.Test(ctx, task)
+		res = GenerateResult{Task: tr.Task, SessionID: tr.SessionID, Output: tr.Output, Review: tr.Review, Attempts: tr.Attempts, Usage: tr.Usage}
+		if tr.TestFile.Path != "" {
+			files = []File{tr.TestFile}
+		}
+	case ModeAgent:
+		var gr *GenerateResult
+		gr, err = p.Agent(ctx, task)
+		res, files = *gr, gr.Files
+	default:
+		var gr *GenerateResult
+		gr, err = p.Generate(ctx, task)
+		res, files = *gr, gr.Files
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	finished := time.Now()
+	j := &e.job
+	j.SessionID, j.WorkDir, j.Output, j.Review, j.Attempts = res.SessionID, res.Task.WorkDir, res.Output, res.Review, len(res.Attempts)
+	j.Tokens, j.Cost = res.Usage.Total, res.Usage.Cost
+	j.FinishedAt = &finished
+	j.Files = nil
+	for _, f := range files {
+		j.Files = append(j.Files, f.Path)
+	}
+	e.files = files
+	switch {
+	case err == nil:
+		j.Status = JobPassed
+	case ctx.Err() != nil:
+		j.Status, j.Error = JobCanceled, err.Error()
+	default:
+		j.Status, j.Error, j.ErrorKind = JobFailed, err.Error(), KindOf(err).String()
+	}
+	e.publish()
+	close(e.done)
+}
+
+// jobOptions 将单任务选项转换为 Pipeline 选项
+func (q *JobQueue) jobOptions(o JobOptions) []Option {
+	var opts []Option
+	if o.MaxAttempts > 0 {
+		opts = append(opts, WithMaxAttempts(o.MaxAttempts))
+	}
+	if o.Review != nil {
+		review := q.p.review
+		review.Enabled = *o.Review
+		opts = append(opts, WithReview(review))
+	}
+	if o.Lint != nil {
+		gate := q.p.quality
+		gate.Enabled = *o.Lint
+		gate.Feedback = gate.Feedback || gate.Enabled
+		opts = append(opts, WithQualityGate(gate))
+	}
+	if o.Structured != nil {
+		opts = append(opts, WithStructuredOutput(*o.Structured))
+	}
+	return opts
+}
@@ -0,0 +1,116 @@
+package aca
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingProvider 阻塞到 ctx 取消或 release 关闭
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+	content string
+}
+
+func (f *blockingProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	f.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-f.release:
+		return &Completion{Content: f.content}, nil
+	}
+}
+
+func TestJobQueue(t *testing.T) {
+	p, _ := New(WithProvider(&fakeProvider{content: "```python\nprint('hi')\n```"}), WithRuntime(&fakeRuntime{output: "hi\n"}), WithLogger(DiscardLogger))
+	q := NewJobQueue(p, Task{WorkDir: t.TempDir()}, 2, 4)
+	defer q.Close()
+	if _, err := q.Submit(JobRequest{Mode: "patch", Prompt: "x"}); KindOf(err) != ErrUsage {
+		t.Errorf("expect usage error for patch mode, got %v", err)
+	}
+	if _, err := q.Submit(JobRequest{Prompt: " "}); KindOf(err) != ErrUsage {
+		t.Errorf("expect usage error for empty prompt, got %v", err)
+	}
+	job, err := q.Submit(JobRequest{Prompt: "say hi", Language: "python", Options: JobOptions{MaxAttempts: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err = q.Wait(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobPassed || job.Output != "hi\n" || job.Attempts != 1 || len(job.Files) != 1 || job.StartedAt == nil {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if files, _ := q.Files(job.ID); len(files) != 1 || files[0].Content != "print('hi')" {
+		t.Errorf("unexpected files %+v", files)
+	}
+	job, _ = q.Submit(JobRequest{Mode: ModeTest, Prompt: "x", Language: "cobol"})
+	if job.ID != "" {
+		t.Errorf("expect unsupported language to be rejected")
+	}
+}
+
+func TestJobQueueCancel(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}, 4), release: make(chan struct{})}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+	q := NewJobQueue(p, Task{WorkDir: t.TempDir()}, 1, 1)
+	defer q.Close()
+
+	running, _ := q.Submit(JobRequest{Prompt: "a", Language: "python"})
+	<-provider.started
+	queued, err := q.Submit(JobRequest{Prompt: "b", Language: "python"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Submit(JobRequest{Prompt: "c", Language: "python"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expect queue full, got %v", err)
+	}
+	// 排队中的任务立即取消
+	if job, _ := q.Cancel(queued.ID); job.Status != JobCanceled {
+		t.Errorf("expect queued job canceled, got %s", job.Status)
+	}
+	q.Cancel(running.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := q.Wait(ctx, running.ID)
+	if err != nil || job.Status != JobCanceled || job.Error == "" {
+		t.Errorf("expect running job canceled, got %+v (%v)", job, err)
+	}
+	if jobs := q.List(); len(jobs) != 2 || jobs[0].ID != running.ID {
+		t.Errorf("unexpected job list %+v", jobs)
+	}
+}
+
+func TestJobQueueSerial(t *testing.T) {
+	for _, runDirs := range []bool{false, true} {
+		provider := &blockingProvider{started: make(chan struct{}, 4), release: make(chan struct{}), content: "```python\nprint('hi')\n```"}
+		p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithRunDirs(runDirs))
+		q := NewJobQueue(p, Task{WorkDir: t.TempDir()}, 2, 4)
+		a, _ := q.Submit(JobRequest{Prompt: "a", Language: "python"})
+		b, _ := q.Submit(JobRequest{Prompt: "b", Language: "python"})
+		<-provider.started
+		// 共用工作目录时第二个任务要等第一个结束
+		select {
+		case <-provider.started:
+			if !runDirs {
+				t.Error("expect jobs sharing the work dir to run one at a time")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if runDirs {
+				t.Error("expect jobs with separate run dirs to run concurrently")
+			}
+		}
+		close(provider.release)
+		// 共用工作目录时第二个任务拒绝覆盖第一个任务写入的文件
+		for _, id := range []string{a.ID, b.ID} {
+			if job, err := q.Wait(context.Background(), id); err != nil || runDirs && job.Status != JobPassed {
+				t.Errorf("runDirs=%v: unexpected job %+v (%v)", runDirs, job, err)
+			}
+		}
+		q.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package aca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lint"
+)
+
+// WithQualityGate 设置运行前的质量门禁：在语言容器中自动修复格式（gofmt/goimports）并运行 go vet 等检查，
+// gate.Feedback 为 true 时把剩余问题作为审查意见反馈给模型重写
+func WithQualityGate(gate QualityGate) Option {
+	return func(pl *Pipeline) { pl.quality = gate }
+}
+
+// lint 对本次写入的文件运行质量门禁，返回格式化后的文件；未启用时原样返回
+func (p *Pipeline) lint(ctx context.Context, task Task, files []File) ([]File, error) {
+	if !p.quality.Enabled || len(files) == 0 {
+		return files, nil
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	p.logger.Info("用 Docker 检查代码质量...")
+	linter := &agent.Linter{Runtime: p.runtime, Languages: p.languages, Linters: p.quality.Linters}
+	report, err := linter.Lint(ctx, task.Language, task.WorkDir, paths, task.MountDir)
+	if err != nil {
+		return files, err
+	}
+	if len(report.Formatted) > 0 {
+		p.logger.Info("已自动格式化:", report.Formatted)
+		files = append([]File(nil), files...)
+		for i, f := range files {
+			if data, err := os.ReadFile(filepath.Join(task.WorkDir, f.Path)); err == nil {
+				files[i].Content = string(data)
+			}
+		}
+	}
+	for _, issue := range report.Issues {
+		p.logger.Warning("代码审查:", issue)
+	}
+	if p.quality.Feedback && len(report.Issues) > 0 {
+		return files, errs.E(errs.Lint, "quality gate", &lint.Error{Issues: report.Issues})
+	}
+	return files, nil
+}
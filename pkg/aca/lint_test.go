@@ -0,0 +1,59 @@
+package aca
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// lintRuntime 质量门禁命令返回 lintOutput，其余命令成功
+type lintRuntime struct {
+	lintOutput []string
+	lints      int
+	runs       int
+}
+
+func (l *lintRuntime) Run(ctx context.Context, spec RunSpec) (string, error) {
+	if strings.Contains(strings.Join(spec.Cmd, " "), "::tool") {
+		out := l.lintOutput[l.lints]
+		l.lints++
+		return out, nil
+	}
+	l.runs++
+	return "ok", nil
+}
+
+func TestQualityGateFeedback(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"```python\nimport os\nprint(1)\n```", "```python\nprint(1)\n```"}}
+	rt := &lintRuntime{lintOutput: []string{
+		"::tool ruff-format\n::tool ruff\nmain.py:1:8: F401 [*] `os` imported but unused\n",
+		"::tool ruff-format\nmain.py\n::tool ruff\n",
+	}}
+	p, _ := New(WithProvider(provider), WithRuntime(rt), WithLogger(DiscardLogger), WithMaxAttempts(3),
+		WithQualityGate(QualityGate{Enabled: true, Feedback: true}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Attempts) != 2 || res.Attempts[0].Verdict.Kind != ErrLint.String() || len(res.Attempts[0].Verdict.Issues) != 1 {
+		t.Fatalf("unexpected attempts %+v", res.Attempts)
+	}
+	if rt.runs != 1 {
+		t.Errorf("code with lint issues must not run, got %d runs", rt.runs)
+	}
+	if !strings.Contains(provider.lastPrompt, "F401") {
+		t.Errorf("feedback should list issues: %q", provider.lastPrompt)
+	}
+}
+
+func TestQualityGateWarnOnly(t *testing.T) {
+	rt := &lintRuntime{lintOutput: []string{"::tool vet\nmain.go:3:2: unreachable code\n"}}
+	p, _ := New(WithProvider(&fakeProvider{content: "```go\npackage main\n\nfunc main() {}\n```"}), WithRuntime(rt),
+		WithLogger(DiscardLogger), WithQualityGate(QualityGate{Enabled: true}))
+	if _, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "go", WorkDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	if rt.lints != 1 || rt.runs != 1 {
+		t.Errorf("expect one lint and one run, got %d/%d", rt.lints, rt.runs)
+	}
+}
@@ -0,0 +1,288 @@
+package aca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/mcp"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+)
+
+// MCPServer 把 Pipeline 以 MCP 工具与资源的形式提供给编辑器等客户端
+type MCPServer = mcp.Server
+
+// sessionURI 会话资源的 URI 前缀
+const sessionURI = "aca://sessions/"
+
+// ConnectMCP 连接 servers 中未禁用的外部 MCP 服务（如 Config.MCPServers），单个服务失败只记录在 Status 中。
+// 用完后调用 Close 断开并终止 stdio 服务进程
+func ConnectMCP(ctx context.Context, servers map[string]MCPServerConfig) *MCPTools {
+	return agent.ConnectMCP(ctx, servers)
+}
+
+// LoadMCPServers 读取 .cursor/mcp.json 格式的 MCP 服务配置
+func LoadMCPServers(path string) (map[string]MCPServerConfig, error) {
+	servers, err := mcp.LoadServers(path)
+	if err != nil {
+		return nil, errs.E(errs.Config, "load mcp servers", err)
+	}
+	return servers, nil
+}
+
+// WithMCPTools 让模型在生成代码时可以调用外部 MCP 服务的工具（各模式通用，agent 模式与沙箱工具一起提供），
+// 工具的可用情况与调用记录保存在会话中。每次模型请求的工具调用循环受 ToolLimits 限制
+func WithMCPTools(tools *MCPTools) Option {
+	return func(pl *Pipeline) { pl.mcp = tools }
+}
+
+// MCPServer 创建 MCP 服务端，提供 generate_code、generate_tests、run_in_sandbox 工具；
+// 配置了 SessionStore 时以资源的形式提供会话记录与生成的文件。defaults 为工具参数未指定时使用的任务设置。
+// 与 JobQueue 相同，未启用独立运行目录或启用了 git 集成时生成类工具调用逐个执行
+func (p *Pipeline) MCPServer(defaults Task) *MCPServer {
+	s := mcp.NewServer("aca", "0.1.0")
+	s.Instructions = "Generate code and tests with an LLM and run them in a Docker sandbox. Each call runs the full generate-run-fix loop and may take minutes."
+	taskParams := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"prompt":   map[string]any{"type": "string", "description": "natural language description of the task"},
+			"language": map[string]any{"type": "string", "description": "programming language, e.g. go or python"},
+			"model":    map[string]any{"type": "string", "description": "model name, defaults to the server's model"},
+		},
+		"required": []any{"prompt"},
+	}
+	s.AddTool(mcp.Tool{
+		Name:        "generate_code",
+		Description: "Generate code for the prompt, run it in a Docker sandbox and retry with the errors until it runs.",
+		InputSchema: taskParams,
+	}, func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+		task, err := mcpTask(defaults, args)
+		if err != nil {
+			return nil, err
+		}
+		release, err := p.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		res, err := p.Generate(ctx, task)
+		return mcpResult(res.SessionID, res.Task.WorkDir, res.Files, res.Output, err), nil
+	})
+	s.AddTool(mcp.Tool{
+		Name:        "generate_tests",
+		Description: "Generate unit tests for the prompt and run them in a Docker sandbox.",
+		InputSchema: taskParams,
+	}, func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+		task, err := mcpTask(defaults, args)
+		if err != nil {
+			return nil, err
+		}
+		release, err := p.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		res, err := p.Test(ctx, task)
+		var files []File
+		if res.TestFile.Path != "" {
+			files = []File{res.TestFile}
+		}
+		return mcpResult(res.SessionID, res.Task.WorkDir, files, res.Output, err), nil
+	})
+	s.AddTool(mcp.Tool{
+		Name:        "run_in_sandbox",
+		Description: "Write the given files to a fresh directory and run them (or the given command) in the language's Docker sandbox.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"language": map[string]any{"type": "string", "description": "programming language, e.g. go or python"},
+				"files": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"path":    map[string]any{"type": "string"},
+							"content": map[string]any{"type": "string"},
+						},
+						"required": []any{"path", "content"},
+					},
+				},
+				"command": map[string]any{"type": "string", "description": "shell command to run instead of the main file"},
+			},
+			"required": []any{"files"},
+		},
+	}, func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+		var in struct {
+			Language string `json:"language"`
+			Files    []File `json:"files"`
+			Command  string `json:"command"`
+		}
+		if err := json.Unmarshal(args, &in); err != nil {
+			return nil, err
+		}
+		task := defaults
+		if in.Language != "" {
+			task.Language = in.Language
+		}
+		res, err := p.RunFiles(ctx, task, in.Files, in.Command)
+		return mcpResult("", res.Task.WorkDir, res.Files, res.Output, err), nil
+	})
+	if p.store != nil {
+		s.Resources = sessionResources{p.store}
+	}
+	return s
+}
+
+// RunFiles 把 files 写入 WorkDir 下新建的 sandbox-<ID> 目录，经安全检查后在容器中运行主文件，
+// command 非空时改为执行该 shell 命令。文件路径、数量与大小按生成代码的规则校验
+func (p *Pipeline) RunFiles(ctx context.Context, task Task, files []File, command string) (*GenerateResult, error) {
+	task, err := p.normalize(task, false)
+	if err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	if len(files) == 0 {
+		return &GenerateResult{Task: task}, errs.Errorf(errs.Usage, "run files", "no files given")
+	}
+	task.WorkDir = filepath.Join(task.WorkDir, "sandbox-"+session.NewID())
+	if err := os.MkdirAll(task.WorkDir, 0755); err != nil {
+		return &GenerateResult{Task: task}, errs.E(errs.Other, "create run dir", err)
+	}
+	// 借用 Toolbox 校验写入的文件并识别主文件
+	toolbox := &agent.Toolbox{Languages: p.languages, Language: task.Language, WorkDir: task.WorkDir}
+	for _, f := range files {
+		if _, err := toolbox.WriteFile(f.Path, f.Content); err != nil {
+			return &GenerateResult{Task: task, Files: toolbox.Files()}, errs.E(errs.Usage, "write "+f.Path, err)
+		}
+	}
+	res := &GenerateResult{Task: task, Files: toolbox.Files()}
+	if err := p.scan(res.Files); err != nil {
+		return res, err
+	}
+	if command == "" {
+		res.Output, err = p.runFiles(ctx, task, res.Files)
+		return res, err
+	}
+	p.logger.Info("用 Docker 执行命令:", command)
+	res.Output, err = p.generator().RunCommand(ctx, task.Language, task.WorkDir, command, task.MountDir)
+	if p.hooks.OnRun != nil {
+		p.hooks.OnRun(ctx, task, res.Output, err)
+	}
+	return res, err
+}
+
+// mcpTask 用工具参数覆盖默认任务设置
+func mcpTask(defaults Task, args json.RawMessage) (Task, error) {
+	var in struct {
+		Prompt   string `json:"prompt"`
+		Language string `json:"language"`
+		Model    string `json:"model"`
+	}
+	if err := json.Unmarshal(args, &in); err != nil {
+		return defaults, err
+	}
+	task := defaults
+	task.Prompt = in.Prompt
+	if in.Language != "" {
+		task.Language = in.Language
+	}
+	if in.Model != "" {
+		task.Model = in.Model
+	}
+	return task, nil
+}
+
+// mcpResult 将运行结果格式化为工具结果，err 非空时标记为失败并附上错误类别
+func mcpResult(sessionID, workDir string, files []File, output string, err error) *mcp.CallToolResult {
+	var sb strings.Builder
+	if err != nil {
+		fmt.Fprintf(&sb, "status: failed (%s)\nerror: %v\n", KindOf(err), err)
+	} else {
+		sb.WriteString("status: passed\n")
+	}
+	if sessionID != "" {
+		fmt.Fprintf(&sb, "session: %s (resource %s%s)\n", sessionID, sessionURI, sessionID)
+	}
+	if workDir != "" {
+		fmt.Fprintf(&sb, "workdir: %s\n", workDir)
+	}
+	for _, f := range files {
+		fmt.Fprintf(&sb, "\n--- %s ---\n%s\n", f.Path, strings.TrimRight(f.Content, "\n"))
+	}
+	if strings.TrimSpace(output) != "" {
+		fmt.Fprintf(&sb, "\n--- output ---\n%s\n", strings.TrimRight(output, "\n"))
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent(sb.String())}, IsError: err != nil}
+}
+
+// sessionResources 以 aca://sessions/<ID> 提供会话记录，aca://sessions/<ID>/files/<路径> 提供最后一次尝试的文件，
+// aca://sessions/<ID>/output 提供最后一次尝试的输出
+type sessionResources struct {
+	store *SessionStore
+}
+
+func (r sessionResources) ListResources() ([]mcp.Resource, error) {
+	sessions, err := r.store.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []mcp.Resource
+	for _, s := range sessions {
+		out = append(out, mcp.Resource{
+			URI:         sessionURI + s.ID,
+			Name:        fmt.Sprintf("session %s (%s, %s)", s.ID, s.Mode, s.Status),
+			Description: s.Prompt,
+			MimeType:    "application/json",
+		})
+		if last := s.LastAttempt(); last != nil {
+			for _, name := range last.FileNames {
+				name = strings.TrimPrefix(name, "*") // 主文件标记
+				out = append(out, mcp.Resource{URI: sessionURI + s.ID + "/files/" + name, Name: name, MimeType: "text/plain"})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r sessionResources) ResourceTemplates() []mcp.ResourceTemplate {
+	return []mcp.ResourceTemplate{
+		{URITemplate: sessionURI + "{id}", Name: "session", Description: "session record with all attempts", MimeType: "application/json"},
+		{URITemplate: sessionURI + "{id}/files/{path}", Name: "session file", Description: "file generated by the last attempt", MimeType: "text/plain"},
+		{URITemplate: sessionURI + "{id}/output", Name: "session output", Description: "sandbox output of the last attempt", MimeType: "text/plain"},
+	}
+}
+
+func (r sessionResources) ReadResource(uri string) ([]mcp.ResourceContents, error) {
+	rest, ok := strings.CutPrefix(uri, sessionURI)
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("resource not found: %s", uri)
+	}
+	id, sub, _ := strings.Cut(rest, "/")
+	sess, err := r.store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	last := sess.LastAttempt()
+	switch {
+	case sub == "":
+		data, err := json.MarshalIndent(sess, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{{URI: uri, MimeType: "application/json", Text: string(data)}}, nil
+	case sub == "output" && last != nil:
+		return []mcp.ResourceContents{{URI: uri, MimeType: "text/plain", Text: last.Output}}, nil
+	case strings.HasPrefix(sub, "files/") && last != nil:
+		name := strings.TrimPrefix(sub, "files/")
+		for _, f := range last.Files {
+			if f.Path == name {
+				return []mcp.ResourceContents{{URI: uri, MimeType: "text/plain", Text: f.Content}}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("resource not found: %s", uri)
+}
@@ -0,0 +1,162 @@
+package aca
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/httpguard"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/mcp"
+)
+
+// callTool 通过 JSON-RPC 调用 MCP 工具并返回结果
+func callTool(t *testing.T, s *MCPServer, name, args string) mcp.CallToolResult {
+	t.Helper()
+	out := s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+name+`","arguments":`+args+`}}`))
+	var resp struct {
+		Result mcp.CallToolResult
+		Error  *mcp.Error
+	}
+	if err := json.Unmarshal(out, &resp); err != nil || resp.Error != nil {
+		t.Fatalf("tools/call %s failed: %s", name, out)
+	}
+	return resp.Result
+}
+
+func TestMCPGenerateCode(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	p, _ := New(
+		WithProvider(&fakeProvider{content: "```python\nprint('hello')\n```"}),
+		WithRuntime(&fakeRuntime{output: "hello\n"}),
+		WithLogger(DiscardLogger),
+		WithSessionStore(store),
+	)
+	s := p.MCPServer(Task{WorkDir: t.TempDir()})
+	res := callTool(t, s, "generate_code", `{"prompt":"say hello","language":"python"}`)
+	if res.IsError || !strings.Contains(res.Text(), "--- main.py ---\nprint('hello')") || !strings.Contains(res.Text(), "hello\n") {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	sessions, _ := store.List()
+	if len(sessions) != 1 {
+		t.Fatalf("expect 1 session, got %d", len(sessions))
+	}
+	id := sessions[0].ID
+
+	// 会话记录与生成的文件以资源提供
+	out := s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"resources/list"}`))
+	if !strings.Contains(string(out), `"uri":"aca://sessions/`+id+`/files/main.py"`) {
+		t.Errorf("unexpected resources %s", out)
+	}
+	out = s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"aca://sessions/`+id+`/files/main.py"}}`))
+	if !strings.Contains(string(out), `"text":"print('hello')`) {
+		t.Errorf("unexpected resource %s", out)
+	}
+
+	res = callTool(t, s, "generate_code", `{"prompt":"x","language":"cobol"}`)
+	if !res.IsError || !strings.Contains(res.Text(), "unsupported language") {
+		t.Errorf("expect tool error, got %+v", res)
+	}
+}
+
+func TestMCPRunInSandbox(t *testing.T) {
+	rt := &fakeRuntime{output: "ok\n"}
+	p, _ := New(WithProvider(&fakeProvider{}), WithRuntime(rt), WithLogger(DiscardLogger))
+	s := p.MCPServer(Task{WorkDir: t.TempDir()})
+	res := callTool(t, s, "run_in_sandbox", `{"language":"python","files":[{"path":"main.py","content":"print('ok')"}]}`)
+	if res.IsError || len(rt.specs) != 1 || !strings.Contains(strings.Join(rt.specs[0].Cmd, " "), "main.py") {
+		t.Fatalf("unexpected result %+v, specs %+v", res, rt.specs)
+	}
+	res = callTool(t, s, "run_in_sandbox", `{"language":"python","files":[{"path":"main.py","content":"x"}],"command":"python -V"}`)
+	if res.IsError || strings.Join(rt.specs[1].Cmd, " ") != "sh -c python -V" {
+		t.Errorf("unexpected command run %+v", rt.specs[1])
+	}
+	res = callTool(t, s, "run_in_sandbox", `{"language":"python","files":[{"path":"../x.py","content":"x"}]}`)
+	if !res.IsError || len(rt.specs) != 2 {
+		t.Errorf("expect rejected path, got %+v", res)
+	}
+}
+
+func TestMCPTools(t *testing.T) {
+	stub := mcp.NewServer("docs", "1.0")
+	var queries []string
+	for _, name := range []string{"search", "delete_all"} {
+		stub.AddTool(mcp.Tool{Name: name, InputSchema: map[string]any{"type": "object"}}, func(ctx context.Context, args json.RawMessage) (*mcp.CallToolResult, error) {
+			queries = append(queries, string(args))
+			return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent("use fmt.Println")}}, nil
+		})
+	}
+	srv := httptest.NewServer(stub.Handler(httpguard.Options{}))
+	defer srv.Close()
+	tools := ConnectMCP(context.Background(), map[string]MCPServerConfig{
+		"docs":    {URL: srv.URL + "/mcp", Tools: []string{"search"}},
+		"offline": {URL: srv.URL + "/missing"},
+	})
+	defer tools.Close()
+
+	provider := &toolProvider{replies: []*Completion{
+		toolCall("1", "docs__search", `{"q":"print"}`),
+		{Content: "```python\nprint('hello')\n```"},
+	}}
+	store := NewSessionStore(t.TempDir())
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger),
+		WithSessionStore(store), WithMCPTools(tools))
+	res, err := p.Generate(context.Background(), Task{Prompt: "say hello", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 || queries[0] != `{"q":"print"}` {
+		t.Errorf("unexpected tool calls %v", queries)
+	}
+	// 只提供允许的工具
+	if got := provider.requests[0].Tools; len(got) != 1 || got[0].Name != "docs__search" {
+		t.Errorf("unexpected tools offered %+v", got)
+	}
+	// 工具调用不进入持久化的对话，第二次请求包含调用与结果
+	if n := len(provider.requests[1].Messages); n != 4 {
+		t.Errorf("expect 4 messages in the second request, got %d", n)
+	}
+	sess, err := store.Load(res.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.MCP) != 2 || sess.MCP[0].Name != "docs" || sess.MCP[1].Error == "" {
+		t.Errorf("unexpected mcp status %+v", sess.MCP)
+	}
+	calls := sess.Attempts[0].ToolCalls
+	if len(calls) != 1 || calls[0].Name != "docs__search" || calls[0].Output != "use fmt.Println" {
+		t.Errorf("unexpected call log %+v", calls)
+	}
+}
+
+func TestMCPGenerateSerial(t *testing.T) {
+	for _, runDirs := range []bool{false, true} {
+		provider := &blockingProvider{started: make(chan struct{}, 4), release: make(chan struct{}), content: "```python\nprint('hi')\n```"}
+		p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithRunDirs(runDirs))
+		s := p.MCPServer(Task{WorkDir: t.TempDir()})
+		done := make(chan struct{})
+		for _, prompt := range []string{"a", "b"} {
+			go func() {
+				s.Handle(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"generate_code","arguments":{"prompt":"`+prompt+`","language":"python"}}}`))
+				done <- struct{}{}
+			}()
+		}
+		<-provider.started
+		// 共用工作目录时第二个调用要等第一个结束
+		select {
+		case <-provider.started:
+			if !runDirs {
+				t.Error("expect tool calls sharing the work dir to run one at a time")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if runDirs {
+				t.Error("expect tool calls with separate run dirs to run concurrently")
+			}
+		}
+		close(provider.release)
+		<-done
+		<-done
+	}
+}
@@ -0,0 +1,116 @@
+package aca
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/patch"
+)
+
+// Patch 修改 WorkDir 中已有的项目：把文件当前内容交给模型，应用返回的 unified diff 或
+// search/replace 编辑块，并在容器中编译、运行测试；校验失败时回滚修改并按 Watcher 的判定重试
+func (p *Pipeline) Patch(ctx context.Context, task Task) (*PatchResult, error) {
+	task, err := p.normalize(task, true)
+	if err != nil {
+		return &PatchResult{Task: task}, err
+	}
+	conv, err := p.patchConversation(task)
+	if err != nil {
+		return &PatchResult{Task: task}, err
+	}
+	sess := p.newSession(ModePatch, task)
+	return p.runPatch(ctx, task, sess, conv)
+}
+
+// patchConversation 读取目标文件，构造包含文件内容的初始对话
+func (p *Pipeline) patchConversation(task Task) (*Conversation, error) {
+	files, err := p.patcher().LoadTargets(task.Language, task.WorkDir, task.Files)
+	if err != nil {
+		return nil, err
+	}
+	d := p.PromptData(task.Language, task.Prompt)
+	d.Files = files
+	return p.conversation(ModePatch, d)
+}
+
+func (p *Pipeline) runPatch(ctx context.Context, task Task, sess *Session, conv *Conversation) (*PatchResult, error) {
+	start := time.Now()
+	patcher := p.patcher()
+	var applied *patch.Result
+	err := p.attempts(ctx, task, sess, conv, nil, func(ctx context.Context, a *Attempt) ([]File, string, error) {
+		applied = nil
+		res, err := patcher.ApplyPatch(a.Response, task.WorkDir)
+		if err != nil {
+			return nil, "", err
+		}
+		applied = res
+		var files []File
+		for _, c := range res.Changes {
+			if c.After != nil {
+				files = append(files, File{Path: c.Path, Content: *c.After})
+			}
+		}
+		p.logger.Info("已应用修改:\n====================\n", res.Diff(), "\n====================")
+		if p.hooks.OnFiles != nil {
+			p.hooks.OnFiles(ctx, task, files)
+		}
+		p.emit(ctx, EventFiles, FilesEvent{Files: files})
+		if err := p.scan(files); err != nil {
+			return files, "", err
+		}
+		if files, err = p.lint(ctx, task, files); err != nil {
+			return files, "", err
+		}
+		if err := p.reviewFiles(ctx, task, a, task.Prompt, files); err != nil {
+			return files, "", err
+		}
+		p.logger.Info("用 Docker 编译并测试项目...")
+		output, err := patcher.Check(ctx, task.Language, task.WorkDir, task.MountDir)
+		if p.hooks.OnRun != nil {
+			p.hooks.OnRun(ctx, task, output, err)
+		}
+		p.emitResult(ctx, err)
+		return files, output, err
+	}, func([]File) {
+		// 回滚失败的修改，下一次尝试基于原始文件
+		p.revertPatch(task, sess, applied)
+		applied = nil
+	})
+	if err != nil && applied != nil {
+		p.revertPatch(task, sess, applied)
+	}
+	res := &PatchResult{Task: task, SessionID: sess.ID, Attempts: sess.Attempts, Usage: sess.Usage, Duration: time.Since(start), Applied: err == nil}
+	if last := sess.LastAttempt(); last != nil {
+		res.Response, res.Files, res.Output = last.Response, last.Files, last.Output
+		res.Review = last.Review
+	}
+	if applied != nil {
+		res.Diff = applied.Diff()
+	}
+	return res, err
+}
+
+// revertPatch 将文件恢复为应用补丁前的内容，启用 git 时将回滚提交到会话分支（被补丁删除后恢复的文件同样提交）；
+// res 为 nil（补丁未应用）时不做任何事
+func (p *Pipeline) revertPatch(task Task, sess *Session, res *patch.Result) {
+	if res == nil {
+		return
+	}
+	p.logger.Warning("校验失败，回滚修改")
+	if err := res.Revert(); err != nil {
+		p.logger.Error("回滚修改失败:", err)
+	}
+	paths := make([]string, len(res.Changes))
+	for i, c := range res.Changes {
+		paths[i] = c.Path
+	}
+	p.gitCommitMessage(task, sess, fmt.Sprintf("aca %s: revert failed attempt\n\nSession: %s\n", ModePatch, sess.ID), paths...)
+}
+
+func (p *Pipeline) patcher() *agent.Patcher {
+	pt := agent.NewPatcher(p.client(), p.runtime)
+	pt.Languages = p.languages
+	return pt
+}
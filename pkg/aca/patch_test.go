@@ -0,0 +1,77 @@
+package aca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patchSource = "def add(a, b):\n    return a - b\n"
+
+func TestPatchRevertsOnFailureAndRetries(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "calc.py"), []byte(patchSource), 0644)
+	provider := &scriptedProvider{replies: []string{
+		// 第一次搜索块与文件不匹配，第二次编译失败，第三次通过
+		"calc.py\n<<<<<<< SEARCH\n    return a * b\n=======\n    return a + b\n>>>>>>> REPLACE",
+		"calc.py\n<<<<<<< SEARCH\n    return a - b\n=======\n    return a +\n>>>>>>> REPLACE",
+		"calc.py\n<<<<<<< SEARCH\n    return a - b\n=======\n    return a + b\n>>>>>>> REPLACE",
+	}}
+	rt := &flakyRuntime{failures: 1}
+	p, _ := New(WithProvider(provider), WithRuntime(rt), WithLogger(DiscardLogger), WithMaxAttempts(3))
+	res, err := p.Patch(context.Background(), Task{Prompt: "fix add", Language: "python", WorkDir: workDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Attempts) != 3 || res.Attempts[0].Verdict.Kind != ErrPatch.String() || res.Attempts[1].Verdict.Kind != ErrBuild.String() {
+		t.Fatalf("unexpected attempts %+v", res.Attempts)
+	}
+	if !res.Applied || !strings.Contains(res.Diff, "+    return a + b") {
+		t.Errorf("unexpected result %+v", res)
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "calc.py"))
+	if string(data) != "def add(a, b):\n    return a + b\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+	if rt.runs != 2 {
+		t.Errorf("expect 2 check runs, got %d", rt.runs)
+	}
+}
+
+func TestPatchFailureLeavesFilesUntouched(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "calc.py"), []byte(patchSource), 0644)
+	provider := &scriptedProvider{replies: []string{
+		"--- a/calc.py\n+++ b/calc.py\n@@ -1,2 +1,2 @@\n def add(a, b):\n-    return a - b\n+    return a +\n",
+	}}
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 1}), WithLogger(DiscardLogger))
+	res, err := p.Patch(context.Background(), Task{Prompt: "fix add", Language: "python", WorkDir: workDir, Files: []string{"calc.py"}})
+	if KindOf(err) != ErrBuild {
+		t.Fatalf("expect build error, got %v", err)
+	}
+	if res.Applied || len(res.Files) != 1 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	data, _ := os.ReadFile(filepath.Join(workDir, "calc.py"))
+	if string(data) != patchSource {
+		t.Errorf("failed patch should be reverted, got %q", data)
+	}
+	if !strings.Contains(provider.lastPrompt, "calc.py") || !strings.Contains(provider.lastPrompt, "return a - b") {
+		t.Errorf("prompt should contain current file content, got %q", provider.lastPrompt)
+	}
+}
+
+func TestPatchRejectsMissingFiles(t *testing.T) {
+	p, _ := New(WithProvider(&fakeProvider{}), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+	cases := []Task{
+		{Prompt: "x", Language: "python", WorkDir: t.TempDir()},
+		{Prompt: "x", Language: "python", WorkDir: t.TempDir(), Files: []string{"../outside.py"}},
+	}
+	for _, task := range cases {
+		if _, err := p.Patch(context.Background(), task); KindOf(err) != ErrUsage {
+			t.Errorf("expect usage error for %+v, got %v", task, err)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+// Package aca 提供可嵌入的 Agent-Cat-Agent 流水线：调用大模型生成代码或测试，
+// 写入工作目录后在容器中运行。
+//
+//	cfg, _ := aca.LoadConfig("etc/config.yaml")
+//	docker, _ := aca.NewDockerRuntime()
+//	defer docker.Close()
+//	p, _ := aca.New(aca.WithConfig(cfg), aca.WithRuntime(docker))
+//	res, err := p.Generate(ctx, aca.Task{Prompt: "生成一个矩阵乘法"})
+package aca
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/logger"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+const (
+	DefaultLanguage = "go"
+	DefaultWorkDir  = "./tmp"
+	DefaultMountDir = "/app"
+)
+
+// Hooks 流水线各阶段的回调，未设置的回调会被跳过
+type Hooks struct {
+	OnRequest  func(ctx context.Context, task Task)
+	OnResponse func(ctx context.Context, task Task, response string)
+	OnFiles    func(ctx context.Context, task Task, files []File)
+	OnRun      func(ctx context.Context, task Task, output string, err error)
+}
+
+// Pipeline 代码/测试生成流水线，可并发使用
+type Pipeline struct {
+	provider  Provider
+	runtime   Runtime
+	languages *LanguageRegistry
+	logger    Logger
+	hooks     Hooks
+	model     string
+	// contextLimit 多轮会话的上下文 token 上限，0 表示不限制
+	contextLimit int
+	maxAttempts  int
+	watcher      *agent.Watcher
+	store        *SessionStore
+	git          bool
+	runDirs      bool
+	// exclusive 容量为 1，任务共用工作目录时由 acquire 取得，With 派生的 Pipeline 共用同一个
+	exclusive  chan struct{}
+	policy     SecurityPolicy
+	quality    QualityGate
+	review     ReviewOptions
+	structured bool
+	toolLimits ToolLimits
+	mcp        *MCPTools
+	events     *EventBus
+	prompts    *PromptSet
+	// system 非空时替换生成代码与测试的系统提示词
+	system  string
+	pricing Pricing
+	budget  Budget
+	// resilience 非空时 New 为 provider 加上超时、重试、限流与备用模型
+	resilience *LLMOptions
+}
+
+// Option 配置 Pipeline
+type Option func(*Pipeline)
+
+// WithProvider 设置大模型服务
+func WithProvider(p Provider) Option {
+	return func(pl *Pipeline) { pl.provider = p }
+}
+
+// WithRuntime 设置容器运行时
+func WithRuntime(r Runtime) Option {
+	return func(pl *Pipeline) { pl.runtime = r }
+}
+
+// WithLanguages 设置语言注册表，默认包含 go/python
+func WithLanguages(r *LanguageRegistry) Option {
+	return func(pl *Pipeline) { pl.languages = r }
+}
+
+// WithLogger 设置日志输出，默认输出到标准输出
+func WithLogger(l Logger) Option {
+	return func(pl *Pipeline) { pl.logger = l }
+}
+
+// WithHooks 设置阶段回调
+func WithHooks(h Hooks) Option {
+	return func(pl *Pipeline) { pl.hooks = h }
+}
+
+// WithModel 设置默认模型，Task.Model 非空时优先
+func WithModel(model string) Option {
+	return func(pl *Pipeline) { pl.model = model }
+}
+
+// WithContextLimit 设置多轮会话的上下文 token 上限，超出时摘要较早的对话
+func WithContextLimit(tokens int) Option {
+	return func(pl *Pipeline) { pl.contextLimit = tokens }
+}
+
+// WithMaxAttempts 设置每个任务的最大尝试次数，失败时 Watcher 将错误反馈给模型重试，默认 1
+func WithMaxAttempts(n int) Option {
+	return func(pl *Pipeline) { pl.maxAttempts = n }
+}
+
+// WithStructuredOutput 要求模型按 JSON Schema 输出文件、入口、依赖与运行命令，代替从 markdown 代码块中提取；
+// 不支持结构化输出的服务会回退到 markdown 提取
+func WithStructuredOutput(enabled bool) Option {
+	return func(pl *Pipeline) { pl.structured = enabled }
+}
+
+// WithSystemPrompt 替换生成代码与测试使用的系统提示词，为空时使用内置提示词
+func WithSystemPrompt(prompt string) Option {
+	return func(pl *Pipeline) { pl.system = prompt }
+}
+
+// WithLLMOptions 为大模型服务加上单次请求超时、暂时性错误重试、限流与备用模型，零值字段使用默认值
+func WithLLMOptions(opts LLMOptions) Option {
+	return func(pl *Pipeline) { pl.resilience = &opts }
+}
+
+// WithSessionStore 将每次运行的会话记录保存到 store，便于查看与恢复
+func WithSessionStore(store *SessionStore) Option {
+	return func(pl *Pipeline) { pl.store = store }
+}
+
+// WithConfig 使用配置文件中的 OpenAI 兼容服务与模型
+func WithConfig(cfg *Config) Option {
+	return func(pl *Pipeline) {
+		pl.provider = llm.NewOpenAIClient(*cfg)
+		pl.resilience = &cfg.LLM
+		pl.model = cfg.Model
+		pl.contextLimit = cfg.ContextLimit
+		pl.policy = cfg.Security
+		pl.quality = cfg.Quality
+		pl.review = cfg.Review
+		pl.structured = cfg.StructuredOutput
+		pl.pricing = cfg.Pricing
+		pl.budget = cfg.Budget
+		if cfg.Tools.MaxSteps > 0 {
+			pl.toolLimits.MaxSteps = cfg.Tools.MaxSteps
+		}
+		if cfg.Tools.MaxTokens > 0 {
+			pl.toolLimits.MaxTokens = cfg.Tools.MaxTokens
+		}
+		if cfg.MaxAttempts > 0 {
+			pl.maxAttempts = cfg.MaxAttempts
+		}
+		// LoadConfig 已校验模板，加载失败时保留内置模板
+		if set, err := prompt.Load(cfg.Prompts); err == nil {
+			pl.prompts = set
+		}
+	}
+}
+
+// New 创建 Pipeline，必须提供 Provider 与 Runtime
+func New(opts ...Option) (*Pipeline, error) {
+	p := &Pipeline{
+		languages:   lang.Default(),
+		logger:      logger.Std,
+		maxAttempts: 1,
+		watcher:     agent.NewWatcher(),
+		toolLimits:  DefaultToolLimits,
+		prompts:     prompt.Default(),
+		exclusive:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.provider == nil {
+		return nil, errs.Errorf(errs.Config, "new pipeline", "provider is required")
+	}
+	if p.runtime == nil {
+		return nil, errs.Errorf(errs.Config, "new pipeline", "runtime is required")
+	}
+	if p.prompts == nil {
+		p.prompts = prompt.Default()
+	}
+	if p.resilience != nil {
+		p.provider = p.resilient(p.provider, *p.resilience)
+	}
+	if p.maxAttempts < 1 {
+		p.maxAttempts = 1
+	}
+	return p, nil
+}
+
+// With 返回应用了 opts 的 Pipeline 副本，原 Pipeline 不受影响；用于为单个任务调整设置
+func (p *Pipeline) With(opts ...Option) *Pipeline {
+	cp := *p
+	for _, opt := range opts {
+		opt(&cp)
+	}
+	if cp.maxAttempts < 1 {
+		cp.maxAttempts = 1
+	}
+	return &cp
+}
+
+// LoadConfig 读取 YAML 配置文件
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// NewOpenAIProvider 创建 OpenAI 兼容的大模型服务
+func NewOpenAIProvider(apiKey, baseURL string) Provider {
+	return llm.NewOpenAIClient(config.Config{ApiKey: apiKey, BaseUrl: baseURL})
+}
+
+// NewDockerRuntime 使用环境变量中的 Docker 配置创建运行时，用完需 Close
+func NewDockerRuntime() (*DockerRuntime, error) {
+	d, err := container.NewDockerClient()
+	return d, errs.E(errs.Runtime, "init docker", err)
+}
+
+// DefaultLanguages 返回包含内置语言的新注册表
+func DefaultLanguages() *LanguageRegistry {
+	return lang.Default()
+}
+
+// Languages 返回 Pipeline 使用的语言注册表
+func (p *Pipeline) Languages() *LanguageRegistry {
+	return p.languages
+}
+
+// normalize 填充任务默认值并校验，requirePrompt 为 false 时允许空 Prompt
+func (p *Pipeline) normalize(task Task, requirePrompt bool) (Task, error) {
+	if requirePrompt && strings.TrimSpace(task.Prompt) == "" {
+		return task, errs.Errorf(errs.Usage, "validate task", "prompt is required")
+	}
+	if task.Language == "" {
+		task.Language = DefaultLanguage
+	}
+	if _, ok := p.languages.Get(task.Language); !ok {
+		return task, errs.Errorf(errs.Usage, "validate task", "unsupported language: %s", task.Language)
+	}
+	if task.Model == "" {
+		task.Model = p.model
+	}
+	if task.WorkDir == "" {
+		task.WorkDir = DefaultWorkDir
+	}
+	if task.MountDir == "" {
+		task.MountDir = DefaultMountDir
+	}
+	abs, err := filepath.Abs(task.WorkDir)
+	if err != nil {
+		return task, errs.E(errs.Usage, "resolve workdir", err)
+	}
+	task.WorkDir = abs
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return task, errs.E(errs.Other, "create workdir", err)
+	}
+	return task, nil
+}
+
+// Generate 生成代码、写入 WorkDir 并在容器中运行；失败时按 Watcher 的判定反馈错误并重试
+func (p *Pipeline) Generate(ctx context.Context, task Task) (*GenerateResult, error) {
+	task, err := p.normalize(task, true)
+	if err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	sess := p.newSession(ModeGen, task)
+	if task, err = p.runDir(task, sess); err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	conv, err := p.conversation(ModeGen, p.PromptData(task.Language, task.Prompt))
+	if err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	return p.runGenerate(ctx, task, sess, conv)
+}
+
+func (p *Pipeline) runGenerate(ctx context.Context, task Task, sess *Session, conv *Conversation) (*GenerateResult, error) {
+	start := time.Now()
+	p.logger.Info("创建/检查工作目录:", task.WorkDir)
+	orig := newOriginals()
+	err := p.attempts(ctx, task, sess, conv, nil, func(ctx context.Context, a *Attempt) ([]File, string, error) {
+		files, err := p.writeFiles(ctx, task, sess, orig, a.Response)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := p.scan(files); err != nil {
+			return files, "", err
+		}
+		if files, err = p.lint(ctx, task, files); err != nil {
+			return files, "", err
+		}
+		if err := p.reviewFiles(ctx, task, a, task.Prompt, files); err != nil {
+			return files, "", err
+		}
+		output, err := p.runFiles(ctx, task, files)
+		return files, output, err
+	}, p.removeFiles(task, orig))
+	res := &GenerateResult{Task: task, SessionID: sess.ID, Attempts: sess.Attempts, Usage: sess.Usage, Duration: time.Since(start)}
+	if last := sess.LastAttempt(); last != nil {
+		res.Response, res.Files, res.Output = last.Response, last.Files, last.Output
+		res.Review = last.Review
+	}
+	return res, err
+}
+
+// complete 调用模型生成代码并触发回调，schema 非空时要求结构化输出。
+// 配置了 MCP 工具时模型可先调用工具，工具调用不计入 messages，只通过返回的记录保存在会话中
+func (p *Pipeline) complete(ctx context.Context, task Task, messages []Message, schema *llm.Schema) (string, []ToolCallRecord, error) {
+	if p.hooks.OnRequest != nil {
+		p.hooks.OnRequest(ctx, task)
+	}
+	p.logger.Info("请求 LLM 生成代码...")
+	var content string
+	var calls []ToolCallRecord
+	if p.mcp != nil && len(p.mcp.Tools()) > 0 {
+		conv := &Conversation{Messages: append([]Message(nil), messages...)}
+		req := llm.Request{Model: task.Model, Schema: schema, OnDelta: p.onDelta(ctx)}
+		res, err := agent.RunTools(ctx, p.client(), req, conv, p.mcp, p.toolLimits)
+		for _, call := range res.Calls {
+			p.logger.Info("工具调用", call.Name, call.Arguments, "\n====================\n", call.Output, "\n====================")
+		}
+		p.emitCalls(ctx, res.Calls)
+		if err != nil {
+			return "", res.Calls, err
+		}
+		content, calls = res.Content, res.Calls
+	} else {
+		g := p.generator()
+		g.Schema, g.OnDelta = schema, p.onDelta(ctx)
+		var err error
+		if content, err = g.Complete(ctx, task.Model, messages); err != nil {
+			return "", nil, err
+		}
+	}
+	if p.hooks.OnResponse != nil {
+		p.hooks.OnResponse(ctx, task, content)
+	}
+	p.logger.Info("LLM 响应内容如下:\n====================\n", content, "\n====================")
+	return content, calls, nil
+}
+
+// writeFiles 提取响应中的代码写入 WorkDir 并触发回调，被覆盖的已有文件记录到 orig
+func (p *Pipeline) writeFiles(ctx context.Context, task Task, sess *Session, orig *originals, content string) ([]File, error) {
+	g := p.generator()
+	g.Guard = p.guard(task, sess, orig)
+	mainFiles, depFiles, err := g.WriteCodeFiles(content, task.Language, task.WorkDir, llm.ExtractCodeFilesFromLLMResponse)
+	var rejected *workspace.RejectedError
+	if errors.As(err, &rejected) {
+		for _, r := range rejected.Files {
+			p.logger.Warning("拒绝写入文件", r)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := append(workspace.ReadFiles(task.WorkDir, mainFiles, true), workspace.ReadFiles(task.WorkDir, depFiles, false)...)
+	if p.hooks.OnFiles != nil {
+		p.hooks.OnFiles(ctx, task, files)
+	}
+	p.emit(ctx, EventFiles, FilesEvent{Files: files})
+	return files, nil
+}
+
+// runFiles 在容器中运行已写入 WorkDir 的文件
+func (p *Pipeline) runFiles(ctx context.Context, task Task, files []File) (string, error) {
+	var mainFiles, depFiles []string
+	for _, f := range files {
+		path := filepath.Join(task.WorkDir, f.Path)
+		if f.Main {
+			mainFiles = append(mainFiles, path)
+		} else {
+			depFiles = append(depFiles, path)
+		}
+	}
+	p.logger.Info("用 Docker 执行代码...")
+	output, err := p.generator().RunCode(ctx, task.Language, task.WorkDir, mainFiles, depFiles, task.MountDir)
+	if p.hooks.OnRun != nil {
+		p.hooks.OnRun(ctx, task, output, err)
+	}
+	if err != nil {
+		return output, err
+	}
+	p.logger.Info("Docker 执行完成，详见上方输出")
+	return output, nil
+}
+
+// schema 返回 mode 下请求使用的输出约束，patch 模式输出编辑块，不使用结构化输出
+func (p *Pipeline) schema(mode string) *llm.Schema {
+	if !p.structured || mode == ModePatch {
+		return nil
+	}
+	return llm.CodeSchema
+}
+
+// removeFiles 清理本次写入的文件，避免残留文件影响下一次尝试；被覆盖的已有文件恢复为 orig 中记录的原始内容
+func (p *Pipeline) removeFiles(task Task, orig *originals) cleanup {
+	return func(files []File) {
+		if err := orig.remove(task.WorkDir, files); err != nil {
+			p.logger.Warning("清理文件失败:", err)
+		}
+	}
+}
+
+func (p *Pipeline) generator() *agent.Generator {
+	return &agent.Generator{LLM: p.client(), Runtime: p.runtime, Languages: p.languages, Prompts: p.prompts}
+}
+
+// Test 生成单元测试、写入 WorkDir 并在容器中执行，测试未通过时返回 ErrTestFailed
+func (p *Pipeline) Test(ctx context.Context, task Task) (*TestResult, error) {
+	task, err := p.normalize(task, true)
+	if err != nil {
+		return &TestResult{Task: task}, err
+	}
+	sess := p.newSession(ModeTest, task)
+	if task, err = p.runDir(task, sess); err != nil {
+		return &TestResult{Task: task}, err
+	}
+	conv, err := p.conversation(ModeTest, p.PromptData(task.Language, task.Prompt))
+	if err != nil {
+		return &TestResult{Task: task}, err
+	}
+	return p.runTest(ctx, task, sess, conv)
+}
+
+func (p *Pipeline) runTest(ctx context.Context, task Task, sess *Session, conv *Conversation) (*TestResult, error) {
+	start := time.Now()
+	p.logger.Info("创建/检查工作目录:", task.WorkDir)
+	orig := newOriginals()
+	tester := &agent.Tester{LLM: p.client(), Runtime: p.runtime, Languages: p.languages, Guard: p.guard(task, sess, orig)}
+	err := p.attempts(ctx, task, sess, conv, nil, func(ctx context.Context, a *Attempt) ([]File, string, error) {
+		testPath, err := tester.WriteTestFile(a.Response, task.Language, task.WorkDir)
+		if err != nil {
+			return nil, "", err
+		}
+		files := workspace.ReadFiles(task.WorkDir, []string{testPath}, false)
+		p.logger.Info("LLM 响应的测试代码如下:\n====================\n", files[0].Content, "\n====================")
+		if p.hooks.OnFiles != nil {
+			p.hooks.OnFiles(ctx, task, files)
+		}
+		p.emit(ctx, EventFiles, FilesEvent{Files: files})
+		if err := p.scan(files); err != nil {
+			return files, "", err
+		}
+		if files, err = p.lint(ctx, task, files); err != nil {
+			return files, "", err
+		}
+		if err := p.reviewFiles(ctx, task, a, task.Prompt, files); err != nil {
+			return files, "", err
+		}
+		p.logger.Info("用 Docker 执行测试代码...")
+		output, err := tester.RunTest(ctx, task.Language, task.WorkDir, files[0].Path, task.MountDir)
+		if p.hooks.OnRun != nil {
+			p.hooks.OnRun(ctx, task, output, err)
+		}
+		p.emitResult(ctx, err)
+		return files, output, err
+	}, p.removeFiles(task, orig))
+	res := &TestResult{Task: task, SessionID: sess.ID, Attempts: sess.Attempts, Usage: sess.Usage, Duration: time.Since(start)}
+	if last := sess.LastAttempt(); last != nil {
+		res.Response, res.Output, res.Passed = last.Response, last.Output, last.Verdict.Passed
+		res.Review = last.Review
+		if len(last.Files) > 0 {
+			res.TestFile = last.Files[0]
+		}
+	}
+	return res, err
+}
+
+// resilient 包装 provider，重试与改用备用模型时记录警告
+func (p *Pipeline) resilient(provider Provider, opts LLMOptions) Provider {
+	r := llm.NewResilient(provider, opts)
+	r.OnRetry = func(model string, retry int, delay time.Duration, err error) {
+		p.logger.Warning("模型", model, "调用失败:", err, "，", delay.Round(time.Millisecond), "后第", retry, "次重试")
+	}
+	r.OnFallback = func(from, to string, err error) {
+		p.logger.Warning("模型", from, "不可用:", err, "，改用", to)
+	}
+	return r
+}
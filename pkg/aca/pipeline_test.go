@@ -0,0 +1,109 @@
+package aca
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeProvider struct {
+	content string
+	err     error
+}
+
+func (f *fakeProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Completion{Content: f.content}, nil
+}
+
+type fakeRuntime struct {
+	specs  []RunSpec
+	output string
+	err    error
+}
+
+func (f *fakeRuntime) Run(ctx context.Context, spec RunSpec) (string, error) {
+	f.specs = append(f.specs, spec)
+	return f.output, f.err
+}
+
+func TestNewRequiresProviderAndRuntime(t *testing.T) {
+	if _, err := New(WithRuntime(&fakeRuntime{})); KindOf(err) != ErrConfig {
+		t.Errorf("expect config error without provider, got %v", err)
+	}
+	if _, err := New(WithProvider(&fakeProvider{})); KindOf(err) != ErrConfig {
+		t.Errorf("expect config error without runtime, got %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	rt := &fakeRuntime{output: "hello\n"}
+	var gotFiles []File
+	p, err := New(
+		WithProvider(&fakeProvider{content: "```python\nprint('hello')\n```"}),
+		WithRuntime(rt),
+		WithLogger(DiscardLogger),
+		WithHooks(Hooks{OnFiles: func(ctx context.Context, task Task, files []File) { gotFiles = files }}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.Generate(context.Background(), Task{Prompt: "say hello", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Output != "hello\n" {
+		t.Errorf("unexpected output %q", res.Output)
+	}
+	if len(res.Files) != 1 || res.Files[0].Path != "main.py" || !res.Files[0].Main {
+		t.Errorf("unexpected files %+v", res.Files)
+	}
+	if len(gotFiles) != 1 {
+		t.Errorf("OnFiles hook not called")
+	}
+	if len(rt.specs) != 1 || rt.specs[0].Image != "python:3.11" || rt.specs[0].MountDir != DefaultMountDir {
+		t.Errorf("unexpected run spec %+v", rt.specs)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider *fakeProvider
+		task     Task
+		expect   ErrorKind
+	}{
+		{"empty prompt", &fakeProvider{}, Task{Language: "python"}, ErrUsage},
+		{"unknown language", &fakeProvider{}, Task{Prompt: "x", Language: "cobol"}, ErrUsage},
+		{"llm error", &fakeProvider{err: errors.New("503")}, Task{Prompt: "x", Language: "python"}, ErrLLM},
+		{"empty response", &fakeProvider{content: " "}, Task{Prompt: "x", Language: "python"}, ErrExtract},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, _ := New(WithProvider(c.provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+			c.task.WorkDir = t.TempDir()
+			_, err := p.Generate(context.Background(), c.task)
+			if KindOf(err) != c.expect {
+				t.Errorf("expect %v, got %v (%v)", c.expect, KindOf(err), err)
+			}
+		})
+	}
+}
+
+func TestTestFailed(t *testing.T) {
+	rt := &fakeRuntime{output: "1 failed", err: &ExitError{Code: 1}}
+	p, _ := New(
+		WithProvider(&fakeProvider{content: "```python\ndef test_x():\n    assert False\n```"}),
+		WithRuntime(rt),
+		WithLogger(DiscardLogger),
+	)
+	res, err := p.Test(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if KindOf(err) != ErrTestFailed {
+		t.Fatalf("expect test failed, got %v", err)
+	}
+	if res.Passed || res.TestFile.Path != "test_main.py" {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
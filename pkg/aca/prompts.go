@@ -0,0 +1,68 @@
+package aca
+
+import (
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+)
+
+// WithPrompts 设置提示词模板集，默认使用内置模板；WithConfig 会加载配置中的 prompts
+func WithPrompts(set *PromptSet) Option {
+	return func(pl *Pipeline) { pl.prompts = set }
+}
+
+// LoadPrompts 以配置中的模板目录与模板覆盖内置模板
+func LoadPrompts(cfg PromptConfig) (*PromptSet, error) {
+	return prompt.Load(cfg)
+}
+
+// DefaultPrompts 返回只包含内置模板的模板集
+func DefaultPrompts() *PromptSet {
+	return prompt.Default()
+}
+
+// Prompts 返回 Pipeline 使用的提示词模板集
+func (p *Pipeline) Prompts() *PromptSet {
+	return p.prompts
+}
+
+// PromptData 返回 language 下需求为 text 的模板变量
+func (p *Pipeline) PromptData(language, text string) PromptData {
+	d := prompt.NewData(p.languages, language, text)
+	d.Structured = p.structured
+	return d
+}
+
+// render 渲染 mode 的 role 消息；WithSystemPrompt 设置的提示词替换 gen/test 的系统消息
+func (p *Pipeline) render(mode, role string, d PromptData) (string, error) {
+	if role == prompt.RoleSystem && p.system != "" && (mode == ModeGen || mode == ModeTest) {
+		return p.system, nil
+	}
+	return p.prompts.Render(mode, role, d)
+}
+
+// conversation 以 mode 的系统消息与用户消息开始一段对话
+func (p *Pipeline) conversation(mode string, d PromptData) (*Conversation, error) {
+	system, err := p.render(mode, prompt.RoleSystem, d)
+	if err != nil {
+		return nil, err
+	}
+	user, err := p.render(mode, prompt.RoleUser, d)
+	if err != nil {
+		return nil, err
+	}
+	conv := llm.NewConversation(system)
+	conv.AddUser(user)
+	return conv, nil
+}
+
+// feedback 用 fix 模板为失败的尝试 a 生成修复提示，模板变量包含之前各次失败的原因
+func (p *Pipeline) feedback(task Task, sess *Session, a *Attempt, output string) (string, error) {
+	d := p.watcher.FixData(p.PromptData(task.Language, task.Prompt), a.Verdict, output)
+	d.Attempt = a.Number
+	for _, prev := range sess.Attempts {
+		if prev.Number < a.Number && !prev.Verdict.Passed {
+			d.Errors = append(d.Errors, prev.Verdict.Reason)
+		}
+	}
+	return p.render(prompt.ModeFix, prompt.RoleUser, d)
+}
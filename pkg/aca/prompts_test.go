@@ -0,0 +1,53 @@
+package aca
+
+import (
+	"context"
+	"testing"
+)
+
+// recordingProvider 依次返回 replies 并记录每次请求的消息
+type recordingProvider struct {
+	replies  []string
+	requests [][]Message
+}
+
+func (r *recordingProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	r.requests = append(r.requests, req.Messages)
+	reply := r.replies[0]
+	r.replies = r.replies[1:]
+	return &Completion{Content: reply}, nil
+}
+
+func TestPipelinePrompts(t *testing.T) {
+	set, err := LoadPrompts(PromptConfig{
+		Templates: map[string]string{
+			"gen.python.system": "python {{.Version}} {{.MainFile}}",
+			"gen.user":          "需求: {{.Prompt}}",
+			"fix.user":          "第 {{.Attempt}} 次失败 ({{.Kind}}): {{.Output}}",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &recordingProvider{replies: []string{"```python\nprint(\n```", "```python\nprint(1)\n```"}}
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 1}), WithLogger(DiscardLogger),
+		WithMaxAttempts(2), WithPrompts(set))
+	if _, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	first, second := provider.requests[0], provider.requests[1]
+	if first[0].Content != "python 3.11 main.py" || first[1].Content != "需求: print 1" {
+		t.Errorf("unexpected first request %+v", first)
+	}
+	want := "第 1 次失败 (build): File \"main.py\", line 1\nSyntaxError: invalid syntax"
+	if got := second[len(second)-1].Content; got != want {
+		t.Errorf("unexpected feedback %q, want %q", got, want)
+	}
+
+	p = p.With(WithSystemPrompt("custom"))
+	provider.replies = []string{"```python\nprint(1)\n```"}
+	p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()})
+	if got := provider.requests[2][0].Content; got != "custom" {
+		t.Errorf("WithSystemPrompt should replace the system prompt, got %q", got)
+	}
+}
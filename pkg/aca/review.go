@@ -0,0 +1,53 @@
+package aca
+
+import (
+	"context"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+)
+
+// 审查结论的处理方式
+const (
+	ReviewGate     = config.ReviewGate     // 审查未通过时让 Coder 修改
+	ReviewAnnotate = config.ReviewAnnotate // 只在结果中附加审查意见
+)
+
+// WithReview 启用运行前的代码审查：Reviewer 根据原始需求审查写入的文件，
+// opts.Mode 为 gate 时要求修改的结论会作为反馈交给 Coder 重写，为 annotate 时只记录在结果中
+func WithReview(opts ReviewOptions) Option {
+	return func(pl *Pipeline) { pl.review = opts }
+}
+
+// reviewFiles 审查 files 并把结论记录在 a 上；审查本身失败时只输出警告，不影响运行
+func (p *Pipeline) reviewFiles(ctx context.Context, task Task, a *Attempt, prompt string, files []File) error {
+	if !p.review.Enabled || len(files) == 0 {
+		return nil
+	}
+	model := p.review.Model
+	if model == "" {
+		model = task.Model
+	}
+	p.logger.Info("请求 LLM 审查代码...")
+	d := p.PromptData(task.Language, prompt)
+	d.Files = files
+	reviewer := &agent.Reviewer{LLM: p.client(), Prompts: p.prompts}
+	review, err := reviewer.Review(ctx, model, d)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		p.logger.Warning("代码审查失败，跳过:", err)
+		return nil
+	}
+	a.Review = review
+	p.logger.Info("代码审查结论:", review.Decision, review.Summary)
+	for _, f := range review.Findings {
+		p.logger.Warning("代码审查:", f)
+	}
+	if !review.Approved() && p.review.Mode != ReviewAnnotate {
+		return errs.E(errs.Review, "review code", &agent.ReviewError{Review: review})
+	}
+	return nil
+}
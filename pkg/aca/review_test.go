@@ -0,0 +1,67 @@
+package aca
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// routedProvider 按系统提示词区分审查请求与生成请求
+type routedProvider struct {
+	coder   *scriptedProvider
+	reviews []string
+	models  []string
+}
+
+func (r *routedProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	if strings.Contains(req.Messages[0].Content, "审查") {
+		r.models = append(r.models, req.Model)
+		reply := r.reviews[0]
+		r.reviews = r.reviews[1:]
+		return &Completion{Content: reply}, nil
+	}
+	return r.coder.Complete(ctx, req)
+}
+
+func TestReviewGate(t *testing.T) {
+	provider := &routedProvider{
+		coder: &scriptedProvider{replies: []string{"```python\nprint(1/0)\n```", "```python\nprint(1)\n```"}},
+		reviews: []string{
+			`{"decision": "request_changes", "findings": [{"severity": "high", "file": "main.py", "line": 1, "message": "division by zero", "suggestion": "divide by 1"}]}`,
+			`{"decision": "approve", "summary": "ok"}`,
+		},
+	}
+	rt := &fakeRuntime{}
+	p, _ := New(WithProvider(provider), WithRuntime(rt), WithLogger(DiscardLogger), WithModel("coder"), WithMaxAttempts(3),
+		WithReview(ReviewOptions{Enabled: true, Model: "reviewer"}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Attempts) != 2 || res.Attempts[0].Verdict.Kind != ErrReview.String() || len(rt.specs) != 1 {
+		t.Fatalf("unexpected attempts %+v (runs %d)", res.Attempts, len(rt.specs))
+	}
+	if !strings.Contains(provider.coder.lastPrompt, "divide by 1") {
+		t.Errorf("feedback should include suggestions: %q", provider.coder.lastPrompt)
+	}
+	if res.Review == nil || !res.Review.Approved() || provider.models[0] != "reviewer" {
+		t.Errorf("unexpected review %+v, models %v", res.Review, provider.models)
+	}
+}
+
+func TestReviewAnnotate(t *testing.T) {
+	provider := &routedProvider{
+		coder:   &scriptedProvider{replies: []string{"```python\nprint(1)\n```"}},
+		reviews: []string{`{"decision": "request_changes", "findings": [{"severity": "low", "file": "main.py", "message": "no docstring"}]}`},
+	}
+	rt := &fakeRuntime{}
+	p, _ := New(WithProvider(provider), WithRuntime(rt), WithLogger(DiscardLogger), WithModel("coder"),
+		WithReview(ReviewOptions{Enabled: true, Mode: ReviewAnnotate}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.specs) != 1 || res.Review == nil || len(res.Review.Findings) != 1 || provider.models[0] != "coder" {
+		t.Errorf("annotate mode should run and record the review: %+v", res.Review)
+	}
+}
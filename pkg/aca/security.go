@@ -0,0 +1,29 @@
+package aca
+
+import (
+	"errors"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/security"
+)
+
+// WithSecurityPolicy 设置运行前静态安全检查的策略，默认所有规则只输出警告
+func WithSecurityPolicy(policy SecurityPolicy) Option {
+	return func(pl *Pipeline) { pl.policy = policy }
+}
+
+// scan 在运行前检查文件：按策略输出警告，存在被拒绝的结果时返回 Security 错误
+func (p *Pipeline) scan(files []File) error {
+	warnings, err := p.policy.Apply(security.Scan(files))
+	for _, f := range warnings {
+		p.logger.Warning("安全检查:", f)
+	}
+	var insecure *security.Error
+	if errors.As(err, &insecure) {
+		for _, f := range insecure.Findings {
+			p.logger.Error("安全检查未通过:", f)
+		}
+		return errs.E(errs.Security, "security check", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package aca
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSecurityPolicy(t *testing.T) {
+	unsafe := "```python\nimport os\nos.system('rm -rf ~')\n```"
+	safe := "```python\nprint(1)\n```"
+
+	// block：拒绝运行且不重试
+	rt := &fakeRuntime{}
+	p, _ := New(WithProvider(&scriptedProvider{replies: []string{unsafe, safe}}), WithRuntime(rt), WithLogger(DiscardLogger),
+		WithMaxAttempts(3), WithSecurityPolicy(SecurityPolicy{Default: "block"}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if KindOf(err) != ErrSecurity {
+		t.Fatalf("expect security error, got %v", err)
+	}
+	if len(rt.specs) != 0 || len(res.Attempts) != 1 || len(res.Attempts[0].Verdict.Findings) == 0 {
+		t.Errorf("blocked code must not run: runs=%d attempts=%+v", len(rt.specs), res.Attempts)
+	}
+
+	// rewrite：把检查结果反馈给模型重写
+	rt = &fakeRuntime{}
+	provider := &scriptedProvider{replies: []string{unsafe, safe}}
+	p, _ = New(WithProvider(provider), WithRuntime(rt), WithLogger(DiscardLogger),
+		WithMaxAttempts(3), WithSecurityPolicy(SecurityPolicy{Default: "rewrite"}))
+	res, err = p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.specs) != 1 || len(res.Attempts) != 2 {
+		t.Errorf("expect one run after rewrite: runs=%d attempts=%d", len(rt.specs), len(res.Attempts))
+	}
+	if !strings.Contains(provider.lastPrompt, "exec-shell") {
+		t.Errorf("feedback should list findings: %q", provider.lastPrompt)
+	}
+
+	// 默认策略只警告
+	rt = &fakeRuntime{}
+	p, _ = New(WithProvider(&fakeProvider{content: unsafe}), WithRuntime(rt), WithLogger(DiscardLogger))
+	if _, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()}); err != nil || len(rt.specs) != 1 {
+		t.Errorf("default policy should only warn: err=%v runs=%d", err, len(rt.specs))
+	}
+}
@@ -0,0 +1,264 @@
+package aca
+
+import (
+	"context"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// NewSessionStore 创建保存在 dir 下的会话存储
+func NewSessionStore(dir string) *SessionStore {
+	return session.NewStore(dir)
+}
+
+// DefaultSessionDir 返回默认的会话目录
+func DefaultSessionDir() string {
+	return session.DefaultDir()
+}
+
+// stage 执行一次尝试中模型回复（a.Response）之后的部分：写入文件并运行，可在 a 上记录审查结论；
+// ctx 标记了本次尝试，用于发布事件
+type stage func(ctx context.Context, a *Attempt) ([]File, string, error)
+
+// cleanup 在失败的尝试之后、重试之前清理该次尝试写入的文件
+type cleanup func(files []File)
+
+// respond 请求模型回复 conv，并把回复追加到 conv，可在 a 上记录工具调用
+type respond func(ctx context.Context, conv *Conversation, a *Attempt) (string, error)
+
+// newSession 创建会话记录，配置了 SessionStore 时立即持久化以分配 ID
+func (p *Pipeline) newSession(mode string, task Task) *Session {
+	sess := &Session{
+		Mode:     mode,
+		Prompt:   task.Prompt,
+		Targets:  task.Files,
+		Language: task.Language,
+		Model:    task.Model,
+		WorkDir:  task.WorkDir,
+		MountDir: task.MountDir,
+		Status:   session.StatusRunning,
+	}
+	if p.mcp != nil {
+		sess.MCP = p.mcp.Status()
+	}
+	p.saveSession(sess, nil)
+	if sess.ID != "" {
+		p.logger.Info("会话 ID:", sess.ID)
+	}
+	return sess
+}
+
+// saveSession 持久化会话，conv 为 nil 时只保存元数据；保存失败只记录警告
+func (p *Pipeline) saveSession(sess *Session, conv *Conversation) {
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Save(sess); err != nil {
+		p.logger.Warning("保存会话失败:", err)
+		return
+	}
+	if conv != nil {
+		if err := p.store.SaveConversation(sess.ID, conv); err != nil {
+			p.logger.Warning("保存对话失败:", err)
+		}
+	}
+}
+
+// finishSession 根据最终错误更新会话状态，结算未计入尝试的模型调用并保存
+func (p *Pipeline) finishSession(ctx context.Context, sess *Session, conv *Conversation, err error) {
+	if m := meterFrom(ctx); m != nil {
+		_, u := m.settle()
+		sess.Usage.Merge(u)
+	}
+	if sess.Usage.Calls > 0 {
+		p.logger.Info("会话用量:", sess.Usage)
+	}
+	switch {
+	case err == nil:
+		sess.Status, sess.Error = session.StatusPassed, ""
+	case ctx.Err() != nil:
+		sess.Status, sess.Error = session.StatusInterrupted, err.Error()
+	default:
+		sess.Status, sess.Error = session.StatusFailed, err.Error()
+	}
+	p.saveSession(sess, conv)
+	p.emit(ctx, EventDone, DoneEvent{Status: sess.Status, Error: sess.Error, Usage: sess.Usage})
+}
+
+// attempts 循环调用模型并执行 run，直到通过、Watcher 判定不再重试或达到最大尝试次数。
+// 若对话以模型回复结尾（上次在运行阶段被中断），先用该回复重新执行一次。
+// ask 为 nil 时直接请求模型，clean 为 nil 时不做清理。会话用量达到预算时不再开始新的尝试。
+func (p *Pipeline) attempts(ctx context.Context, task Task, sess *Session, conv *Conversation, ask respond, run stage, clean cleanup) (err error) {
+	m := newMeter(p.budget, sess.Usage)
+	ctx = withMeter(p.eventContext(ctx, sess.ID, 0), m)
+	defer func() {
+		err = budgetErr(err)
+		p.finishSession(ctx, sess, conv, err)
+	}()
+	if err := p.budget.Check(sess.Usage); err != nil {
+		return err
+	}
+	if err := p.gitBegin(task, sess); err != nil {
+		return err
+	}
+	defer p.gitEnd(task, sess)
+	if ask == nil {
+		ask = func(ctx context.Context, conv *Conversation, a *Attempt) (string, error) {
+			content, calls, err := p.complete(ctx, task, conv.Messages, p.schema(sess.Mode))
+			a.ToolCalls = append(a.ToolCalls, calls...)
+			if err == nil {
+				conv.AddAssistant(content)
+			}
+			return content, err
+		}
+	}
+	limit := len(sess.Attempts) + p.maxAttempts
+	for len(sess.Attempts) < limit {
+		a := Attempt{Number: len(sess.Attempts) + 1, StartedAt: time.Now()}
+		rerun := conv.Last().Role == llm.RoleAssistant && len(sess.Attempts) > 0
+		if rerun {
+			// 重新执行被中断的尝试
+			prev := sess.Attempts[len(sess.Attempts)-1]
+			sess.Attempts = sess.Attempts[:len(sess.Attempts)-1]
+			a.Number, a.Prompt, a.Response = prev.Number, prev.Prompt, prev.Response
+			a.Calls, a.Usage = prev.Calls, prev.Usage
+		}
+		actx := p.eventContext(ctx, sess.ID, a.Number)
+		if !rerun {
+			a.Prompt = conv.Last().Content
+			content, err := ask(actx, conv, &a)
+			if err != nil {
+				return err
+			}
+			a.Response = content
+		}
+		files, output, err := run(actx, &a)
+		a.Files, a.Output = files, output
+		a.Verdict = p.watcher.Judge(ctx, output, err)
+		a.Duration = time.Since(a.StartedAt)
+		calls, u := m.settle()
+		a.Calls = append(a.Calls, calls...)
+		a.Usage.Merge(u)
+		sess.Usage.Merge(u)
+		p.emit(actx, EventVerdict, a.Verdict)
+		p.gitCommit(task, sess, &a)
+		sess.Attempts = append(sess.Attempts, a)
+		if a.Verdict.Passed || !a.Verdict.Retry || len(sess.Attempts) >= limit {
+			return err
+		}
+		if berr := p.budget.Check(sess.Usage); berr != nil {
+			p.logger.Warning("第", a.Number, "次尝试失败:", a.Verdict.Reason, "，", berr, "，不再重试")
+			return berr
+		}
+		p.logger.Warning("第", a.Number, "次尝试失败:", a.Verdict.Reason, "，反馈给模型重试")
+		p.emit(actx, EventRetry, RetryEvent{Next: a.Number + 1, Reason: a.Verdict.Reason})
+		feedback, ferr := p.feedback(task, sess, &a, output)
+		if ferr != nil {
+			return ferr
+		}
+		conv.AddUser(feedback)
+		if clean != nil {
+			clean(files)
+		}
+		p.saveSession(sess, conv)
+	}
+	return nil
+}
+
+// Sessions 返回 SessionStore 中的全部会话，未配置时返回错误
+func (p *Pipeline) Sessions() ([]*Session, error) {
+	if p.store == nil {
+		return nil, errs.Errorf(errs.Config, "list sessions", "session store is not configured")
+	}
+	return p.store.List()
+}
+
+// loadSession 读取会话及其对话历史，并将最后一次尝试的文件恢复到工作目录
+func (p *Pipeline) loadSession(id string) (*Session, *Conversation, Task, error) {
+	if p.store == nil {
+		return nil, nil, Task{}, errs.Errorf(errs.Config, "resume session", "session store is not configured")
+	}
+	sess, err := p.store.Load(id)
+	if err != nil {
+		return nil, nil, Task{}, errs.E(errs.Usage, "resume session", err)
+	}
+	conv, err := p.store.LoadConversation(sess.ID)
+	if err != nil {
+		return nil, nil, Task{}, errs.E(errs.Other, "resume session", err)
+	}
+	if conv == nil && sess.Mode != ModePatch {
+		mode := sess.Mode
+		if mode == ModeChat {
+			mode = ModeGen
+		}
+		if conv, err = p.conversation(mode, p.PromptData(sess.Language, sess.Prompt)); err != nil {
+			return nil, nil, Task{}, err
+		}
+	}
+	task, err := p.normalize(Task{
+		Prompt:   sess.Prompt,
+		Files:    sess.Targets,
+		Language: sess.Language,
+		Model:    sess.Model,
+		WorkDir:  sess.WorkDir,
+		MountDir: sess.MountDir,
+	}, false)
+	if err != nil {
+		return nil, nil, task, err
+	}
+	if conv == nil {
+		// patch 会话需要重新读取文件的当前内容
+		if conv, err = p.patchConversation(task); err != nil {
+			return nil, nil, task, err
+		}
+	}
+	if last := sess.LastAttempt(); last != nil && sess.Mode != ModePatch {
+		// patch 会话失败时修改已回滚，恢复文件会破坏目标项目
+		if err := workspace.WriteFiles(task.WorkDir, last.Files); err != nil {
+			return nil, nil, task, errs.E(errs.Other, "restore files", err)
+		}
+	}
+	return sess, conv, task, nil
+}
+
+// Resume 继续一个未完成或失败的 gen/test/patch/agent 会话，最多再尝试 maxAttempts 次，返回更新后的会话。
+// chat 会话请使用 ResumeChat。
+func (p *Pipeline) Resume(ctx context.Context, id string) (*Session, error) {
+	sess, conv, task, err := p.loadSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Mode != ModeGen && sess.Mode != ModeTest && sess.Mode != ModePatch && sess.Mode != ModeAgent {
+		return sess, errs.Errorf(errs.Usage, "resume session", "session %s is a %s session", sess.ID, sess.Mode)
+	}
+	if sess.Status == session.StatusPassed {
+		return sess, errs.Errorf(errs.Usage, "resume session", "session %s already passed", sess.ID)
+	}
+	if last := sess.LastAttempt(); last != nil && sess.Status == session.StatusFailed && conv.Last().Role == llm.RoleAssistant {
+		// 上次失败后已结束，追加反馈后重新请求模型；其余情况（中断/崩溃）重新执行最后一次尝试
+		feedback, err := p.feedback(task, sess, last, last.Output)
+		if err != nil {
+			return sess, err
+		}
+		conv.AddUser(feedback)
+	}
+	sess.Status, sess.Error = session.StatusRunning, ""
+	p.logger.Info("恢复会话:", sess.ID, "，已有", len(sess.Attempts), "次尝试")
+	switch sess.Mode {
+	case ModeTest:
+		_, err = p.runTest(ctx, task, sess, conv)
+		return sess, err
+	case ModePatch:
+		_, err = p.runPatch(ctx, task, sess, conv)
+		return sess, err
+	case ModeAgent:
+		_, err = p.runAgent(ctx, task, sess, conv)
+		return sess, err
+	}
+	_, err = p.runGenerate(ctx, task, sess, conv)
+	return sess, err
+}
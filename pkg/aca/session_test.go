@@ -0,0 +1,73 @@
+package aca
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// flakyRuntime 前 failures 次运行返回编译错误
+type flakyRuntime struct {
+	failures int
+	runs     int
+}
+
+func (f *flakyRuntime) Run(ctx context.Context, spec RunSpec) (string, error) {
+	f.runs++
+	if f.runs <= f.failures {
+		return "  File \"main.py\", line 1\nSyntaxError: invalid syntax", &ExitError{Code: 1}
+	}
+	return "ok", nil
+}
+
+func TestGenerateRetriesWithFeedback(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"```python\nprint(\n```", "```python\nprint(1)\n```"}}
+	store := NewSessionStore(t.TempDir())
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 1}), WithLogger(DiscardLogger),
+		WithMaxAttempts(3), WithSessionStore(store))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Attempts) != 2 || res.Attempts[0].Verdict.Passed || !res.Attempts[1].Verdict.Passed {
+		t.Fatalf("unexpected attempts %+v", res.Attempts)
+	}
+	// 第二次请求应包含 Watcher 的反馈
+	if provider.msgCount[1] != 4 {
+		t.Errorf("expect feedback in second request, got %d messages", provider.msgCount[1])
+	}
+	sess, err := store.Load(res.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Status != "passed" || len(sess.Attempts) != 2 {
+		t.Errorf("unexpected persisted session %+v", sess)
+	}
+}
+
+func TestResumeFailedSession(t *testing.T) {
+	store := NewSessionStore(t.TempDir())
+	workDir := t.TempDir()
+	provider := &scriptedProvider{replies: []string{"```python\nprint(\n```"}}
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 1}), WithLogger(DiscardLogger), WithSessionStore(store))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: workDir})
+	if KindOf(err) != ErrBuild {
+		t.Fatalf("expect build error, got %v", err)
+	}
+
+	provider.replies = []string{"```python\nprint(1)\n```"}
+	sess, err := p.Resume(context.Background(), res.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Status != "passed" || len(sess.Attempts) != 2 {
+		t.Errorf("unexpected resumed session status %s, attempts %d", sess.Status, len(sess.Attempts))
+	}
+	conv, _ := store.LoadConversation(sess.ID)
+	if !strings.Contains(conv.Messages[3].Content, "SyntaxError") {
+		t.Errorf("expect watcher feedback in conversation, got %q", conv.Messages[3].Content)
+	}
+	if _, err := p.Resume(context.Background(), res.SessionID); KindOf(err) != ErrUsage {
+		t.Errorf("resuming a passed session should fail, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package aca
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// schemaProvider 记录请求的输出约束与系统提示词
+type schemaProvider struct {
+	content string
+	schemas []bool
+	system  string
+}
+
+func (s *schemaProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	s.schemas = append(s.schemas, req.Schema != nil)
+	s.system = req.Messages[0].Content
+	return &Completion{Content: s.content}, nil
+}
+
+func TestStructuredOutput(t *testing.T) {
+	provider := &schemaProvider{content: `{"files": [{"path": "main.py", "language": "python", "content": "import util\nutil.hello()\n"}, {"path": "util.py", "language": "python", "content": "def hello():\n    print('hi')\n"}], "entrypoint": "main.py", "run_command": "python main.py"}`}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithStructuredOutput(true))
+	res, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 2 || len(provider.schemas) != 1 || !provider.schemas[0] || !strings.Contains(provider.system, "JSON") {
+		t.Errorf("unexpected files %+v, schemas %v", res.Files, provider.schemas)
+	}
+
+	// 服务忽略约束返回 markdown 时回退到代码块提取
+	provider = &schemaProvider{content: "```python\nprint(1)\n```"}
+	p, _ = New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithStructuredOutput(true))
+	if res, err = p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()}); err != nil || len(res.Files) != 1 {
+		t.Errorf("markdown fallback failed: %v %+v", err, res.Files)
+	}
+
+	// 不符合 schema 的输出作为提取错误反馈
+	provider = &schemaProvider{content: `{"files": []}`}
+	p, _ = New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithStructuredOutput(true))
+	if _, err = p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()}); KindOf(err) != ErrExtract {
+		t.Errorf("expect extract error, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package aca
+
+import (
+	"context"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+)
+
+// ToolLimits agent 模式下工具调用循环的限制，字段为 0 表示不限制
+type ToolLimits = agent.LoopLimits
+
+// DefaultToolLimits 默认最多 30 次模型请求、20 万 token
+var DefaultToolLimits = ToolLimits{MaxSteps: 30, MaxTokens: 200000}
+
+// WithToolLimits 设置 agent 模式下工具调用循环的步数与 token 上限，为整个任务（含重试）累计
+func WithToolLimits(limits ToolLimits) Option {
+	return func(pl *Pipeline) { pl.toolLimits = limits }
+}
+
+// Agent 让模型通过工具调用（write_file、read_file、list_files、run_command、run_tests）在沙箱工作目录中
+// 自行编写、运行并修改代码；模型结束调用后运行主文件校验，失败时按 Watcher 的判定反馈并继续
+func (p *Pipeline) Agent(ctx context.Context, task Task) (*GenerateResult, error) {
+	task, err := p.normalize(task, true)
+	if err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	sess := p.newSession(ModeAgent, task)
+	if task, err = p.runDir(task, sess); err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	conv, err := p.conversation(ModeAgent, p.PromptData(task.Language, task.Prompt))
+	if err != nil {
+		return &GenerateResult{Task: task}, err
+	}
+	return p.runAgent(ctx, task, sess, conv)
+}
+
+func (p *Pipeline) runAgent(ctx context.Context, task Task, sess *Session, conv *Conversation) (*GenerateResult, error) {
+	start := time.Now()
+	p.logger.Info("创建/检查工作目录:", task.WorkDir)
+	toolbox := &agent.Toolbox{
+		Runtime:   p.runtime,
+		Languages: p.languages,
+		Language:  task.Language,
+		WorkDir:   task.WorkDir,
+		MountDir:  task.MountDir,
+		Guard:     p.guard(task, sess, nil),
+		Check:     p.scan,
+		OnCall: func(call llm.ToolCall, output string) {
+			p.logger.Info("工具调用", call.Name, call.Arguments, "\n====================\n", output, "\n====================")
+		},
+	}
+	if p.mcp != nil {
+		toolbox.Extra = p.mcp
+	}
+	if last := sess.LastAttempt(); last != nil {
+		// 恢复会话时上一次尝试的文件已写回工作目录
+		toolbox.Track(last.Files)
+	}
+	var used agent.LoopResult
+	ask := func(ctx context.Context, conv *Conversation, a *Attempt) (string, error) {
+		limits, err := p.remainingToolLimits(used)
+		if err != nil {
+			return "", err
+		}
+		if p.hooks.OnRequest != nil {
+			p.hooks.OnRequest(ctx, task)
+		}
+		p.logger.Info("请求 LLM 调用工具编写代码...")
+		toolbox.OnDelta = p.onDelta(ctx)
+		res, err := toolbox.Loop(ctx, p.client(), task.Model, conv, limits)
+		used.Steps += res.Steps
+		used.Tokens += res.Tokens
+		a.ToolCalls = append(a.ToolCalls, res.Calls...)
+		p.emitCalls(ctx, res.Calls)
+		p.logger.Info("工具调用循环:", res.Steps, "步，约", res.Tokens, "tokens")
+		if err != nil {
+			return "", err
+		}
+		if p.hooks.OnResponse != nil {
+			p.hooks.OnResponse(ctx, task, res.Content)
+		}
+		p.logger.Info("LLM 最终回复:\n====================\n", res.Content, "\n====================")
+		return res.Content, nil
+	}
+	err := p.attempts(ctx, task, sess, conv, ask, func(ctx context.Context, a *Attempt) ([]File, string, error) {
+		files := toolbox.Files()
+		if len(files) == 0 {
+			return nil, "", errs.Errorf(errs.Extract, "tool loop", "no files were written")
+		}
+		if p.hooks.OnFiles != nil {
+			p.hooks.OnFiles(ctx, task, files)
+		}
+		p.emit(ctx, EventFiles, FilesEvent{Files: files})
+		if err := p.scan(files); err != nil {
+			return files, "", err
+		}
+		files, err := p.lint(ctx, task, files)
+		if err != nil {
+			return files, "", err
+		}
+		if err := p.reviewFiles(ctx, task, a, task.Prompt, files); err != nil {
+			return files, "", err
+		}
+		output, err := p.runFiles(ctx, task, files)
+		return files, output, err
+	}, nil)
+	res := &GenerateResult{Task: task, SessionID: sess.ID, Attempts: sess.Attempts, Usage: sess.Usage, Duration: time.Since(start)}
+	if last := sess.LastAttempt(); last != nil {
+		res.Response, res.Files, res.Output = last.Response, last.Files, last.Output
+		res.Review = last.Review
+	}
+	return res, err
+}
+
+// remainingToolLimits 返回扣除已用部分后的限制，已用尽时返回 LLM 错误
+func (p *Pipeline) remainingToolLimits(used agent.LoopResult) (ToolLimits, error) {
+	limits := p.toolLimits
+	if limits.MaxSteps > 0 {
+		if limits.MaxSteps -= used.Steps; limits.MaxSteps <= 0 {
+			return limits, errs.Errorf(errs.LLM, "tool loop", "step limit %d reached", p.toolLimits.MaxSteps)
+		}
+	}
+	if limits.MaxTokens > 0 {
+		if limits.MaxTokens -= used.Tokens; limits.MaxTokens <= 0 {
+			return limits, errs.Errorf(errs.LLM, "tool loop", "token limit %d reached (used %d)", p.toolLimits.MaxTokens, used.Tokens)
+		}
+	}
+	return limits, nil
+}
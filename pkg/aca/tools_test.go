@@ -0,0 +1,84 @@
+package aca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+)
+
+// toolProvider 依次返回 replies，用完后重复最后一个
+type toolProvider struct {
+	replies  []*Completion
+	calls    int
+	requests []CompletionRequest
+}
+
+func (f *toolProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	reply := f.replies[min(f.calls, len(f.replies)-1)]
+	f.calls++
+	f.requests = append(f.requests, req)
+	return reply, nil
+}
+
+func toolCall(id, name, args string) *Completion {
+	return &Completion{ToolCalls: []llm.ToolCall{{ID: id, Name: name, Arguments: args}}}
+}
+
+func TestAgent(t *testing.T) {
+	rt := &fakeRuntime{output: "hello\n"}
+	provider := &toolProvider{replies: []*Completion{
+		toolCall("1", "write_file", `{"path":"main.py","content":"print('hello')\n"}`),
+		toolCall("2", "write_file", `{"path":"../escape.py","content":"x"}`),
+		toolCall("3", "run_command", `{"command":"python main.py"}`),
+		{Content: "done"},
+	}}
+	p, _ := New(WithProvider(provider), WithRuntime(rt), WithLogger(DiscardLogger))
+	dir := t.TempDir()
+	res, err := p.Agent(context.Background(), Task{Prompt: "say hello", Language: "python", WorkDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 || res.Files[0].Path != "main.py" || !res.Files[0].Main {
+		t.Errorf("unexpected files %+v", res.Files)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(res.Task.WorkDir), "escape.py")); err == nil {
+		t.Error("file written outside the work dir")
+	}
+	// run_command 与最终校验各运行一次
+	if len(rt.specs) != 2 || strings.Join(rt.specs[0].Cmd, " ") != "sh -c python main.py" {
+		t.Errorf("unexpected run specs %+v", rt.specs)
+	}
+	if res.Response != "done" || res.Output != "hello\n" {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestAgentLimits(t *testing.T) {
+	provider := &toolProvider{replies: []*Completion{toolCall("1", "list_files", `{}`)}}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger),
+		WithToolLimits(ToolLimits{MaxSteps: 3}), WithMaxAttempts(2))
+	_, err := p.Agent(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if KindOf(err) != ErrLLM {
+		t.Fatalf("expect llm error, got %v", err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("expect 3 model calls, got %d", provider.calls)
+	}
+}
+
+func TestAgentNoFiles(t *testing.T) {
+	provider := &toolProvider{replies: []*Completion{{Content: "nothing to do"}}}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithMaxAttempts(2))
+	_, err := p.Agent(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: t.TempDir()})
+	if KindOf(err) != ErrExtract {
+		t.Fatalf("expect extract error, got %v", err)
+	}
+	// 提取错误会反馈给模型重试
+	if provider.calls != 2 {
+		t.Errorf("expect 2 model calls, got %d", provider.calls)
+	}
+}
@@ -0,0 +1,149 @@
+package aca
+
+import (
+	"io"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/config"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/container"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lang"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/lint"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/logger"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/mcp"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/prompt"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/security"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/usage"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// 以下类型别名构成对外稳定的 API，实现位于 internal 包中
+type (
+	Config            = config.Config
+	Provider          = llm.Provider
+	Message           = llm.Message
+	Conversation      = llm.Conversation
+	CompletionRequest = llm.Request
+	Completion        = llm.Response
+	CompletionUsage   = llm.Usage
+	CompletionError   = llm.CompletionError
+	Condition         = llm.Condition
+	Runtime           = container.Runtime
+	RunSpec           = container.RunSpec
+	RunTrace          = container.Trace
+	ExitError         = container.ExitError
+	DockerRuntime     = container.DockerClient
+	Language          = lang.Language
+	LanguageRegistry  = lang.Registry
+	Logger            = logger.Logger
+	File              = workspace.File
+	Session           = session.Session
+	SessionStore      = session.Store
+	Attempt           = session.Attempt
+	Verdict           = agent.Verdict
+	SecurityPolicy    = security.Policy
+	SecurityFinding   = security.Finding
+	QualityGate       = lint.Gate
+	LintIssue         = lint.Issue
+	ReviewOptions     = config.Review
+	LLMOptions        = config.LLM
+	Review            = agent.Review
+	ReviewFinding     = agent.ReviewFinding
+	MCPServerConfig   = mcp.ServerConfig
+	MCPServerStatus   = mcp.ServerStatus
+	MCPTools          = agent.MCPTools
+	ToolCallRecord    = agent.ToolCallRecord
+	PromptConfig      = prompt.Config
+	PromptSet         = prompt.Set
+	PromptData        = prompt.Data
+	PromptTemplate    = prompt.Template
+	Usage             = usage.Usage
+	UsageCall         = usage.Call
+	Pricing           = usage.Pricing
+	Price             = usage.Price
+	Budget            = usage.Budget
+)
+
+// 会话模式
+const (
+	ModeGen   = "gen"
+	ModeTest  = "test"
+	ModeChat  = "chat"
+	ModePatch = "patch"
+	ModeAgent = "agent"
+)
+
+// DiffFiles 生成两组文件之间的 unified diff
+func DiffFiles(before, after []File) string {
+	return workspace.DiffFiles(before, after)
+}
+
+// WriteFiles 将文件写入 dir，按需创建子目录
+func WriteFiles(dir string, files []File) error {
+	return workspace.WriteFiles(dir, files)
+}
+
+// DiscardLogger 丢弃所有日志的 Logger
+var DiscardLogger Logger = logger.Discard
+
+// NewLogger 返回将所有级别的日志写到 w 的 Logger
+func NewLogger(w io.Writer) Logger {
+	return logger.New(w)
+}
+
+// Task 一次生成或测试任务
+type Task struct {
+	Prompt   string // 自然语言需求，必填
+	Language string // 编程语言，默认 go
+	Model    string // 模型名，默认使用 Pipeline 的模型
+	WorkDir  string // 宿主机工作目录，默认 ./tmp
+	MountDir string // 容器内挂载路径，默认 /app
+	// Force 允许覆盖 WorkDir 中不是由本次会话写入的已有文件
+	Force bool
+	// Files Patch 模式下交给模型的文件（相对 WorkDir），为空时收集 WorkDir 中该语言的全部源文件
+	Files []string
+}
+
+// GenerateResult Generate 的结果，Response/Files/Output 来自最后一次尝试，出错时已完成阶段的字段仍会填充
+type GenerateResult struct {
+	Task      Task
+	SessionID string // 未配置 SessionStore 时为空
+	Response  string // LLM 原始响应
+	Files     []File
+	Output    string  // 容器输出
+	Review    *Review // 最后一次尝试的审查结论，未启用审查时为 nil
+	Attempts  []Attempt
+	Usage     Usage // 会话累计的模型用量
+	Duration  time.Duration
+}
+
+// TestResult Test 的结果，Response/TestFile/Output 来自最后一次尝试，出错时已完成阶段的字段仍会填充
+type TestResult struct {
+	Task      Task
+	SessionID string // 未配置 SessionStore 时为空
+	Response  string // LLM 原始响应
+	TestFile  File
+	Output    string // 容器输出
+	Passed    bool
+	Review    *Review // 最后一次尝试的审查结论，未启用审查时为 nil
+	Attempts  []Attempt
+	Usage     Usage // 会话累计的模型用量
+	Duration  time.Duration
+}
+
+// PatchResult Patch 的结果，Response/Files/Output 来自最后一次尝试；未通过校验时修改已回滚
+type PatchResult struct {
+	Task      Task
+	SessionID string  // 未配置 SessionStore 时为空
+	Response  string  // LLM 原始响应
+	Diff      string  // 最后一次尝试对文件的修改
+	Files     []File  // 最后一次尝试修改后的文件
+	Output    string  // 容器输出
+	Applied   bool    // 修改是否保留在 WorkDir 中
+	Review    *Review // 最后一次尝试的审查结论，未启用审查时为 nil
+	Attempts  []Attempt
+	Usage     Usage // 会话累计的模型用量
+	Duration  time.Duration
+}
@@ -0,0 +1,139 @@
+package aca
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/usage"
+)
+
+// WithPricing 设置各模型每百万 token 的价格，用于估算会话费用
+func WithPricing(pricing Pricing) Option {
+	return func(pl *Pipeline) { pl.pricing = pricing }
+}
+
+// WithBudget 设置单个会话的 token 与费用上限；会话用量达到上限后不再发起模型调用，返回 ErrBudget
+func WithBudget(budget Budget) Option {
+	return func(pl *Pipeline) { pl.budget = budget }
+}
+
+// meter 记录一次运行（一组尝试或一轮对话）中尚未结算的模型调用，并在每次调用前检查会话预算，可并发使用
+type meter struct {
+	mu    sync.Mutex
+	calls []UsageCall
+	// budget 会话预算，spent 为会话已结算与尚未结算的用量合计
+	budget Budget
+	spent  Usage
+}
+
+// newMeter 创建受 budget 限制的 meter，会话此前的用量为 spent
+func newMeter(budget Budget, spent Usage) *meter {
+	return &meter{budget: budget, spent: spent}
+}
+
+func (m *meter) record(c UsageCall) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, c)
+	m.spent.Add(c)
+}
+
+// check 会话用量达到预算时返回 ErrBudget
+func (m *meter) check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.budget.Check(m.spent)
+}
+
+// settle 取出尚未结算的调用及其合计
+func (m *meter) settle() ([]UsageCall, Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := m.calls
+	m.calls = nil
+	var u Usage
+	for _, c := range calls {
+		u.Add(c)
+	}
+	return calls, u
+}
+
+type meterKey struct{}
+
+// withMeter 返回记录模型调用到 m 的 ctx
+func withMeter(ctx context.Context, m *meter) context.Context {
+	return context.WithValue(ctx, meterKey{}, m)
+}
+
+func meterFrom(ctx context.Context) *meter {
+	m, _ := ctx.Value(meterKey{}).(*meter)
+	return m
+}
+
+// meteredProvider 将每次模型调用的用量与费用记录到 ctx 中的 meter，服务未返回用量时按消息与回复估算；
+// 会话用量达到预算时不再调用模型
+type meteredProvider struct {
+	Provider
+	pricing Pricing
+}
+
+func (m *meteredProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	if mt := meterFrom(ctx); mt != nil {
+		if err := mt.check(); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	resp, err := m.Provider.Complete(ctx, req)
+	// 被截断、拒绝或过滤的回复同样消耗 token
+	counted := resp
+	var incomplete *llm.CompletionError
+	if errors.As(err, &incomplete) {
+		counted = incomplete.Response
+	}
+	if mt := meterFrom(ctx); mt != nil && counted != nil {
+		mt.record(m.call(req, counted, time.Since(start)))
+	}
+	return resp, err
+}
+
+func (m *meteredProvider) call(req CompletionRequest, resp *Completion, d time.Duration) UsageCall {
+	model := req.Model
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	c := UsageCall{Model: model, Duration: d, Tokens: usage.Tokens{
+		Prompt:     resp.Usage.PromptTokens,
+		Completion: resp.Usage.CompletionTokens,
+		Cached:     resp.Usage.CachedTokens,
+		Total:      resp.Usage.TotalTokens,
+	}}
+	if c.Total == 0 {
+		reply := &Conversation{Messages: []Message{{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls}}}
+		c.Prompt, c.Completion = (&Conversation{Messages: req.Messages}).Tokens(), reply.Tokens()
+		c.Total, c.Estimated = c.Prompt+c.Completion, true
+	}
+	if price, ok := m.pricing.Lookup(model); ok {
+		c.Cost = price.Cost(c.Tokens)
+	}
+	return c
+}
+
+// budgetErr 从错误链中取出预算耗尽的错误，使被生成、审查等阶段包装后的错误仍以 ErrBudget 返回
+func budgetErr(err error) error {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if be, ok := e.(*errs.Error); ok && be.Kind == errs.Budget {
+			return be
+		}
+	}
+	return err
+}
+
+// client 返回记录用量的大模型服务，流水线的模型调用都经过它
+func (p *Pipeline) client() Provider {
+	return &meteredProvider{Provider: p.provider, pricing: p.pricing}
+}
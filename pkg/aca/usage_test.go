@@ -0,0 +1,124 @@
+package aca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/llm"
+)
+
+// usageProvider 每次返回相同的回复并报告固定的用量
+type usageProvider struct {
+	reply string
+	usage CompletionUsage
+}
+
+func (u *usageProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	return &Completion{Content: u.reply, Usage: u.usage}, nil
+}
+
+func TestSessionUsageAndBudget(t *testing.T) {
+	provider := &usageProvider{reply: "```python\nprint(\n```", usage: CompletionUsage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100, CachedTokens: 40}}
+	store := NewSessionStore(t.TempDir())
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 10}), WithLogger(DiscardLogger),
+		WithMaxAttempts(5), WithSessionStore(store), WithBudget(Budget{Tokens: 250}),
+		WithPricing(Pricing{"gpt-*": {Prompt: 1, Completion: 2, Cached: 0.5}}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", Model: "gpt-test", WorkDir: t.TempDir()})
+	if KindOf(err) != ErrBudget {
+		t.Fatalf("expect budget error, got %v", err)
+	}
+	// 每次尝试 100 tokens，第 3 次后达到 250 的预算，不再重试
+	if len(res.Attempts) != 3 || res.Usage.Calls != 3 || res.Usage.Total != 300 || res.Usage.Cached != 120 {
+		t.Fatalf("unexpected usage %+v after %d attempts", res.Usage, len(res.Attempts))
+	}
+	a := res.Attempts[0]
+	if len(a.Calls) != 1 || a.Calls[0].Model != "gpt-test" || a.Usage.Total != 100 || a.Calls[0].Estimated {
+		t.Errorf("unexpected attempt usage %+v", a.Calls)
+	}
+	if want := 3 * (40*1 + 40*0.5 + 20*2) / 1e6; math.Abs(res.Usage.Cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", res.Usage.Cost, want)
+	}
+	sess, err := store.Load(res.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Usage != res.Usage || sess.Attempts[2].Usage.Total != 100 {
+		t.Errorf("usage not persisted: %+v", sess.Usage)
+	}
+
+	// 恢复已超出预算的会话时不再请求模型
+	if _, err := p.Resume(context.Background(), res.SessionID); KindOf(err) != ErrBudget {
+		t.Errorf("expect budget error on resume, got %v", err)
+	}
+}
+
+// truncatingProvider 每次都返回被截断的回复并报告固定的用量
+type truncatingProvider struct {
+	calls int
+}
+
+func (f *truncatingProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	f.calls++
+	resp := &Completion{Content: "```python\nprint(", Usage: CompletionUsage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100}}
+	return nil, &llm.CompletionError{Condition: llm.Truncated, Response: resp}
+}
+
+func TestBudgetCheckedBeforeEachCall(t *testing.T) {
+	provider := &truncatingProvider{}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger), WithBudget(Budget{Tokens: 150}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()})
+	if KindOf(err) != ErrBudget {
+		t.Fatalf("expect budget error, got %v", err)
+	}
+	// 一次尝试内的续写请求同样受预算限制：第 2 次调用后用量达到 200，不再续写
+	if provider.calls != 2 || res.Usage.Total != 200 {
+		t.Errorf("expect 2 calls within the attempt, got %d calls and %+v", provider.calls, res.Usage)
+	}
+}
+
+func TestEstimatedUsage(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"```python\nprint(1)\n```"}}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := res.Usage; u.Calls != 1 || !u.Estimated || u.Prompt == 0 || u.Completion == 0 || u.Total != u.Prompt+u.Completion || u.Cost != 0 {
+		t.Errorf("unexpected estimated usage %+v", u)
+	}
+}
+
+// overloadedProvider 主模型总是过载，其他模型正常回复
+type overloadedProvider struct {
+	models []string
+}
+
+func (o *overloadedProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	o.models = append(o.models, req.Model)
+	if req.Model == "primary" {
+		return nil, &llm.StatusError{StatusCode: 529, Err: errors.New("overloaded")}
+	}
+	return &Completion{Content: "```python\nprint(1)\n```", Usage: CompletionUsage{PromptTokens: 10, CompletionTokens: 10, TotalTokens: 20}}, nil
+}
+
+func TestFallbackModel(t *testing.T) {
+	provider := &overloadedProvider{}
+	p, _ := New(WithProvider(provider), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger),
+		WithLLMOptions(LLMOptions{MaxRetries: 1, Backoff: time.Millisecond, FallbackModels: []string{"backup"}}),
+		WithPricing(Pricing{"backup": {Prompt: 1, Completion: 1}}))
+	res, err := p.Generate(context.Background(), Task{Prompt: "print 1", Language: "python", Model: "primary", WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(provider.models) != "[primary primary backup]" {
+		t.Errorf("unexpected requests %v", provider.models)
+	}
+	// 用量按实际使用的备用模型计价
+	if c := res.Attempts[0].Calls; len(c) != 1 || c[0].Model != "backup" || c[0].Cost == 0 {
+		t.Errorf("unexpected calls %+v", c)
+	}
+}
@@ -0,0 +1,250 @@
+package aca
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/agent"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/errs"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/session"
+	"github.com/Zephyruston/Agent-Cat-Agent/internal/workspace"
+)
+
+// WithRunDirs 为每次 gen/test/chat 运行在 WorkDir 下创建以会话 ID 命名的独立子目录，
+// 避免上次运行残留的文件被再次编译运行；patch 模式始终直接修改 WorkDir
+func WithRunDirs(enabled bool) Option {
+	return func(pl *Pipeline) { pl.runDirs = enabled }
+}
+
+// acquire 在任务共用同一个工作目录或 git 工作区（未启用独立运行目录或启用了 git 集成）时等待其他任务结束，
+// 避免互相覆盖文件、切换分支；返回结束任务时调用的 release，ctx 取消时返回其错误
+func (p *Pipeline) acquire(ctx context.Context) (release func(), err error) {
+	if !p.git && p.runDirs {
+		return func() {}, nil
+	}
+	select {
+	case p.exclusive <- struct{}{}:
+		return func() { <-p.exclusive }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runDir 启用独立运行目录时将任务的 WorkDir 换成 WorkDir/<会话 ID> 并记录到会话
+func (p *Pipeline) runDir(task Task, sess *Session) (Task, error) {
+	if !p.runDirs {
+		return task, nil
+	}
+	name := sess.ID
+	if name == "" {
+		name = session.NewID()
+	}
+	task.WorkDir = filepath.Join(task.WorkDir, name)
+	if err := os.MkdirAll(task.WorkDir, 0755); err != nil {
+		return task, errs.E(errs.Other, "create run dir", err)
+	}
+	sess.WorkDir = task.WorkDir
+	p.saveSession(sess, nil)
+	p.logger.Info("运行目录:", task.WorkDir)
+	return task, nil
+}
+
+// originals 记录会话覆盖的已有文件的原始内容，清理失败尝试时恢复这些文件、只删除新建的文件；
+// 可并发使用，nil 表示不记录
+type originals struct {
+	mu    sync.Mutex
+	files map[string][]byte // 以绝对路径为键
+}
+
+func newOriginals() *originals {
+	return &originals{files: make(map[string][]byte)}
+}
+
+// save 在 path 第一次被覆盖前记录其内容
+func (o *originals) save(path string) {
+	if o == nil {
+		return
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.files[path]; ok {
+		return
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		o.files[path] = data
+	}
+}
+
+// remove 清理 dir 中的 files：被覆盖的已有文件恢复原始内容，本会话新建的文件删除
+func (o *originals) remove(dir string, files []File) error {
+	var created []File
+	for _, f := range files {
+		path, err := workspace.SafePath(dir, f.Path)
+		if err != nil {
+			continue
+		}
+		var data []byte
+		var ok bool
+		if o != nil {
+			o.mu.Lock()
+			data, ok = o.files[path]
+			o.mu.Unlock()
+		}
+		if !ok {
+			created = append(created, f)
+			continue
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return workspace.RemoveFiles(dir, created)
+}
+
+// guard 返回写入文件前的检查：输出将要写入的文件预览，并拒绝覆盖不是由本会话写入的已有文件，
+// Task.Force 为 true 时允许覆盖，并在 orig 中记录被覆盖文件的原始内容
+func (p *Pipeline) guard(task Task, sess *Session, orig *originals) agent.Guard {
+	return func(files map[string]string) error {
+		owned := make(map[string]bool)
+		for _, a := range sess.Attempts {
+			for _, f := range a.Files {
+				owned[filepath.Join(task.WorkDir, f.Path)] = true
+			}
+		}
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		var preview strings.Builder
+		var conflicts []string
+		for _, path := range paths {
+			rel, err := filepath.Rel(task.WorkDir, path)
+			if err != nil {
+				rel = path
+			}
+			action := "新建"
+			if _, err := os.Lstat(path); err == nil {
+				action = "覆盖"
+				if !owned[path] && !task.Force {
+					conflicts = append(conflicts, rel)
+				}
+			}
+			fmt.Fprintf(&preview, "\n  %s %s (%d bytes)", action, rel, len(files[path]))
+		}
+		p.logger.Info("将写入以下文件:" + preview.String())
+		if len(conflicts) > 0 {
+			return errs.Errorf(errs.Usage, "write files", "refusing to overwrite existing files %s, use force to overwrite", strings.Join(conflicts, ", "))
+		}
+		for _, path := range paths {
+			if !owned[path] {
+				orig.save(path)
+			}
+		}
+		return nil
+	}
+}
+
+// CleanRuns 删除 root 下早于 olderThan 的独立运行目录（以会话 ID 命名的子目录），
+// archiveDir 非空时先将目录打包为 archiveDir/<会话 ID>.tar.gz，返回处理过的目录名
+func CleanRuns(root string, olderThan time.Duration, archiveDir string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, errs.E(errs.Usage, "clean runs", err)
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var cleaned []string
+	for _, e := range entries {
+		if !e.IsDir() || !session.IsID(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if archiveDir != "" {
+			if err := archiveDirTo(dir, filepath.Join(archiveDir, e.Name()+".tar.gz")); err != nil {
+				return cleaned, errs.E(errs.Other, "archive run", err)
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return cleaned, errs.E(errs.Other, "clean runs", err)
+		}
+		cleaned = append(cleaned, e.Name())
+	}
+	return cleaned, nil
+}
+
+// archiveDirTo 将 dir 打包为 gzip 压缩的 tar 文件，包内路径以 dir 的目录名开头
+func archiveDirTo(dir, target string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	base := filepath.Dir(dir)
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,101 @@
+package aca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunDirsIsolateRuns(t *testing.T) {
+	root := t.TempDir()
+	store := NewSessionStore(t.TempDir())
+	p, _ := New(WithProvider(&fakeProvider{content: "```python\nprint(1)\n```"}), WithRuntime(&fakeRuntime{}),
+		WithLogger(DiscardLogger), WithSessionStore(store), WithRunDirs(true))
+	first, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Task.WorkDir == second.Task.WorkDir || filepath.Dir(first.Task.WorkDir) != root {
+		t.Errorf("expect separate run dirs under %s, got %s and %s", root, first.Task.WorkDir, second.Task.WorkDir)
+	}
+	if filepath.Base(first.Task.WorkDir) != first.SessionID {
+		t.Errorf("run dir should be named after the session, got %s", first.Task.WorkDir)
+	}
+	sess, _ := store.Load(first.SessionID)
+	if sess.WorkDir != first.Task.WorkDir {
+		t.Errorf("session should record the run dir, got %s", sess.WorkDir)
+	}
+}
+
+func TestRefuseOverwrite(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "main.py"), []byte("# mine\n"), 0644)
+	p, _ := New(WithProvider(&fakeProvider{content: "```python\nprint(1)\n```"}), WithRuntime(&fakeRuntime{}), WithLogger(DiscardLogger))
+	if _, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: workDir}); KindOf(err) != ErrUsage {
+		t.Fatalf("expect usage error, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "main.py")); string(data) != "# mine\n" {
+		t.Errorf("existing file was overwritten: %q", data)
+	}
+	if _, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: workDir, Force: true}); err != nil {
+		t.Errorf("force should allow overwriting, got %v", err)
+	}
+}
+
+func TestFailedAttemptRestoresOverwrittenFiles(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "main.py"), []byte("# mine\n"), 0644)
+	provider := &scriptedProvider{replies: []string{
+		`{"files": [{"path": "main.py", "language": "python", "content": "print(\n"}, {"path": "new.py", "language": "python", "content": "x = 1\n"}], "entrypoint": "main.py", "run_command": ""}`,
+		`{"files": [{"path": "other.py", "language": "python", "content": "print(1)\n"}], "entrypoint": "other.py", "run_command": ""}`,
+	}}
+	p, _ := New(WithProvider(provider), WithRuntime(&flakyRuntime{failures: 1}), WithLogger(DiscardLogger), WithMaxAttempts(2))
+	if _, err := p.Generate(context.Background(), Task{Prompt: "x", Language: "python", WorkDir: workDir, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "main.py")); string(data) != "# mine\n" {
+		t.Errorf("overwritten file should be restored after the failed attempt, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "new.py")); !os.IsNotExist(err) {
+		t.Errorf("file created by the failed attempt should be removed, stat: %v", err)
+	}
+}
+
+func TestCleanRuns(t *testing.T) {
+	root, archive := t.TempDir(), t.TempDir()
+	old := filepath.Join(root, "20240101-120000-abcdef")
+	recent := filepath.Join(root, "20990101-120000-abcdef")
+	other := filepath.Join(root, "keep")
+	for _, dir := range []string{old, recent, other} {
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, past, past)
+	os.Chtimes(other, past, past)
+
+	cleaned, err := CleanRuns(root, 24*time.Hour, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cleaned) != 1 || cleaned[0] != filepath.Base(old) {
+		t.Fatalf("unexpected cleaned runs %v", cleaned)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("old run should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(archive, filepath.Base(old)+".tar.gz")); err != nil {
+		t.Errorf("old run should be archived: %v", err)
+	}
+	for _, dir := range []string{recent, other} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s should be kept", dir)
+		}
+	}
+}